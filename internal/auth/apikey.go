@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Scope is something an API key may be used for. Tokens from logins carry no
+// scopes and may do whatever their roles allow.
+type Scope string
+
+const (
+	ScopePostsRead        Scope = "posts:read"
+	ScopePostsWrite       Scope = "posts:write"
+	ScopeCommentsWrite    Scope = "comments:write"
+	ScopeCommentsModerate Scope = "comments:moderate"
+)
+
+// APIKeyPrefix starts every API key, so a leaked key is easy to recognise.
+const APIKeyPrefix = "bp_"
+
+// NewAPIKey returns a random API key. Only its HashAPIKey should be stored.
+func NewAPIKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return APIKeyPrefix + hex.EncodeToString(b)
+}
+
+// HashAPIKey is the digest API keys are stored and looked up by. Keys are
+// random and long, so unlike passwords a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
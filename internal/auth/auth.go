@@ -0,0 +1,185 @@
+// Package auth issues and verifies the JSON Web Tokens that authenticate API
+// requests, and describes what the caller of a request is allowed to do.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleAuthor Role = "author"
+	RoleReader Role = "reader"
+)
+
+// Writers are the roles that may write posts at all. Authors may only touch
+// their own, see Principal.CanEdit.
+var Writers = []Role{RoleAdmin, RoleEditor, RoleAuthor}
+
+// Editors are the roles that may manage every post.
+var Editors = []Role{RoleAdmin, RoleEditor}
+
+// Members are all the roles, held by everyone with an account.
+var Members = []Role{RoleAdmin, RoleEditor, RoleAuthor, RoleReader}
+
+// ErrInvalidToken is returned for a token that is malformed, badly signed or
+// expired.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID    string
+	Name  string
+	Roles []Role
+
+	// TokenID and ExpiresAt identify the token the principal presented.
+	TokenID   string
+	ExpiresAt time.Time
+
+	// TokenVersion is the version of the user's tokens the token was issued
+	// at. Changing a user's password or roles moves it on, which retires
+	// every token issued before.
+	TokenVersion int
+
+	// KeyID and Scopes are set when the principal presented an API key, which
+	// may only be used within its scopes.
+	KeyID  string
+	Scopes []Scope
+}
+
+// HasRole reports whether p holds any of roles.
+func (p *Principal) HasRole(roles ...Role) bool {
+	return p != nil && slices.ContainsFunc(p.Roles, func(r Role) bool { return slices.Contains(roles, r) })
+}
+
+// Allows reports whether p may act within scope. Only API keys are limited
+// by scopes.
+func (p *Principal) Allows(scope Scope) bool {
+	return p != nil && (p.KeyID == "" || slices.Contains(p.Scopes, scope))
+}
+
+// CanEdit reports whether p may change a post written by authors: editors
+// and admins may change any post, authors only those they wrote or co-wrote.
+func (p *Principal) CanEdit(authors ...string) bool {
+	if p.HasRole(Editors...) {
+		return true
+	}
+	return p.HasRole(RoleAuthor) && slices.Contains(authors, p.ID)
+}
+
+// Claims is the payload of a token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Name    string `json:"name,omitempty"`
+	Roles   []Role `json:"roles"`
+	Version int    `json:"ver,omitempty"`
+}
+
+// Keys signs and verifies tokens with either an HMAC secret or an RSA key
+// pair. A Keys built from an RSA public key alone can verify but not sign.
+type Keys struct {
+	method  jwt.SigningMethod
+	signKey any
+	verify  any
+	issuer  string
+}
+
+// MinSecretLength is the shortest HMAC secret NewHMACKeys accepts, the size
+// of an HS256 digest.
+const MinSecretLength = 32
+
+func NewHMACKeys(secret []byte, issuer string) (*Keys, error) {
+	if len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("hmac secret must be at least %d bytes", MinSecretLength)
+	}
+	return &Keys{method: jwt.SigningMethodHS256, signKey: secret, verify: secret, issuer: issuer}, nil
+}
+
+// NewRSAKeys verifies with public and, when private is not nil, signs with
+// private.
+func NewRSAKeys(public *rsa.PublicKey, private *rsa.PrivateKey, issuer string) *Keys {
+	k := &Keys{method: jwt.SigningMethodRS256, verify: public, issuer: issuer}
+	if private != nil {
+		k.signKey = private
+	}
+	return k
+}
+
+// Sign issues a token for p that expires after ttl.
+func (k *Keys) Sign(p Principal, ttl time.Duration) (string, error) {
+	if k.signKey == nil {
+		return "", errors.New("keys cannot sign without a private key")
+	}
+
+	id := p.TokenID
+	if id == "" {
+		id = newTokenID()
+	}
+	issuedAt := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   p.ID,
+			Issuer:    k.issuer,
+			ID:        id,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(ttl)),
+		},
+		Name:    p.Name,
+		Roles:   p.Roles,
+		Version: p.TokenVersion,
+	}
+
+	token, err := jwt.NewWithClaims(k.method, claims).SignedString(k.signKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token - %w", err)
+	}
+	return token, nil
+}
+
+// Verify checks the signature, expiry and issuer of token and returns who it
+// was issued to.
+func (k *Keys) Verify(token string) (*Principal, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{k.method.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if k.issuer != "" {
+		options = append(options, jwt.WithIssuer(k.issuer))
+	}
+
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) { return k.verify, nil }, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w - %w", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token has no subject - %w", ErrInvalidToken)
+	}
+
+	return &Principal{
+		ID:           claims.Subject,
+		Name:         claims.Name,
+		Roles:        claims.Roles,
+		TokenID:      claims.ID,
+		ExpiresAt:    claims.ExpiresAt.Time,
+		TokenVersion: claims.Version,
+	}, nil
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hmacKeys(t *testing.T, issuer string) *Keys {
+	secret := make([]byte, MinSecretLength)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+
+	keys, err := NewHMACKeys(secret, issuer)
+	require.NoError(t, err)
+	return keys
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := map[string]*Keys{
+		"HMAC": hmacKeys(t, "blog-platform"),
+		"RSA":  NewRSAKeys(&rsaKey.PublicKey, rsaKey, "blog-platform"),
+	}
+	for name, keys := range tests {
+		t.Run(name, func(t *testing.T) {
+			token, err := keys.Sign(Principal{ID: "u1", Name: "Ada", Roles: []Role{RoleAuthor}, TokenVersion: 3}, time.Hour)
+			require.NoError(t, err)
+
+			p, err := keys.Verify(token)
+			require.NoError(t, err)
+			assert.Equal(t, "u1", p.ID)
+			assert.Equal(t, "Ada", p.Name)
+			assert.Equal(t, []Role{RoleAuthor}, p.Roles)
+			assert.Equal(t, 3, p.TokenVersion)
+			assert.NotEmpty(t, p.TokenID)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), p.ExpiresAt, time.Minute)
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	keys := hmacKeys(t, "blog-platform")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	sign := func(method jwt.SigningMethod, key any, claims jwt.Claims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+	valid := func() Claims {
+		return Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "u1",
+				Issuer:    "blog-platform",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Roles: []Role{RoleAdmin},
+		}
+	}
+
+	expired := valid()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := valid()
+	noExpiry.ExpiresAt = nil
+	otherIssuer := valid()
+	otherIssuer.Issuer = "someone-else"
+	noSubject := valid()
+	noSubject.Subject = ""
+
+	tokens := map[string]string{
+		"Garbage":           "not.a.token",
+		"Expired":           sign(jwt.SigningMethodHS256, keys.signKey, expired),
+		"Without expiry":    sign(jwt.SigningMethodHS256, keys.signKey, noExpiry),
+		"Other issuer":      sign(jwt.SigningMethodHS256, keys.signKey, otherIssuer),
+		"Without subject":   sign(jwt.SigningMethodHS256, keys.signKey, noSubject),
+		"Other secret":      sign(jwt.SigningMethodHS256, hmacKeys(t, "").signKey, valid()),
+		"Other algorithm":   sign(jwt.SigningMethodRS256, rsaKey, valid()),
+		"Unsigned":          sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid()),
+		"Tampered":          sign(jwt.SigningMethodHS256, keys.signKey, valid()) + "x",
+		"Public key as MAC": sign(jwt.SigningMethodHS256, []byte("-----BEGIN PUBLIC KEY-----"), valid()),
+	}
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			_, err := keys.Verify(token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestKeysValidation(t *testing.T) {
+	_, err := NewHMACKeys([]byte("too short"), "")
+	assert.Error(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	verifyOnly := NewRSAKeys(&rsaKey.PublicKey, nil, "")
+	_, err = verifyOnly.Sign(Principal{ID: "u1"}, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestPrincipalPermissions(t *testing.T) {
+	admin := &Principal{ID: "a", Roles: []Role{RoleAdmin}}
+	editor := &Principal{ID: "e", Roles: []Role{RoleEditor}}
+	author := &Principal{ID: "u1", Roles: []Role{RoleAuthor}}
+	reader := &Principal{ID: "r", Roles: []Role{RoleReader}}
+	var anonymous *Principal
+
+	assert.True(t, admin.CanEdit("u1"))
+	assert.True(t, editor.CanEdit(""))
+	assert.True(t, author.CanEdit("u1"))
+	assert.False(t, author.CanEdit("u2"))
+	assert.False(t, author.CanEdit(""))
+	assert.False(t, author.CanEdit())
+	assert.True(t, author.CanEdit("u2", "u1"), "co-authors edit too")
+	assert.False(t, reader.CanEdit("r"))
+	assert.False(t, anonymous.CanEdit("u1"))
+
+	assert.True(t, author.HasRole(Writers...))
+	assert.False(t, author.HasRole(Editors...))
+	assert.False(t, anonymous.HasRole(RoleReader))
+
+	key := &Principal{ID: "u1", Roles: []Role{RoleAuthor}, KeyID: "k1", Scopes: []Scope{ScopePostsRead}}
+	assert.True(t, author.Allows(ScopePostsWrite), "tokens are not scoped")
+	assert.True(t, key.Allows(ScopePostsRead))
+	assert.False(t, key.Allows(ScopePostsWrite))
+	assert.False(t, anonymous.Allows(ScopePostsRead))
+}
+
+func TestAPIKeys(t *testing.T) {
+	key := NewAPIKey()
+	assert.True(t, strings.HasPrefix(key, APIKeyPrefix))
+	assert.NotEqual(t, key, NewAPIKey())
+
+	assert.Equal(t, HashAPIKey(key), HashAPIKey(key))
+	assert.NotEqual(t, HashAPIKey(key), HashAPIKey(NewAPIKey()))
+	assert.NotContains(t, HashAPIKey(key), key[len(APIKeyPrefix):])
+}
+
+func TestPasswords(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	require.NoError(t, err)
+	assert.NotContains(t, hash, "correct horse")
+
+	assert.True(t, CheckPassword(hash, "correct horse battery staple"))
+	assert.False(t, CheckPassword(hash, "Correct horse battery staple"))
+	assert.False(t, CheckPassword("", ""))
+	assert.False(t, CheckPassword("not a hash", "correct horse battery staple"))
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordBytes is the longest password bcrypt hashes, in bytes rather
+// than characters.
+const MaxPasswordBytes = 72
+
+// HashPassword hashes password with bcrypt for storing. bcrypt only reads the
+// first 72 bytes, so longer passwords are refused.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password - %w", err)
+	}
+	return string(hash), nil
+}
+
+// dummyHash is checked against when there is no stored hash, so a login for
+// an unknown email takes as long as one with a wrong password.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("no such user"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// CheckPassword reports whether password matches hash. An empty hash never
+// matches, but takes as long to check as any other.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
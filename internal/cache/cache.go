@@ -0,0 +1,94 @@
+// Package cache keeps values that are costly to work out until what they
+// were worked out from changes.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Rebuilding keeps a value until Invalidate is told what it was built from
+// changed or it is older than maxAge, which catches changes made elsewhere,
+// like on another server. The value is kept for the key it was built for,
+// and asking for another key builds it afresh. A nil Rebuilding builds on
+// every call.
+type Rebuilding[T any] struct {
+	maxAge time.Duration
+
+	// building lets one build run at a time, and mu guards the rest, so
+	// Invalidate never waits for a build.
+	building sync.Mutex
+	mu       sync.Mutex
+	key      string
+	built    time.Time
+	value    T
+	ok       bool
+
+	// generation counts invalidations, so a build that one overtook is
+	// not kept.
+	generation int
+}
+
+// New returns a Rebuilding that builds its value at least every maxAge.
+func New[T any](maxAge time.Duration) *Rebuilding[T] {
+	return &Rebuilding[T]{maxAge: maxAge}
+}
+
+// Get returns the value for key, calling build for it unless the cache has
+// it already. Only one build runs at a time; callers arriving meanwhile wait
+// for it rather than starting their own.
+func (c *Rebuilding[T]) Get(key string, build func() (T, error)) (T, error) {
+	if c == nil {
+		return build()
+	}
+
+	if value, ok := c.cached(key); ok {
+		return value, nil
+	}
+
+	c.building.Lock()
+	defer c.building.Unlock()
+	if value, ok := c.cached(key); ok {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, err := build()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.key, c.built, c.value, c.ok = key, time.Now(), value, true
+	}
+	return value, nil
+}
+
+// cached returns the value for key if it is still fresh.
+func (c *Rebuilding[T]) cached(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ok && c.key == key && time.Since(c.built) < c.maxAge {
+		return c.value, true
+	}
+	var zero T
+	return zero, false
+}
+
+// Invalidate drops the value, for the next Get to build it again.
+func (c *Rebuilding[T]) Invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero T
+	c.value, c.ok = zero, false
+	c.generation++
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuilding(t *testing.T) {
+	builds := 0
+	build := func() (string, error) {
+		builds++
+		return fmt.Sprint(builds), nil
+	}
+
+	t.Run("Keeps what it built until invalidated", func(t *testing.T) {
+		c := New[string](time.Hour)
+		get := func(key string) string {
+			t.Helper()
+			value, err := c.Get(key, build)
+			require.NoError(t, err)
+			return value
+		}
+
+		builds = 0
+		assert.Equal(t, "1", get("https://a.example"))
+		assert.Equal(t, "1", get("https://a.example"))
+		c.Invalidate()
+		assert.Equal(t, "2", get("https://a.example"))
+		assert.Equal(t, "3", get("https://b.example"), "another key is built for")
+	})
+
+	t.Run("Rebuilds what is too old", func(t *testing.T) {
+		c := New[string](time.Nanosecond)
+		builds = 0
+		_, err := c.Get("", build)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+		_, err = c.Get("", build)
+		require.NoError(t, err)
+		assert.Equal(t, 2, builds)
+	})
+
+	t.Run("Drops a build overtaken by a change", func(t *testing.T) {
+		c := New[string](time.Hour)
+		builds = 0
+		_, err := c.Get("", func() (string, error) {
+			c.Invalidate()
+			return build()
+		})
+		require.NoError(t, err)
+		_, err = c.Get("", build)
+		require.NoError(t, err)
+		assert.Equal(t, 2, builds)
+	})
+
+	t.Run("Keeps zero values", func(t *testing.T) {
+		c := New[*int](time.Hour)
+		builds := 0
+		for range 2 {
+			_, err := c.Get("", func() (*int, error) {
+				builds++
+				return nil, nil
+			})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, builds)
+	})
+
+	t.Run("Errors are not kept", func(t *testing.T) {
+		c := New[string](time.Hour)
+		_, err := c.Get("", func() (string, error) { return "", fmt.Errorf("down") })
+		assert.Error(t, err)
+		builds = 0
+		_, err = c.Get("", build)
+		require.NoError(t, err)
+		assert.Equal(t, 1, builds)
+	})
+
+	t.Run("A nil cache builds every time", func(t *testing.T) {
+		var c *Rebuilding[string]
+		builds = 0
+		_, err := c.Get("", build)
+		require.NoError(t, err)
+		_, err = c.Get("", build)
+		require.NoError(t, err)
+		assert.Equal(t, 2, builds)
+		c.Invalidate()
+	})
+}
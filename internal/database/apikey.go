@@ -0,0 +1,114 @@
+package database
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/dto"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKey lets a machine client act for a user within Scopes, without logging
+// in. Only the hash of the key is stored; the key itself is shown once, when
+// it is created.
+type APIKey struct {
+	ID   primitive.ObjectID `bson:"_id" json:"id"`
+	Name string             `bson:"name" json:"name"`
+
+	// Prefix is the start of the key, enough to tell keys apart in a list.
+	Prefix     string       `bson:"prefix" json:"prefix"`
+	Hash       string       `bson:"hash" json:"-"`
+	UserID     string       `bson:"user_id" json:"userId"`
+	Scopes     []auth.Scope `bson:"scopes" json:"scopes"`
+	ExpiresAt  time.Time    `bson:"expires_at" json:"expiresAt"`
+	LastUsedAt *time.Time   `bson:"last_used_at,omitempty" json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time   `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt  time.Time    `bson:"created_at" json:"createdAt"`
+}
+
+// Active reports whether k may be used at t.
+func (k *APIKey) Active(t time.Time) bool {
+	return k.RevokedAt == nil && t.Before(k.ExpiresAt)
+}
+
+func newAPIKey(create dto.APIKeyCreateDTO) APIKey {
+	return APIKey{
+		ID:        primitive.NewObjectID(),
+		Name:      create.Name,
+		Prefix:    create.Prefix,
+		Hash:      create.Hash,
+		UserID:    create.UserID,
+		Scopes:    slices.Clone(create.Scopes),
+		ExpiresAt: create.ExpiresAt.UTC().Truncate(time.Millisecond),
+		CreatedAt: now(),
+	}
+}
+
+func (s *MongoUserRepository) CreateAPIKey(ctx context.Context, create dto.APIKeyCreateDTO) (*APIKey, error) {
+	key := newAPIKey(create)
+
+	if _, err := s.keys.InsertOne(ctx, key); err != nil {
+		return nil, fmt.Errorf("failed to insert api key - %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every key, revoked and expired ones included, newest
+// first.
+func (s *MongoUserRepository) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	cursor, err := s.keys.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys - %w", err)
+	}
+
+	keys := []*APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode api keys - %w", err)
+	}
+	return keys, nil
+}
+
+func (s *MongoUserRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	var key *APIKey
+	err := s.keys.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("cannot find api key - %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api key - %w", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey stops the key with id from being used. Revoking a key again
+// keeps when it was first revoked.
+func (s *MongoUserRepository) RevokeAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	return s.updateAPIKey(ctx, id, bson.M{"$min": bson.M{"revoked_at": now()}})
+}
+
+// TouchAPIKey records that the key with id was used at t.
+func (s *MongoUserRepository) TouchAPIKey(ctx context.Context, id string, t time.Time) error {
+	_, err := s.updateAPIKey(ctx, id, bson.M{"$max": bson.M{"last_used_at": t.UTC().Truncate(time.Millisecond)}})
+	return err
+}
+
+func (s *MongoUserRepository) updateAPIKey(ctx context.Context, id string, update bson.M) (*APIKey, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var key *APIKey
+	err = s.keys.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&key)
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+	return key, nil
+}
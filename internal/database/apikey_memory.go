@@ -0,0 +1,89 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *MemoryUserRepository) CreateAPIKey(ctx context.Context, create dto.APIKeyCreateDTO) (*APIKey, error) {
+	key := newAPIKey(create)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = &key
+	return cloneAPIKey(&key), nil
+}
+
+func (s *MemoryUserRepository) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, cloneAPIKey(key))
+	}
+	slices.SortFunc(keys, func(a, b *APIKey) int { return cmp.Compare(b.ID.Hex(), a.ID.Hex()) })
+	return keys, nil
+}
+
+func (s *MemoryUserRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			return cloneAPIKey(key), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot find api key - %w", ErrNotFound)
+}
+
+func (s *MemoryUserRepository) RevokeAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	return s.updateAPIKey(id, func(key *APIKey) {
+		if key.RevokedAt == nil {
+			revokedAt := now()
+			key.RevokedAt = &revokedAt
+		}
+	})
+}
+
+func (s *MemoryUserRepository) TouchAPIKey(ctx context.Context, id string, t time.Time) error {
+	_, err := s.updateAPIKey(id, func(key *APIKey) {
+		t = t.UTC().Truncate(time.Millisecond)
+		if key.LastUsedAt == nil || t.After(*key.LastUsedAt) {
+			key.LastUsedAt = &t
+		}
+	})
+	return err
+}
+
+// updateAPIKey applies change to the key with id under the write lock.
+func (s *MemoryUserRepository) updateAPIKey(id string, change func(*APIKey)) (*APIKey, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[objID]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	change(key)
+	return cloneAPIKey(key), nil
+}
+
+func cloneAPIKey(k *APIKey) *APIKey {
+	c := *k
+	c.Scopes = slices.Clone(k.Scopes)
+	return &c
+}
@@ -0,0 +1,398 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"blog-platform/internal/slug"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CategoryRepository stores the categories blogs are filed under. A blog
+// names its category by slug, so a category whose slug changes takes its
+// blogs along, and one that blogs are filed under cannot be deleted.
+type CategoryRepository interface {
+	CreateCategory(ctx context.Context, create dto.CategoryCreateDTO) (*Category, error)
+
+	// ListCategories returns every category, ordered by name.
+	ListCategories(ctx context.Context) ([]*Category, error)
+	GetCategory(ctx context.Context, id string) (*Category, error)
+	UpdateCategory(ctx context.Context, update dto.CategoryUpdateDTO) (*Category, error)
+	DeleteCategory(ctx context.Context, id string) (*Category, error)
+}
+
+// Category groups blogs by subject. Categories nest: listing the blogs of a
+// category lists those of every category below it too. Names are unique
+// whatever their case, as are slugs.
+type Category struct {
+	ID          primitive.ObjectID  `bson:"_id" json:"id"`
+	Name        string              `bson:"name" json:"name"`
+	NameKey     string              `bson:"name_key" json:"-"`
+	Slug        string              `bson:"slug" json:"slug"`
+	Description string              `bson:"description,omitempty" json:"description"`
+	ParentID    *primitive.ObjectID `bson:"parent_id,omitempty" json:"parentId,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time           `bson:"updated_at" json:"updatedAt"`
+}
+
+// nameKey is what category names are compared by, so "Go" and "GO" are the
+// same category.
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func newCategory(create dto.CategoryCreateDTO) (Category, error) {
+	createdAt := now()
+	category := Category{
+		ID:          primitive.NewObjectID(),
+		Name:        strings.TrimSpace(create.Name),
+		NameKey:     nameKey(create.Name),
+		Slug:        create.Slug,
+		Description: create.Description,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+	if category.Slug == "" {
+		category.Slug = slug.Make(create.Name)
+	}
+
+	if create.ParentID != "" {
+		parent, err := primitive.ObjectIDFromHex(create.ParentID)
+		if err != nil {
+			return Category{}, fmt.Errorf("cannot parse id %v - %w", create.ParentID, ErrInvalidID)
+		}
+		category.ParentID = &parent
+	}
+	return category, nil
+}
+
+// applyCategoryUpdate changes c as update asks, checking a new parent
+// against the other categories.
+func applyCategoryUpdate(c *Category, update dto.CategoryUpdateDTO, categories []*Category) error {
+	if update.Name != nil {
+		c.Name = strings.TrimSpace(*update.Name)
+		c.NameKey = nameKey(*update.Name)
+	}
+	if update.Slug != nil {
+		c.Slug = *update.Slug
+	}
+	if update.Description != nil {
+		c.Description = *update.Description
+	}
+	if update.ParentID != nil {
+		c.ParentID = nil
+		if *update.ParentID != "" {
+			parent, err := primitive.ObjectIDFromHex(*update.ParentID)
+			if err != nil {
+				return fmt.Errorf("cannot parse id %v - %w", *update.ParentID, ErrInvalidID)
+			}
+			if err := checkParent(categories, c.ID, parent); err != nil {
+				return err
+			}
+			c.ParentID = &parent
+		}
+	}
+	return nil
+}
+
+// noCategoryFields reports whether update changes nothing.
+func noCategoryFields(update dto.CategoryUpdateDTO) bool {
+	return update.Name == nil && update.Slug == nil && update.Description == nil && update.ParentID == nil
+}
+
+// checkParent makes sure the category with id may move below parent: parent
+// has to exist and must not be the category itself or below it.
+func checkParent(categories []*Category, id, parent primitive.ObjectID) error {
+	byID := make(map[primitive.ObjectID]*Category, len(categories))
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+	if _, ok := byID[parent]; !ok {
+		return fmt.Errorf("cannot find id %v - %w", parent.Hex(), ErrNotFound)
+	}
+
+	// Stored categories hold no cycles, but the walk is bounded anyway.
+	ancestor := byID[parent]
+	for range len(categories) {
+		if ancestor.ID == id {
+			return fmt.Errorf("category %v cannot be below itself - %w", id.Hex(), ErrConflict)
+		}
+		if ancestor.ParentID == nil || byID[*ancestor.ParentID] == nil {
+			break
+		}
+		ancestor = byID[*ancestor.ParentID]
+	}
+	return nil
+}
+
+// FindCategory returns the category ref names by its slug or its name, in
+// any case, or nil when it names none.
+func FindCategory(categories []*Category, ref string) *Category {
+	for _, c := range categories {
+		if c.Slug == ref {
+			return c
+		}
+	}
+	refSlug, refKey := slug.Make(ref), nameKey(ref)
+	for _, c := range categories {
+		if c.Slug == refSlug || c.NameKey == refKey {
+			return c
+		}
+	}
+	return nil
+}
+
+// categorySubtree returns the slugs of the category ref names and of every
+// category below it. A ref that names no category only matches itself, as
+// categories did before they were managed.
+func categorySubtree(categories []*Category, ref string) []string {
+	root := FindCategory(categories, ref)
+	if root == nil {
+		return []string{ref}
+	}
+
+	slugs := []string{root.Slug}
+	for below := []primitive.ObjectID{root.ID}; len(below) > 0; {
+		var next []primitive.ObjectID
+		for _, c := range categories {
+			if c.ParentID != nil && slices.Contains(below, *c.ParentID) {
+				slugs = append(slugs, c.Slug)
+				next = append(next, c.ID)
+			}
+		}
+		below = next
+	}
+	return slugs
+}
+
+func sortCategories(categories []*Category) {
+	slices.SortFunc(categories, func(a, b *Category) int {
+		return strings.Compare(a.NameKey, b.NameKey)
+	})
+}
+
+func (s *MongoBlogRepository) CreateCategory(ctx context.Context, create dto.CategoryCreateDTO) (*Category, error) {
+	category, err := newCategory(create)
+	if err != nil {
+		return nil, err
+	}
+
+	if category.ParentID != nil {
+		count, err := s.categories.CountDocuments(ctx, bson.M{"_id": *category.ParentID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query id %v - %w", create.ParentID, err)
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("cannot find id %v - %w", create.ParentID, ErrNotFound)
+		}
+	}
+
+	if _, err := s.categories.InsertOne(ctx, category); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("category %v is taken - %w", category.Slug, ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to insert category - %w", err)
+	}
+	return &category, nil
+}
+
+func (s *MongoBlogRepository) ListCategories(ctx context.Context) ([]*Category, error) {
+	cur, err := s.categories.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name_key", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find categories - %w", err)
+	}
+
+	categories := []*Category{}
+	if err := cur.All(ctx, &categories); err != nil {
+		return nil, fmt.Errorf("failed to decode categories - %w", err)
+	}
+	return categories, nil
+}
+
+func (s *MongoBlogRepository) GetCategory(ctx context.Context, id string) (*Category, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var category *Category
+	if err := s.categories.FindOne(ctx, bson.M{"_id": objID}).Decode(&category); err != nil {
+		return nil, notFound(id, err)
+	}
+	return category, nil
+}
+
+// UpdateCategory changes a category. A new slug is written to every blog
+// filed under the old one, including those in the trash. Those blogs count
+// as updated but get no revision, as their authors did not change them.
+func (s *MongoBlogRepository) UpdateCategory(ctx context.Context, update dto.CategoryUpdateDTO) (*Category, error) {
+	if noCategoryFields(update) {
+		return nil, fmt.Errorf("cannot update id %v - %w", update.Id, ErrNoFieldsToUpdate)
+	}
+
+	categories, err := s.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.GetCategory(ctx, update.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *before
+	if err := applyCategoryUpdate(&updated, update, categories); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = now()
+
+	_, err = s.categories.ReplaceOne(ctx, bson.M{"_id": updated.ID}, updated)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("category %v is taken - %w", updated.Slug, ErrConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update category %v - %w", update.Id, err)
+	}
+
+	if updated.Slug != before.Slug {
+		if err := s.refileBlogs(ctx, before.Slug, updated.Slug); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
+
+// refileBlogs moves every blog filed under the category slug from to to.
+// The category is part of what search finds a blog by, so its words are
+// worked out again.
+func (s *MongoBlogRepository) refileBlogs(ctx context.Context, from, to string) error {
+	cur, err := s.collection.Find(ctx, bson.M{"category": from})
+	if err != nil {
+		return fmt.Errorf("failed to find blogs in category %v - %w", from, err)
+	}
+
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return err
+	}
+
+	updatedAt := now()
+	for _, b := range blogs {
+		b.Category = to
+		_, err := s.collection.UpdateOne(ctx,
+			bson.M{"_id": b.ID, "category": from},
+			bson.M{
+				"$set": bson.M{"category": to, "search_terms": searchTerms(b), "updated_at": updatedAt},
+				"$inc": bson.M{"version": 1},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to refile blog %v - %w", b.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category nothing is filed under: no blog, even in
+// the trash, and no other category.
+func (s *MongoBlogRepository) DeleteCategory(ctx context.Context, id string) (*Category, error) {
+	category, err := s.GetCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.categories.CountDocuments(ctx, bson.M{"parent_id": category.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query id %v - %w", id, err)
+	}
+	if children > 0 {
+		return nil, fmt.Errorf("category %v has categories below it - %w", id, ErrConflict)
+	}
+
+	blogs, err := s.collection.CountDocuments(ctx, bson.M{"category": category.Slug})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query id %v - %w", id, err)
+	}
+	if blogs > 0 {
+		return nil, fmt.Errorf("category %v has blogs - %w", id, ErrConflict)
+	}
+
+	if _, err := s.categories.DeleteOne(ctx, bson.M{"_id": category.ID}); err != nil {
+		return nil, fmt.Errorf("failed to delete category %v - %w", id, err)
+	}
+	return category, nil
+}
+
+// categorySubtree returns the slugs listing the blogs of the category ref
+// matches.
+func (s *MongoBlogRepository) categorySubtree(ctx context.Context, ref string) ([]string, error) {
+	if ref == "" {
+		return nil, nil
+	}
+
+	categories, err := s.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return categorySubtree(categories, ref), nil
+}
+
+// backfillCategories turns the categories blogs were filed under before
+// categories were managed into categories of their own, named as the first
+// blog spelled them. Spellings with the same slug, like "Go" and "go", become
+// one category.
+func (s *MongoBlogRepository) backfillCategories(ctx context.Context) error {
+	names, err := s.collection.Distinct(ctx, "category", bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to find blog categories - %w", err)
+	}
+
+	for _, value := range names {
+		name, ok := value.(string)
+		if !ok || strings.TrimSpace(name) == "" {
+			continue
+		}
+		err := s.categories.FindOne(ctx, bson.M{"slug": name}).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("failed to query category %v - %w", name, err)
+		}
+
+		category, err := newCategory(dto.CategoryCreateDTO{Name: name})
+		if err != nil {
+			return err
+		}
+		_, err = s.categories.UpdateOne(ctx,
+			bson.M{"slug": category.Slug},
+			bson.M{"$setOnInsert": category},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			// Another spelling took the name with a different slug, so
+			// this one gets its own.
+			category.Name = fmt.Sprintf("%s (%s)", name, category.Slug)
+			category.NameKey = nameKey(category.Name)
+			_, err = s.categories.InsertOne(ctx, category)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create category %v - %w", name, err)
+		}
+
+		if name == category.Slug {
+			continue
+		}
+		if err := s.refileBlogs(ctx, name, category.Slug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *MemoryBlogRepository) CreateCategory(ctx context.Context, create dto.CategoryCreateDTO) (*Category, error) {
+	category, err := newCategory(create)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if category.ParentID != nil {
+		if _, ok := s.categories[*category.ParentID]; !ok {
+			return nil, fmt.Errorf("cannot find id %v - %w", create.ParentID, ErrNotFound)
+		}
+	}
+	if err := s.categoryTaken(&category); err != nil {
+		return nil, err
+	}
+
+	s.categories[category.ID] = &category
+	return cloneCategory(&category), nil
+}
+
+func (s *MemoryBlogRepository) ListCategories(ctx context.Context) ([]*Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := s.categoryList()
+	for i, c := range categories {
+		categories[i] = cloneCategory(c)
+	}
+	return categories, nil
+}
+
+func (s *MemoryBlogRepository) GetCategory(ctx context.Context, id string) (*Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	category, err := s.category(id)
+	if err != nil {
+		return nil, err
+	}
+	return cloneCategory(category), nil
+}
+
+func (s *MemoryBlogRepository) UpdateCategory(ctx context.Context, update dto.CategoryUpdateDTO) (*Category, error) {
+	if noCategoryFields(update) {
+		return nil, fmt.Errorf("cannot update id %v - %w", update.Id, ErrNoFieldsToUpdate)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, err := s.category(update.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *category
+	if err := applyCategoryUpdate(&updated, update, s.categoryList()); err != nil {
+		return nil, err
+	}
+	if err := s.categoryTaken(&updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = now()
+
+	if updated.Slug != category.Slug {
+		for _, b := range s.blogs {
+			if b.Category == category.Slug {
+				b.Category = updated.Slug
+				b.UpdatedAt = updated.UpdatedAt
+				b.Version++
+			}
+		}
+	}
+	*category = updated
+	return cloneCategory(category), nil
+}
+
+func (s *MemoryBlogRepository) DeleteCategory(ctx context.Context, id string) (*Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, err := s.category(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range s.categories {
+		if c.ParentID != nil && *c.ParentID == category.ID {
+			return nil, fmt.Errorf("category %v has categories below it - %w", id, ErrConflict)
+		}
+	}
+	for _, b := range s.blogs {
+		if b.Category == category.Slug {
+			return nil, fmt.Errorf("category %v has blogs - %w", id, ErrConflict)
+		}
+	}
+
+	delete(s.categories, category.ID)
+	return cloneCategory(category), nil
+}
+
+// category returns the stored category with id. Callers hold the lock.
+func (s *MemoryBlogRepository) category(id string) (*Category, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	category, ok := s.categories[objID]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	return category, nil
+}
+
+// categoryList returns the stored categories ordered by name. Callers hold
+// the lock.
+func (s *MemoryBlogRepository) categoryList() []*Category {
+	categories := make([]*Category, 0, len(s.categories))
+	for _, c := range s.categories {
+		categories = append(categories, c)
+	}
+	sortCategories(categories)
+	return categories
+}
+
+// categoryTaken fails with ErrConflict when another category has the name or
+// slug of c, as Mongo's unique indexes do. Callers hold the lock.
+func (s *MemoryBlogRepository) categoryTaken(c *Category) error {
+	for _, other := range s.categories {
+		if other.ID != c.ID && (other.Slug == c.Slug || other.NameKey == c.NameKey) {
+			return fmt.Errorf("category %v is taken - %w", c.Slug, ErrConflict)
+		}
+	}
+	return nil
+}
+
+func cloneCategory(c *Category) *Category {
+	clone := *c
+	if c.ParentID != nil {
+		parent := *c.ParentID
+		clone.ParentID = &parent
+	}
+	return &clone
+}
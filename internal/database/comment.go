@@ -0,0 +1,480 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CommentRepository stores the comments on blogs. Comments belong to their
+// blog: they are hidden while it is in the trash and purged along with it.
+type CommentRepository interface {
+	CreateComment(ctx context.Context, create dto.CommentCreateDTO) (*Comment, error)
+	GetComment(ctx context.Context, id string) (*Comment, error)
+	ListComments(ctx context.Context, opts CommentListOptions) (*CommentPage, error)
+	UpdateComment(ctx context.Context, update dto.CommentUpdateDTO) (*Comment, error)
+	DeleteComment(ctx context.Context, id string) (*Comment, error)
+
+	// ModerationQueue lists the pending comments, oldest first.
+	ModerationQueue(ctx context.Context, limit int) (*CommentPage, error)
+
+	// ModerateComments gives the comments named in moderation its status and
+	// returns them as they were and as they are now. Ids that name no
+	// comment, or a deleted one, are skipped.
+	ModerateComments(ctx context.Context, moderation dto.CommentModerationDTO) ([]*ModeratedComment, error)
+
+	// ListModeratedComments returns every comment a moderator decided on and
+	// nobody deleted since, for a SpamScorer to learn from.
+	ListModeratedComments(ctx context.Context) ([]*Comment, error)
+}
+
+// Comment is a reader's response to a blog, or with a ParentID, to another
+// comment on it. Deleting a comment blanks it rather than removing it, so the
+// replies to it keep their place in the thread. Only approved comments are
+// shown; comments stored before moderation have no Status and count as
+// approved.
+type Comment struct {
+	ID         primitive.ObjectID  `bson:"_id" json:"id"`
+	BlogID     primitive.ObjectID  `bson:"blog_id" json:"postId"`
+	ParentID   *primitive.ObjectID `bson:"parent_id,omitempty" json:"parentId,omitempty"`
+	AuthorID   string              `bson:"author_id,omitempty" json:"authorId,omitempty"`
+	AuthorName string              `bson:"author_name,omitempty" json:"authorName,omitempty"`
+	Body       string              `bson:"body" json:"body"`
+	CreatedAt  time.Time           `bson:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time           `bson:"updated_at" json:"updatedAt"`
+	DeletedAt  *time.Time          `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"`
+
+	Status      CommentStatus `bson:"status,omitempty" json:"status"`
+	SpamScore   float64       `bson:"spam_score,omitempty" json:"spamScore,omitempty"`
+	ModeratedBy string        `bson:"moderated_by,omitempty" json:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time    `bson:"moderated_at,omitempty" json:"moderatedAt,omitempty"`
+
+	// Replies are filled in by ListComments, oldest first.
+	Replies []*Comment `bson:"-" json:"replies,omitempty"`
+}
+
+// ModeratedComment is a comment a moderator just decided on, Before and
+// After the decision.
+type ModeratedComment struct {
+	Before, After *Comment
+}
+
+// Public reports whether c may be shown to readers.
+func (c *Comment) Public() bool {
+	return c.DeletedAt == nil && (c.Status == "" || c.Status == CommentApproved)
+}
+
+type CommentStatus string
+
+const (
+	// CommentPending comments wait for a moderator before they are shown.
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentRejected CommentStatus = "rejected"
+	CommentSpam     CommentStatus = "spam"
+)
+
+type CommentSort string
+
+const (
+	CommentsNewest CommentSort = "newest"
+	CommentsOldest CommentSort = "oldest"
+
+	// CommentsTop puts the threads with the most replies first.
+	CommentsTop CommentSort = "top"
+)
+
+// CommentListOptions selects one page of the threads on a blog. The zero
+// value of everything but BlogID lists the newest DefaultLimit threads.
+type CommentListOptions struct {
+	BlogID string
+	Sort   CommentSort
+	Limit  int
+	Cursor string
+}
+
+// CommentPage is one page of threads: comments on the blog itself, each with
+// its replies nested inside. Total counts every thread, not just this page.
+type CommentPage struct {
+	Items      []*Comment `json:"items"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	Total      int64      `json:"total"`
+}
+
+func (o CommentListOptions) normalize() (CommentListOptions, error) {
+	switch {
+	case o.Limit <= 0:
+		o.Limit = DefaultLimit
+	case o.Limit > MaxLimit:
+		o.Limit = MaxLimit
+	}
+
+	switch o.Sort {
+	case "":
+		o.Sort = CommentsNewest
+	case CommentsNewest, CommentsOldest, CommentsTop:
+	default:
+		return o, fmt.Errorf("unknown comment sort %q", o.Sort)
+	}
+
+	return o, nil
+}
+
+func newComment(create dto.CommentCreateDTO) (Comment, error) {
+	blogID, err := primitive.ObjectIDFromHex(create.BlogID)
+	if err != nil {
+		return Comment{}, fmt.Errorf("cannot parse id %v - %w", create.BlogID, ErrInvalidID)
+	}
+
+	createdAt := now()
+	comment := Comment{
+		ID:         primitive.NewObjectID(),
+		BlogID:     blogID,
+		AuthorID:   create.AuthorID,
+		AuthorName: create.AuthorName,
+		Body:       create.Body,
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+		Status:     CommentStatus(create.Status),
+		SpamScore:  create.SpamScore,
+	}
+	if comment.Status == "" {
+		comment.Status = CommentApproved
+	}
+
+	if create.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(create.ParentID)
+		if err != nil {
+			return Comment{}, fmt.Errorf("cannot parse id %v - %w", create.ParentID, ErrInvalidID)
+		}
+		comment.ParentID = &parentID
+	}
+
+	return comment, nil
+}
+
+// commentCursor marks the last thread of a page, for the sort it was issued
+// for.
+type commentCursor struct {
+	Sort CommentSort `json:"s"`
+	ID   string      `json:"id"`
+}
+
+// threadComments nests comments, every comment on one blog, into threads and
+// returns the page of them opts selects. Comments readers may not see are
+// dropped unless somebody replied to them, in which case they stay as blank
+// placeholders.
+func threadComments(comments []*Comment, opts CommentListOptions) (*CommentPage, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(map[primitive.ObjectID][]*Comment)
+	var threads []*Comment
+	for _, c := range comments {
+		if c.ParentID == nil {
+			threads = append(threads, c)
+		} else {
+			replies[*c.ParentID] = append(replies[*c.ParentID], c)
+		}
+	}
+
+	// nest fills in the replies to c and returns how many it has, however
+	// deep, or -1 when c is hidden and has none left to show.
+	var nest func(c *Comment) int
+	nest = func(c *Comment) int {
+		count := 0
+		c.Replies = nil
+		for _, reply := range replies[c.ID] {
+			if n := nest(reply); n >= 0 {
+				c.Replies = append(c.Replies, reply)
+				count += n + 1
+			}
+		}
+		if !c.Public() {
+			if len(c.Replies) == 0 {
+				return -1
+			}
+			c.AuthorID, c.AuthorName, c.Body = "", "", ""
+		}
+		slices.SortFunc(c.Replies, func(a, b *Comment) int { return compareComments(a, b, CommentsOldest, nil) })
+		return count
+	}
+
+	counts := make(map[primitive.ObjectID]int)
+	threads = slices.DeleteFunc(threads, func(c *Comment) bool {
+		counts[c.ID] = nest(c)
+		return counts[c.ID] < 0
+	})
+	slices.SortFunc(threads, func(a, b *Comment) int { return compareComments(a, b, opts.Sort, counts) })
+
+	start := 0
+	if opts.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode cursor - %w", ErrInvalidCursor)
+		}
+		var c commentCursor
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, fmt.Errorf("cannot decode cursor - %w", ErrInvalidCursor)
+		}
+		if c.Sort != opts.Sort {
+			return nil, fmt.Errorf("cursor was issued for another sort - %w", ErrInvalidCursor)
+		}
+		last := slices.IndexFunc(threads, func(t *Comment) bool { return t.ID.Hex() == c.ID })
+		if last < 0 {
+			return nil, fmt.Errorf("cursor thread is gone - %w", ErrInvalidCursor)
+		}
+		start = last + 1
+	}
+
+	page := &CommentPage{Items: threads[start:], Total: int64(len(threads))}
+	if len(page.Items) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		raw, _ := json.Marshal(commentCursor{Sort: opts.Sort, ID: page.Items[opts.Limit-1].ID.Hex()})
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+	}
+	if page.Items == nil {
+		page.Items = []*Comment{}
+	}
+	return page, nil
+}
+
+// compareComments orders a before b by sort. counts, the replies each thread
+// has, is only needed for CommentsTop. Ties are broken by id, so the order is
+// stable across pages.
+func compareComments(a, b *Comment, sort CommentSort, counts map[primitive.ObjectID]int) int {
+	switch sort {
+	case CommentsOldest:
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID.Hex(), b.ID.Hex()))
+	case CommentsTop:
+		if c := cmp.Compare(counts[b.ID], counts[a.ID]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ID.Hex(), a.ID.Hex()))
+}
+
+// blank turns c into what is left of it once it is deleted at at.
+func blank(c *Comment, at time.Time) {
+	c.AuthorID = ""
+	c.AuthorName = ""
+	c.Body = ""
+	c.DeletedAt = &at
+}
+
+// moderationQueue returns the page of pending comments limit asks for, oldest
+// first.
+func moderationQueue(pending []*Comment, limit int) *CommentPage {
+	opts, _ := CommentListOptions{Limit: limit}.normalize()
+	slices.SortFunc(pending, func(a, b *Comment) int { return compareComments(a, b, CommentsOldest, nil) })
+
+	page := &CommentPage{Items: pending, Total: int64(len(pending))}
+	if len(page.Items) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+	}
+	if page.Items == nil {
+		page.Items = []*Comment{}
+	}
+	return page
+}
+
+// liveBlog fails with ErrNotFound unless the blog with id exists outside the
+// trash.
+func (s *MongoBlogRepository) liveBlog(ctx context.Context, id primitive.ObjectID) error {
+	count, err := s.collection.CountDocuments(ctx, bson.M{"_id": id, "deleted_at": notTrashed})
+	if err != nil {
+		return fmt.Errorf("failed to query id %v - %w", id.Hex(), err)
+	}
+	if count == 0 {
+		return fmt.Errorf("cannot find id %v - %w", id.Hex(), ErrNotFound)
+	}
+	return nil
+}
+
+// CreateComment adds a comment to a blog. A reply has to answer a comment on
+// the same blog that readers can see.
+func (s *MongoBlogRepository) CreateComment(ctx context.Context, create dto.CommentCreateDTO) (*Comment, error) {
+	comment, err := newComment(create)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.liveBlog(ctx, comment.BlogID); err != nil {
+		return nil, err
+	}
+
+	if comment.ParentID != nil {
+		filter := bson.M{
+			"_id":        *comment.ParentID,
+			"blog_id":    comment.BlogID,
+			"deleted_at": bson.M{"$exists": false},
+			"status":     bson.M{"$in": bson.A{nil, CommentApproved}},
+		}
+		if err := s.comments.FindOne(ctx, filter).Err(); err != nil {
+			return nil, notFound(comment.ParentID.Hex(), err)
+		}
+	}
+
+	if _, err := s.comments.InsertOne(ctx, comment); err != nil {
+		return nil, fmt.Errorf("failed to insert comment - %w", err)
+	}
+	return &comment, nil
+}
+
+func (s *MongoBlogRepository) GetComment(ctx context.Context, id string) (*Comment, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var comment *Comment
+	if err := s.comments.FindOne(ctx, bson.M{"_id": objID}).Decode(&comment); err != nil {
+		return nil, notFound(id, err)
+	}
+
+	if err := s.liveBlog(ctx, comment.BlogID); err != nil {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, err)
+	}
+	return comment, nil
+}
+
+// ListComments reads every comment on the blog and threads them in memory,
+// since a page of threads needs their replies however deep they go.
+func (s *MongoBlogRepository) ListComments(ctx context.Context, opts CommentListOptions) (*CommentPage, error) {
+	blogID, err := primitive.ObjectIDFromHex(opts.BlogID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", opts.BlogID, ErrInvalidID)
+	}
+
+	if err := s.liveBlog(ctx, blogID); err != nil {
+		return nil, err
+	}
+
+	cur, err := s.comments.Find(ctx, bson.M{"blog_id": blogID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments - %w", err)
+	}
+
+	var comments []*Comment
+	if err := cur.All(ctx, &comments); err != nil {
+		return nil, fmt.Errorf("failed to decode comments - %w", err)
+	}
+
+	return threadComments(comments, opts)
+}
+
+func (s *MongoBlogRepository) UpdateComment(ctx context.Context, update dto.CommentUpdateDTO) (*Comment, error) {
+	set := bson.M{"body": update.Body, "updated_at": now()}
+	if update.Status != "" {
+		set["status"] = update.Status
+		set["spam_score"] = update.SpamScore
+	}
+	return s.updateComment(ctx, update.Id, bson.M{"$set": set}, options.After)
+}
+
+// DeleteComment blanks the comment with id, see Comment.
+func (s *MongoBlogRepository) DeleteComment(ctx context.Context, id string) (*Comment, error) {
+	deletedAt := now()
+	return s.updateComment(ctx, id, bson.M{
+		"$set":   bson.M{"body": "", "deleted_at": deletedAt, "updated_at": deletedAt},
+		"$unset": bson.M{"author_id": "", "author_name": ""},
+	}, options.After)
+}
+
+// ModerationQueue leaves out the comments on blogs in the trash, which
+// nobody can see whatever a moderator decides.
+func (s *MongoBlogRepository) ModerationQueue(ctx context.Context, limit int) (*CommentPage, error) {
+	trashed, err := s.collection.Distinct(ctx, "_id", bson.M{"deleted_at": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash - %w", err)
+	}
+
+	cur, err := s.comments.Find(ctx, bson.M{
+		"status":     CommentPending,
+		"deleted_at": bson.M{"$exists": false},
+		"blog_id":    bson.M{"$nin": trashed},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments - %w", err)
+	}
+
+	var pending []*Comment
+	if err := cur.All(ctx, &pending); err != nil {
+		return nil, fmt.Errorf("failed to decode comments - %w", err)
+	}
+	return moderationQueue(pending, limit), nil
+}
+
+func (s *MongoBlogRepository) ModerateComments(ctx context.Context, moderation dto.CommentModerationDTO) ([]*ModeratedComment, error) {
+	moderatedAt := now()
+	moderated := []*ModeratedComment{}
+	for _, id := range moderation.Ids {
+		before, err := s.updateComment(ctx, id, bson.M{"$set": bson.M{
+			"status":       moderation.Status,
+			"moderated_by": moderation.Moderator,
+			"moderated_at": moderatedAt,
+		}}, options.Before)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidID) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		after := *before
+		after.Status = CommentStatus(moderation.Status)
+		after.ModeratedBy = moderation.Moderator
+		after.ModeratedAt = &moderatedAt
+		moderated = append(moderated, &ModeratedComment{Before: before, After: &after})
+	}
+	return moderated, nil
+}
+
+func (s *MongoBlogRepository) ListModeratedComments(ctx context.Context) ([]*Comment, error) {
+	cur, err := s.comments.Find(ctx, bson.M{
+		"moderated_at": bson.M{"$exists": true},
+		"deleted_at":   bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments - %w", err)
+	}
+
+	var comments []*Comment
+	if err := cur.All(ctx, &comments); err != nil {
+		return nil, fmt.Errorf("failed to decode comments - %w", err)
+	}
+	return comments, nil
+}
+
+// updateComment applies update to the comment with id, unless it was deleted
+// or its blog is in the trash, and returns the comment as returnDocument
+// says: as it was before or as it is after.
+func (s *MongoBlogRepository) updateComment(ctx context.Context, id string, update bson.M, returnDocument options.ReturnDocument) (*Comment, error) {
+	if _, err := s.GetComment(ctx, id); err != nil {
+		return nil, err
+	}
+	objID, _ := primitive.ObjectIDFromHex(id)
+
+	var comment *Comment
+	err := s.comments.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(returnDocument),
+	).Decode(&comment)
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+	return comment, nil
+}
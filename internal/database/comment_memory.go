@@ -0,0 +1,174 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *MemoryBlogRepository) CreateComment(ctx context.Context, create dto.CommentCreateDTO) (*Comment, error) {
+	comment, err := newComment(create)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.live(comment.BlogID); !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", create.BlogID, ErrNotFound)
+	}
+	if comment.ParentID != nil {
+		parent, ok := s.comments[*comment.ParentID]
+		if !ok || parent.BlogID != comment.BlogID || !parent.Public() {
+			return nil, fmt.Errorf("cannot find id %v - %w", create.ParentID, ErrNotFound)
+		}
+	}
+
+	s.comments[comment.ID] = &comment
+	return cloneComment(&comment), nil
+}
+
+func (s *MemoryBlogRepository) GetComment(ctx context.Context, id string) (*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, err := s.comment(id)
+	if err != nil {
+		return nil, err
+	}
+	return cloneComment(comment), nil
+}
+
+func (s *MemoryBlogRepository) ListComments(ctx context.Context, opts CommentListOptions) (*CommentPage, error) {
+	blogID, err := primitive.ObjectIDFromHex(opts.BlogID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", opts.BlogID, ErrInvalidID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.live(blogID); !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", opts.BlogID, ErrNotFound)
+	}
+
+	var comments []*Comment
+	for _, c := range s.comments {
+		if c.BlogID == blogID {
+			comments = append(comments, cloneComment(c))
+		}
+	}
+	return threadComments(comments, opts)
+}
+
+func (s *MemoryBlogRepository) UpdateComment(ctx context.Context, update dto.CommentUpdateDTO) (*Comment, error) {
+	return s.updateComment(update.Id, func(c *Comment) {
+		c.Body = update.Body
+		c.UpdatedAt = now()
+		if update.Status != "" {
+			c.Status = CommentStatus(update.Status)
+			c.SpamScore = update.SpamScore
+		}
+	})
+}
+
+func (s *MemoryBlogRepository) DeleteComment(ctx context.Context, id string) (*Comment, error) {
+	return s.updateComment(id, func(c *Comment) {
+		deletedAt := now()
+		blank(c, deletedAt)
+		c.UpdatedAt = deletedAt
+	})
+}
+
+func (s *MemoryBlogRepository) ModerationQueue(ctx context.Context, limit int) (*CommentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []*Comment
+	for _, c := range s.comments {
+		if _, ok := s.live(c.BlogID); ok && c.Status == CommentPending && c.DeletedAt == nil {
+			pending = append(pending, cloneComment(c))
+		}
+	}
+	return moderationQueue(pending, limit), nil
+}
+
+func (s *MemoryBlogRepository) ModerateComments(ctx context.Context, moderation dto.CommentModerationDTO) ([]*ModeratedComment, error) {
+	moderatedAt := now()
+	moderated := []*ModeratedComment{}
+	for _, id := range moderation.Ids {
+		var before *Comment
+		after, err := s.updateComment(id, func(c *Comment) {
+			before = cloneComment(c)
+			c.Status = CommentStatus(moderation.Status)
+			c.ModeratedBy = moderation.Moderator
+			c.ModeratedAt = &moderatedAt
+		})
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidID) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		moderated = append(moderated, &ModeratedComment{Before: before, After: after})
+	}
+	return moderated, nil
+}
+
+func (s *MemoryBlogRepository) ListModeratedComments(ctx context.Context) ([]*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var comments []*Comment
+	for _, c := range s.comments {
+		if c.ModeratedAt != nil && c.DeletedAt == nil {
+			comments = append(comments, cloneComment(c))
+		}
+	}
+	return comments, nil
+}
+
+// updateComment applies change to the comment with id under the write lock,
+// unless it was deleted.
+func (s *MemoryBlogRepository) updateComment(id string, change func(*Comment)) (*Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, err := s.comment(id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.DeletedAt != nil {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	change(comment)
+	return cloneComment(comment), nil
+}
+
+// comment returns the comment with id unless it is missing or its blog is in
+// the trash. The caller must hold the lock.
+func (s *MemoryBlogRepository) comment(id string) (*Comment, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	comment, ok := s.comments[objID]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	if _, ok := s.live(comment.BlogID); !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	return comment, nil
+}
+
+func cloneComment(c *Comment) *Comment {
+	clone := *c
+	clone.Replies = nil
+	return &clone
+}
@@ -2,9 +2,14 @@ package database
 
 import (
 	"blog-platform/internal/dto"
+	"blog-platform/internal/render"
+	"blog-platform/internal/search"
+	"blog-platform/internal/slug"
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -16,17 +21,30 @@ import (
 
 type BlogRepository interface {
 	Health(ctx context.Context) error
-	GetBlogs(ctx context.Context) ([]*Blog, error)
+	ListBlogs(ctx context.Context, opts ListOptions) (*BlogPage, error)
 	GetBlog(ctx context.Context, id string) (*Blog, error)
+	GetBlogBySlug(ctx context.Context, slug string) (*Blog, error)
 	CreateBlog(ctx context.Context, create dto.BlogCreateDto) (*string, error)
 	UpdateBlog(ctx context.Context, update dto.BlogUpdateDTO) (*Blog, error)
-	DeleteBlog(ctx context.Context, id string) (*Blog, error)
-	GetBlogsByTerm(ctx context.Context, term string) ([]*Blog, error)
+	DeleteBlog(ctx context.Context, del dto.BlogDeleteDTO) (*Blog, error)
+	RestoreBlog(ctx context.Context, id string) (*Blog, error)
+	PurgeBlog(ctx context.Context, id string) (*Blog, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+	SearchBlogs(ctx context.Context, opts SearchOptions) (*SearchResult, error)
+	SetBlogStatus(ctx context.Context, change dto.BlogStatusDTO) (*Blog, error)
+	ListRevisions(ctx context.Context, id string) ([]*RevisionSummary, error)
+	GetRevision(ctx context.Context, id string, number int) (*Revision, error)
+	PublishDue(ctx context.Context, now time.Time) (int64, error)
 }
 
 type MongoBlogRepository struct {
 	client     *mongo.Client
 	collection *mongo.Collection
+	slugs      *mongo.Collection
+	revisions  *mongo.Collection
+	comments   *mongo.Collection
+	categories *mongo.Collection
+	media      *mongo.Collection
 }
 
 type Settings struct {
@@ -38,14 +56,128 @@ type Settings struct {
 	DbName     string
 }
 
+// searchableBlog is how a blog is stored: the blog itself plus the words
+// SearchBlogs finds it by.
+type searchableBlog struct {
+	Blog        `bson:",inline"`
+	SearchTerms []string `bson:"search_terms"`
+}
+
+// Blog is a post. Its Slug is generated from the title when the blog is
+// created and only changes when an update asks for a new one, so links to a
+// blog survive edits to its title.
 type Blog struct {
 	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Slug      string             `bson:"slug" json:"slug"`
+	AuthorID  string             `bson:"author_id,omitempty" json:"authorId,omitempty"`
+	CoAuthors []string           `bson:"co_authors,omitempty" json:"coAuthors,omitempty"`
 	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
 	Title     string             `bson:"title" json:"title"`
 	Category  string             `bson:"category" json:"category"`
 	Content   string             `bson:"content" json:"content"`
-	Tags      []string           `bson:"tags" json:"tags"`
+
+	// ContentFormat is the language Content is written in, and ContentHTML
+	// what it renders to. The repository never fills ContentHTML in.
+	ContentFormat render.Format `bson:"content_format,omitempty" json:"contentFormat"`
+	ContentHTML   string        `bson:"-" json:"contentHtml,omitempty"`
+
+	// Summary is worked out from the content whenever it is written.
+	render.Summary `bson:",inline"`
+
+	Tags []string `bson:"tags" json:"tags"`
+
+	// CoverMediaID is the uploaded image shown with the blog, if any.
+	CoverMediaID string `bson:"cover_media_id,omitempty" json:"coverMediaId,omitempty"`
+
+	Revision    int        `bson:"revision" json:"revision"`
+	Version     int        `bson:"version" json:"version"`
+	Status      Status     `bson:"status" json:"status"`
+	PublishAt   *time.Time `bson:"publish_at,omitempty" json:"publishAt,omitempty"`
+	PublishedAt *time.Time `bson:"published_at,omitempty" json:"publishedAt,omitempty"`
+	DeletedAt   *time.Time `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"`
+}
+
+// now returns the current time at the precision MongoDB stores dates with, so
+// every backend hands out identical timestamps.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// newBlog builds the blog a create request describes. Blogs start out as
+// drafts unless the request publishes or schedules them.
+func newBlog(create dto.BlogCreateDto) (Blog, error) {
+	status, err := parseStatus(create.Status)
+	if err != nil {
+		return Blog{}, err
+	}
+	format, err := render.ParseFormat(create.ContentFormat)
+	if err != nil {
+		return Blog{}, err
+	}
+
+	createdAt := now()
+	blog := Blog{
+		ID:            primitive.NewObjectID(),
+		Slug:          create.Slug,
+		AuthorID:      create.Author,
+		CoAuthors:     coAuthors(create.Author, create.CoAuthors),
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+		Title:         create.Title,
+		Category:      create.Category,
+		Content:       create.Content,
+		ContentFormat: format,
+		Tags:          NormalizeTags(create.Tags),
+		Revision:      1,
+		Version:       1,
+		Status:        status,
+	}
+
+	if blog.Slug == "" {
+		blog.Slug = slug.Make(create.Title)
+	}
+	if err := summarize(&blog); err != nil {
+		return Blog{}, err
+	}
+
+	switch status {
+	case StatusPublished:
+		blog.PublishedAt = &createdAt
+	case StatusScheduled:
+		if create.PublishAt == nil {
+			return Blog{}, errors.New("a scheduled blog needs a publish time")
+		}
+		blog.PublishAt = storedTime(create.PublishAt)
+	}
+
+	return blog, nil
+}
+
+// coAuthors drops duplicates and the author from the co-authors of a blog.
+func coAuthors(author string, ids []string) []string {
+	var unique []string
+	for _, id := range ids {
+		if id != author && !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// Authors lists everyone who wrote b: its author first, then any co-authors.
+func (b *Blog) Authors() []string {
+	if b.AuthorID == "" {
+		return slices.Clone(b.CoAuthors)
+	}
+	return append([]string{b.AuthorID}, b.CoAuthors...)
+}
+
+// sortKeys maps each SortField to the document field it orders by.
+var sortKeys = map[SortField]string{
+	SortCreatedAt: "created_at",
+	SortUpdatedAt: "updated_at",
+	SortTitle:     "title",
 }
 
 func New(settings Settings) (*MongoBlogRepository, error) {
@@ -65,10 +197,82 @@ func New(settings Settings) (*MongoBlogRepository, error) {
 		return nil, fmt.Errorf("database failed to connect - %w", err)
 	}
 
-	return &MongoBlogRepository{
+	repository := &MongoBlogRepository{
 		client:     client,
 		collection: client.Database(settings.DbName).Collection(settings.DbName),
-	}, nil
+		slugs:      client.Database(settings.DbName).Collection("slugs"),
+		revisions:  client.Database(settings.DbName).Collection("revisions"),
+		comments:   client.Database(settings.DbName).Collection("comments"),
+		categories: client.Database(settings.DbName).Collection("categories"),
+		media:      client.Database(settings.DbName).Collection("media"),
+	}
+
+	if err := repository.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := repository.migrate(ctx); err != nil {
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// ensureIndexes creates the indexes listing relies on. Creating an index that
+// already exists is a no-op, so this runs on every start.
+func (s *MongoBlogRepository) ensureIndexes(ctx context.Context) error {
+	var models []mongo.IndexModel
+	for _, key := range sortKeys {
+		models = append(models, mongo.IndexModel{Keys: bson.D{{Key: key, Value: 1}, {Key: "_id", Value: 1}}})
+	}
+	models = append(models,
+		mongo.IndexModel{Keys: bson.D{{Key: "category", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "tags", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "search_terms", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publish_at", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "author_id", Value: 1}}},
+		mongo.IndexModel{Keys: bson.D{{Key: "co_authors", Value: 1}}},
+	)
+
+	if _, err := s.collection.Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("failed to create indexes - %w", err)
+	}
+
+	if _, err := s.slugs.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "blog_id", Value: 1}}}); err != nil {
+		return fmt.Errorf("failed to create slug indexes - %w", err)
+	}
+
+	_, err := s.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "blog_id", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create revision indexes - %w", err)
+	}
+
+	if _, err := s.comments.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "blog_id", Value: 1}}}); err != nil {
+		return fmt.Errorf("failed to create comment indexes - %w", err)
+	}
+
+	_, err = s.categories.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slug", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "name_key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "parent_id", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create category indexes - %w", err)
+	}
+
+	_, err = s.media.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create media indexes - %w", err)
+	}
+
+	return nil
 }
 
 func (s *MongoBlogRepository) Health(ctx context.Context) error {
@@ -81,97 +285,326 @@ func (s *MongoBlogRepository) Health(ctx context.Context) error {
 }
 
 func (s *MongoBlogRepository) CreateBlog(ctx context.Context, create dto.BlogCreateDto) (*string, error) {
-	blog := Blog{
-		ID:        primitive.NewObjectID(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Title:     create.Title,
-		Category:  create.Category,
-		Content:   create.Content,
-		Tags:      create.Tags,
+	blog, err := newBlog(create)
+	if err != nil {
+		return nil, err
+	}
+
+	blog.Slug, err = s.reserveSlug(ctx, blog.Slug, blog.ID, create.Slug != "")
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := s.collection.InsertOne(ctx, blog)
+	result, err := s.collection.InsertOne(ctx, searchableBlog{Blog: blog, SearchTerms: searchTerms(&blog)})
 	if err != nil {
+		s.releaseSlugs(ctx, blog.ID)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("failed to insert blog entry - %w", ErrConflict)
+		}
 		return nil, fmt.Errorf("failed to insert blog entry - %w", err)
 	}
 
+	if err := s.insertRevision(ctx, newRevision(&blog, create.Author, slices.Clone(revisionFields))); err != nil {
+		return nil, err
+	}
+
 	stringObjectID := result.InsertedID.(primitive.ObjectID).Hex()
 
 	return &stringObjectID, nil
 }
 
-func (s *MongoBlogRepository) GetBlogs(ctx context.Context) ([]*Blog, error) {
-	var blogs []*Blog
-	filter := bson.D{{}}
-	cur, err := s.collection.Find(ctx, filter)
+func (s *MongoBlogRepository) ListBlogs(ctx context.Context, opts ListOptions) (*BlogPage, error) {
+	opts, err := opts.normalize()
 	if err != nil {
-		return nil, errors.New("no blogs found")
+		return nil, err
 	}
 
-	for cur.Next(ctx) {
-		var b Blog
-		err := cur.Decode(&b)
+	categories, err := s.categorySubtree(ctx, opts.Category)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := listFilter(opts, categories)
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count blogs - %w", err)
+	}
+
+	key := sortKeys[opts.Sort]
+	direction, after := 1, "$gt"
+	if opts.Order == SortDesc {
+		direction, after = -1, "$lt"
+	}
+
+	if opts.Cursor != "" {
+		value, id, err := decodeCursor(opts.Cursor, opts)
 		if err != nil {
-			return nil, errors.New("paging error")
+			return nil, err
 		}
-		blogs = append(blogs, &b)
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.M{key: bson.M{after: value}},
+			bson.M{key: value, "_id": bson.M{after: id}},
+		}})
 	}
 
-	if err := cur.Err(); err != nil {
-		return nil, errors.New("paging error")
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: key, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(opts.Limit) + 1)
+	if opts.OmitContent {
+		findOptions.SetProjection(bson.M{"content": 0})
+	}
+	cur, err := s.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find blogs - %w", err)
 	}
 
-	if err = cur.Close(ctx); err != nil {
-		return nil, errors.New("paging error")
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(blogs) == 0 {
-		return nil, errors.New("no blogs found")
+	return newPage(blogs, total, opts), nil
+}
+
+// listFilter turns the filters of opts into a query, with the category
+// filter widened to the slugs in categories. The cursor is not part of it, so
+// the same filter also counts the total.
+func listFilter(opts ListOptions, categories []string) bson.D {
+	filter := bson.D{{Key: "deleted_at", Value: notTrashed}}
+	if opts.Trash {
+		filter = bson.D{{Key: "deleted_at", Value: bson.M{"$exists": true}}}
+	}
+	if len(opts.Statuses) > 0 {
+		filter = append(filter, bson.E{Key: "status", Value: bson.M{"$in": opts.Statuses}})
+	}
+	if len(categories) > 0 {
+		filter = append(filter, bson.E{Key: "category", Value: bson.M{"$in": categories}})
+	}
+	if opts.Author != "" {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.M{"author_id": opts.Author},
+			bson.M{"co_authors": opts.Author},
+		}})
+	}
+	if len(opts.IDs) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(opts.IDs))
+		for _, id := range opts.IDs {
+			// An id that does not parse matches no blog.
+			if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+				ids = append(ids, objID)
+			}
+		}
+		filter = append(filter, bson.E{Key: "_id", Value: bson.M{"$in": ids}})
+	}
+	if len(opts.Tags) > 0 {
+		operator := "$in"
+		if opts.TagMatch == TagMatchAll {
+			operator = "$all"
+		}
+		filter = append(filter, bson.E{Key: "tags", Value: bson.M{operator: opts.Tags}})
 	}
 
-	return blogs, nil
+	created := bson.M{}
+	if opts.CreatedBefore != nil {
+		created["$lt"] = *opts.CreatedBefore
+	}
+	if opts.CreatedAfter != nil {
+		created["$gt"] = *opts.CreatedAfter
+	}
+	if len(created) > 0 {
+		filter = append(filter, bson.E{Key: "created_at", Value: created})
+	}
+
+	return filter
 }
 
 func (s *MongoBlogRepository) GetBlog(ctx context.Context, id string) (*Blog, error) {
 	idFromHex, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("failed to create new object id")
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
 	}
 
 	filter := bson.D{
-		primitive.E{Key: "_id", Value: idFromHex}}
+		primitive.E{Key: "_id", Value: idFromHex},
+		primitive.E{Key: "deleted_at", Value: notTrashed}}
 
 	var blog *Blog
 	err = s.collection.FindOne(ctx, filter).Decode(&blog)
 	if err != nil {
-		return nil, errors.New("no blogs found")
+		return nil, notFound(id, err)
 	}
 
 	return blog, nil
 }
 
-func (s *MongoBlogRepository) DeleteBlog(ctx context.Context, id string) (*Blog, error) {
+// DeleteBlog moves a blog to the trash. It keeps its slugs, revisions and
+// comments until it is purged, so it can be restored as it was; its comments
+// are hidden until then.
+func (s *MongoBlogRepository) DeleteBlog(ctx context.Context, del dto.BlogDeleteDTO) (*Blog, error) {
+	id := del.Id
 	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
 
-	filter := bson.D{
-		primitive.E{Key: "_id", Value: idFromHex}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var blog *Blog
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		versionFilter(idFromHex, del.Version),
+		bson.M{"$set": bson.M{"deleted_at": now()}, "$inc": bson.M{"version": 1}},
+		opts,
+	).Decode(&blog)
+	if err != nil {
+		return nil, s.missing(ctx, idFromHex, err)
+	}
+
+	return blog, nil
+}
+
+func (s *MongoBlogRepository) RestoreBlog(ctx context.Context, id string) (*Blog, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
 	var blog *Blog
-	err = s.collection.FindOneAndDelete(ctx, filter).Decode(&blog)
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": idFromHex, "deleted_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deleted_at": ""}, "$inc": bson.M{"version": 1}},
+		opts,
+	).Decode(&blog)
 	if err != nil {
-		return nil, fmt.Errorf("cannot find id %v", id)
+		return nil, notFound(id, err)
 	}
 
 	return blog, nil
 }
 
+// PurgeBlog deletes a blog in the trash for good, with its slugs, revisions
+// and comments.
+func (s *MongoBlogRepository) PurgeBlog(ctx context.Context, id string) (*Blog, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var blog *Blog
+	err = s.collection.FindOneAndDelete(ctx, bson.M{"_id": idFromHex, "deleted_at": bson.M{"$exists": true}}).Decode(&blog)
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+
+	if err := s.purgeRelated(ctx, bson.M{"blog_id": idFromHex}); err != nil {
+		return nil, err
+	}
+
+	return blog, nil
+}
+
+// PurgeTrash purges every blog moved to the trash at or before before.
+func (s *MongoBlogRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.M{"deleted_at": bson.M{"$lte": before}}
+	cur, err := s.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired blogs - %w", err)
+	}
+
+	var expired []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &expired); err != nil {
+		return 0, fmt.Errorf("failed to decode expired blogs - %w", err)
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	ids := make(bson.A, 0, len(expired))
+	for _, e := range expired {
+		ids = append(ids, e.ID)
+	}
+
+	result, err := s.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$lte": before}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired blogs - %w", err)
+	}
+
+	if err := s.purgeRelated(ctx, bson.M{"blog_id": bson.M{"$in": ids}}); err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// purgeRelated deletes the slugs, revisions and comments of the blogs filter
+// selects.
+func (s *MongoBlogRepository) purgeRelated(ctx context.Context, filter bson.M) error {
+	if _, err := s.slugs.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("failed to release slugs - %w", err)
+	}
+	if _, err := s.revisions.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("failed to delete revisions - %w", err)
+	}
+	if _, err := s.comments.DeleteMany(ctx, filter); err != nil {
+		return fmt.Errorf("failed to delete comments - %w", err)
+	}
+	return nil
+}
+
+func (s *MongoBlogRepository) GetBlogBySlug(ctx context.Context, slug string) (*Blog, error) {
+	var record slugRecord
+	err := s.slugs.FindOne(ctx, bson.M{"_id": slug}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("cannot find slug %v - %w", slug, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query slug %v - %w", slug, err)
+	}
+
+	return s.GetBlog(ctx, record.BlogID.Hex())
+}
+
+// reserveSlug claims a unique slug based on base for the blog with id.
+func (s *MongoBlogRepository) reserveSlug(ctx context.Context, base string, id primitive.ObjectID, exact bool) (string, error) {
+	return uniqueSlug(base, exact, func(candidate string) (bool, error) {
+		_, err := s.slugs.InsertOne(ctx, slugRecord{Slug: candidate, BlogID: id})
+		if err == nil {
+			return true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return false, fmt.Errorf("failed to reserve slug %v - %w", candidate, err)
+		}
+
+		var owner slugRecord
+		err = s.slugs.FindOne(ctx, bson.M{"_id": candidate}).Decode(&owner)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return false, fmt.Errorf("failed to query slug %v - %w", candidate, err)
+		}
+		return owner.BlogID == id, nil
+	})
+}
+
+// releaseSlugs frees every slug the blog with id has had.
+func (s *MongoBlogRepository) releaseSlugs(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := s.slugs.DeleteMany(ctx, bson.M{"blog_id": id}); err != nil {
+		return fmt.Errorf("failed to release slugs of id %v - %w", id.Hex(), err)
+	}
+	return nil
+}
+
 func (s *MongoBlogRepository) UpdateBlog(ctx context.Context, update dto.BlogUpdateDTO) (*Blog, error) {
 	objID, err := primitive.ObjectIDFromHex(update.Id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid blog ID: %w", err)
+		return nil, fmt.Errorf("cannot parse id %v - %w", update.Id, ErrInvalidID)
 	}
 
+	update.Tags = normalizeTagUpdate(update.Tags)
+
 	updateFields := bson.M{}
 	if update.Title != nil {
 		updateFields["title"] = *update.Title
@@ -182,68 +615,453 @@ func (s *MongoBlogRepository) UpdateBlog(ctx context.Context, update dto.BlogUpd
 	if update.Content != nil {
 		updateFields["content"] = *update.Content
 	}
+	if update.ContentFormat != nil {
+		if _, err := render.ParseFormat(*update.ContentFormat); err != nil {
+			return nil, err
+		}
+		updateFields["content_format"] = *update.ContentFormat
+	}
 	if update.Tags != nil {
 		updateFields["tags"] = *update.Tags
 	}
-	updateFields["updated_at"] = time.Now()
+	if update.Slug != nil {
+		updateFields["slug"] = *update.Slug
+	}
+	updatedAt := now()
+	updateFields["updated_at"] = updatedAt
 
 	if len(updateFields) == 1 {
-		return nil, fmt.Errorf("no valid fields to update")
+		return nil, fmt.Errorf("cannot update id %v - %w", update.Id, ErrNoFieldsToUpdate)
 	}
 
-	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	// The blog keeps the record of its old slug, which is what lets
+	// GetBlogBySlug still find it there. A slug it already owned must
+	// survive a failed update.
+	var ownedSlug bool
+	if update.Slug != nil {
+		owners, err := s.slugs.CountDocuments(ctx, bson.M{"_id": *update.Slug, "blog_id": objID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query slug %v - %w", *update.Slug, err)
+		}
+		ownedSlug = owners > 0
+		if _, err := s.reserveSlug(ctx, *update.Slug, objID, true); err != nil {
+			return nil, err
+		}
+	}
 
-	var updated Blog
+	// The blog as it was is what the revision's changes are worked out from.
+	// $inc hands every update its own revision number.
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var before Blog
 	err = s.collection.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": objID},
-		bson.M{"$set": updateFields},
+		versionFilter(objID, update.Version),
+		bson.M{"$set": updateFields, "$inc": bson.M{"revision": 1, "version": 1}},
 		opts,
-	).Decode(&updated)
+	).Decode(&before)
+
+	if err != nil {
+		if update.Slug != nil && !ownedSlug {
+			s.slugs.DeleteOne(ctx, bson.M{"_id": *update.Slug, "blog_id": objID})
+		}
+		return nil, s.missing(ctx, objID, err)
+	}
+
+	updated := before
+	applyUpdate(&updated, update)
+	updated.UpdatedAt = updatedAt
+	updated.Revision++
+	updated.Version++
+	if err := summarize(&updated); err != nil {
+		return nil, err
+	}
+
+	// The words and summary can only be worked out from the whole updated
+	// blog. Matching updated_at leaves them alone if another update has
+	// already won.
+	derived := summaryFields(updated.Summary)
+	derived["search_terms"] = searchTerms(&updated)
+	_, err = s.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": updated.ID, "updated_at": updated.UpdatedAt},
+		bson.M{"$set": derived},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index blog %v - %w", update.Id, err)
+	}
+
+	if err := s.insertRevision(ctx, newRevision(&updated, update.Author, changedFields(&before, &updated))); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (s *MongoBlogRepository) insertRevision(ctx context.Context, revision *Revision) error {
+	_, err := s.revisions.InsertOne(ctx, revision)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("revision %d of blog %v exists - %w", revision.Number, revision.BlogID.Hex(), ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert revision of blog %v - %w", revision.BlogID.Hex(), err)
+	}
+	return nil
+}
+
+func (s *MongoBlogRepository) ListRevisions(ctx context.Context, id string) ([]*RevisionSummary, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "number", Value: -1}}).
+		SetProjection(bson.M{"number": 1, "created_at": 1, "author": 1, "changed": 1})
+	cur, err := s.revisions.Find(ctx, bson.M{"blog_id": idFromHex}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find revisions of id %v - %w", id, err)
+	}
+
+	var revisions []*RevisionSummary
+	if err := cur.All(ctx, &revisions); err != nil {
+		return nil, fmt.Errorf("failed to decode revisions of id %v - %w", id, err)
+	}
+
+	// Every blog has at least the revision its creation stored.
+	if len(revisions) == 0 {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+
+	return revisions, nil
+}
+
+func (s *MongoBlogRepository) GetRevision(ctx context.Context, id string, number int) (*Revision, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var revision Revision
+	err = s.revisions.FindOne(ctx, bson.M{"blog_id": idFromHex, "number": number}).Decode(&revision)
+	if err != nil {
+		return nil, notFound(fmt.Sprintf("%v revision %d", id, number), err)
+	}
+
+	return &revision, nil
+}
+
+func (s *MongoBlogRepository) SearchBlogs(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	opts = opts.normalize()
+	q := search.Parse(opts.Query)
+	if q.IsEmpty() {
+		return &SearchResult{Items: []*SearchHit{}}, nil
+	}
+
+	conditions := bson.A{bson.M{"deleted_at": notTrashed}}
+	if len(opts.Statuses) > 0 {
+		conditions = append(conditions, bson.M{"status": bson.M{"$in": opts.Statuses}})
+	}
+	for _, word := range q.Required() {
+		conditions = append(conditions, bson.M{"search_terms": word})
+	}
+	for _, prefix := range q.Prefixes {
+		conditions = append(conditions, bson.M{"search_terms": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}})
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(MaxSearchCandidates + 1).
+		SetProjection(bson.M{"search_terms": 0})
+	cur, err := s.collection.Find(ctx, bson.M{"$and": conditions}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search blogs - %w", err)
+	}
+
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankHits(blogs, q, opts), nil
+}
+
+func (s *MongoBlogRepository) SetBlogStatus(ctx context.Context, change dto.BlogStatusDTO) (*Blog, error) {
+	objID, err := primitive.ObjectIDFromHex(change.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", change.Id, ErrInvalidID)
+	}
 
+	status, err := parseStatus(change.Status)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, fmt.Errorf("blog not found")
+		return nil, err
+	}
+
+	set := bson.M{"status": status, "updated_at": now(), "version": bson.M{"$add": bson.A{"$version", 1}}}
+	switch status {
+	case StatusPublished:
+		set["published_at"] = bson.M{"$ifNull": bson.A{"$published_at", set["updated_at"]}}
+	case StatusScheduled:
+		if change.PublishAt == nil {
+			return nil, fmt.Errorf("scheduling id %v needs a publish time", change.Id)
 		}
-		return nil, fmt.Errorf("failed to update blog: %w", err)
+		set["publish_at"] = storedTime(change.PublishAt)
+	}
+	pipeline := mongo.Pipeline{{{Key: "$set", Value: set}}}
+	if status != StatusScheduled {
+		pipeline = append(pipeline, bson.D{{Key: "$unset", Value: "publish_at"}})
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated Blog
+	err = s.collection.FindOneAndUpdate(ctx, bson.M{"_id": objID, "deleted_at": notTrashed}, pipeline, opts).Decode(&updated)
+	if err != nil {
+		return nil, notFound(change.Id, err)
 	}
 
 	return &updated, nil
 }
 
-func (s *MongoBlogRepository) GetBlogsByTerm(ctx context.Context, term string) ([]*Blog, error) {
-	filter := bson.M{
-		"$or": []bson.M{
-			{"title": bson.M{"$regex": term, "$options": "i"}},
-			{"content": bson.M{"$regex": term, "$options": "i"}},
-			{"category": bson.M{"$regex": term, "$options": "i"}},
+func (s *MongoBlogRepository) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.collection.UpdateMany(
+		ctx,
+		bson.M{"status": StatusScheduled, "publish_at": bson.M{"$lte": now}, "deleted_at": notTrashed},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"status":       StatusPublished,
+				"published_at": "$publish_at",
+				"updated_at":   storedTime(&now),
+				"version":      bson.M{"$add": bson.A{"$version", 1}},
+			}}},
+			{{Key: "$unset", Value: "publish_at"}},
 		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish scheduled blogs - %w", err)
 	}
-	cur, err := s.collection.Find(ctx, filter)
+
+	return result.ModifiedCount, nil
+}
+
+// migrate brings blogs stored by older versions up to date.
+func (s *MongoBlogRepository) migrate(ctx context.Context) error {
+	// Blogs written before versioning start counting from their first
+	// version.
+	_, err := s.collection.UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 1}})
 	if err != nil {
-		return nil, errors.New("no blogs found")
+		return fmt.Errorf("failed to migrate blog version - %w", err)
+	}
+
+	// Before the editorial workflow every blog was live as soon as it was
+	// created.
+	_, err = s.collection.UpdateMany(
+		ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"status": StatusPublished, "published_at": "$created_at"}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to migrate blog status - %w", err)
+	}
+
+	if err := s.backfillSearchTerms(ctx); err != nil {
+		return err
 	}
-	var blogs []*Blog
+
+	if err := s.backfillSummaries(ctx); err != nil {
+		return err
+	}
+
+	if err := s.backfillCategories(ctx); err != nil {
+		return err
+	}
+
+	if err := s.backfillSlugs(ctx); err != nil {
+		return err
+	}
+
+	return s.backfillRevisions(ctx)
+}
+
+// backfillRevisions records the current state of blogs stored before
+// revisions existed as their first revision.
+func (s *MongoBlogRepository) backfillRevisions(ctx context.Context) error {
+	cur, err := s.collection.Find(ctx, bson.M{"revision": bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("failed to find blogs without revisions - %w", err)
+	}
+
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range blogs {
+		b.Revision = 1
+		err := s.insertRevision(ctx, newRevision(b, "", slices.Clone(revisionFields)))
+		if err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+		_, err = s.collection.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": bson.M{"revision": 1}})
+		if err != nil {
+			return fmt.Errorf("failed to set revision of blog %v - %w", b.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// backfillSlugs gives blogs stored before slugs existed one. Older blogs go
+// first, so they get the slugs without a suffix.
+func (s *MongoBlogRepository) backfillSlugs(ctx context.Context) error {
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cur, err := s.collection.Find(ctx, bson.M{"slug": bson.M{"$in": bson.A{nil, ""}}}, findOptions)
+	if err != nil {
+		return fmt.Errorf("failed to find blogs without a slug - %w", err)
+	}
+
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range blogs {
+		blogSlug, err := s.reserveSlug(ctx, slug.Make(b.Title), b.ID, false)
+		if err != nil {
+			return err
+		}
+		_, err = s.collection.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": bson.M{"slug": blogSlug}})
+		if err != nil {
+			return fmt.Errorf("failed to set slug of blog %v - %w", b.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// backfillSearchTerms indexes blogs stored before search_terms existed.
+func (s *MongoBlogRepository) backfillSearchTerms(ctx context.Context) error {
+	cur, err := s.collection.Find(ctx, bson.M{"search_terms": bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("failed to find unindexed blogs - %w", err)
+	}
+
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range blogs {
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": bson.M{"search_terms": searchTerms(b)}})
+		if err != nil {
+			return fmt.Errorf("failed to index blog %v - %w", b.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// backfillSummaries summarises blogs stored before summaries existed.
+func (s *MongoBlogRepository) backfillSummaries(ctx context.Context) error {
+	cur, err := s.collection.Find(ctx, bson.M{"word_count": bson.M{"$exists": false}})
+	if err != nil {
+		return fmt.Errorf("failed to find unsummarised blogs - %w", err)
+	}
+
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range blogs {
+		if err := summarize(b); err != nil {
+			return err
+		}
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": summaryFields(b.Summary)})
+		if err != nil {
+			return fmt.Errorf("failed to summarise blog %v - %w", b.ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// summarize works out the summary of b from its content.
+func summarize(b *Blog) error {
+	summary, err := render.Summarize(b.ContentFormat, b.Content)
+	if err != nil {
+		return fmt.Errorf("failed to summarise blog %v - %w", b.ID.Hex(), err)
+	}
+	b.Summary = summary
+	return nil
+}
+
+// summaryFields are the fields summary is stored in.
+func summaryFields(summary render.Summary) bson.M {
+	return bson.M{
+		"excerpt":              summary.Excerpt,
+		"word_count":           summary.WordCount,
+		"reading_time_minutes": summary.ReadingTimeMinutes,
+		"toc":                  summary.TableOfContents,
+	}
+}
+
+// decodeBlogs drains and closes cur. An empty result is an empty slice rather
+// than an error, so it serialises as [].
+func decodeBlogs(ctx context.Context, cur *mongo.Cursor) ([]*Blog, error) {
+	defer cur.Close(ctx)
+
+	blogs := []*Blog{}
 	for cur.Next(ctx) {
 		var b Blog
-		err := cur.Decode(&b)
-		if err != nil {
-			return nil, errors.New("paging error")
+		if err := cur.Decode(&b); err != nil {
+			return nil, fmt.Errorf("failed to decode blog - %w", err)
 		}
 		blogs = append(blogs, &b)
 	}
 
 	if err := cur.Err(); err != nil {
-		return nil, errors.New("paging error")
+		return nil, fmt.Errorf("paging error - %w", err)
 	}
 
-	if err = cur.Close(ctx); err != nil {
-		return nil, errors.New("paging error")
+	return blogs, nil
+}
+
+// notTrashed matches the deleted_at of every blog that is not in the trash.
+// Everything but the trash endpoints only ever sees those.
+var notTrashed = bson.M{"$exists": false}
+
+// versionFilter selects the blog with id, and only at version when one is
+// given, so a write compares and swaps in a single operation.
+func versionFilter(id primitive.ObjectID, version *int) bson.M {
+	filter := bson.M{"_id": id, "deleted_at": notTrashed}
+	if version != nil {
+		filter["version"] = *version
 	}
+	return filter
+}
 
-	if len(blogs) == 0 {
-		return nil, errors.New("no blogs found with that term")
+// missing explains why a versioned write matched no blog: either there is no
+// blog with id, or it has moved on to another version.
+func (s *MongoBlogRepository) missing(ctx context.Context, id primitive.ObjectID, err error) error {
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(id.Hex(), err)
 	}
 
-	return blogs, nil
+	count, err := s.collection.CountDocuments(ctx, bson.M{"_id": id, "deleted_at": notTrashed})
+	if err != nil {
+		return fmt.Errorf("failed to query id %v - %w", id.Hex(), err)
+	}
+	if count > 0 {
+		return fmt.Errorf("id %v has another version - %w", id.Hex(), ErrVersionMismatch)
+	}
+	return fmt.Errorf("cannot find id %v - %w", id.Hex(), ErrNotFound)
+}
+
+// notFound turns mongo.ErrNoDocuments into ErrNotFound and wraps anything else.
+func notFound(id string, err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	return fmt.Errorf("failed to query id %v - %w", id, err)
 }
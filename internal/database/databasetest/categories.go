@@ -0,0 +1,256 @@
+package databasetest
+
+import (
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CategoryFactory returns an empty blog repository and the category
+// repository that belongs with it, which may well be the same value.
+type CategoryFactory func(t *testing.T) (database.BlogRepository, database.CategoryRepository)
+
+func RunCategoryConformance(t *testing.T, newRepository CategoryFactory) {
+	run := func(name string, test func(*testing.T, database.BlogRepository, database.CategoryRepository)) {
+		t.Run(name, func(t *testing.T) {
+			blogs, categories := newRepository(t)
+			test(t, blogs, categories)
+		})
+	}
+	run("CreateCategory", testCreateCategory)
+	run("UpdateCategory", testUpdateCategory)
+	run("DeleteCategory", testDeleteCategory)
+	run("ListingIncludesChildren", testListingIncludesChildren)
+}
+
+func category(t *testing.T, categories database.CategoryRepository, name, parentID string) *database.Category {
+	t.Helper()
+	c, err := categories.CreateCategory(context.Background(), dto.CategoryCreateDTO{Name: name, ParentID: parentID})
+	require.NoError(t, err)
+	return c
+}
+
+func categoryNames(categories []*database.Category) []string {
+	var names []string
+	for _, c := range categories {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func testCreateCategory(t *testing.T, _ database.BlogRepository, categories database.CategoryRepository) {
+	ctx := context.Background()
+	programming := category(t, categories, "Programming", "")
+	golang, err := categories.CreateCategory(ctx, dto.CategoryCreateDTO{
+		Name:        "Go",
+		Slug:        "golang",
+		Description: "The Go language",
+		ParentID:    programming.ID.Hex(),
+	})
+	require.NoError(t, err)
+
+	t.Run("Stores what it was given", func(t *testing.T) {
+		got, err := categories.GetCategory(ctx, golang.ID.Hex())
+		require.NoError(t, err)
+		assert.Equal(t, "Go", got.Name)
+		assert.Equal(t, "golang", got.Slug)
+		assert.Equal(t, "The Go language", got.Description)
+		require.NotNil(t, got.ParentID)
+		assert.Equal(t, programming.ID, *got.ParentID)
+		assert.False(t, got.CreatedAt.IsZero())
+	})
+
+	t.Run("Makes a slug from the name", func(t *testing.T) {
+		assert.Equal(t, "programming", programming.Slug)
+		assert.Nil(t, programming.ParentID)
+	})
+
+	t.Run("Names and slugs are unique", func(t *testing.T) {
+		_, err := categories.CreateCategory(ctx, dto.CategoryCreateDTO{Name: "GO "})
+		assert.ErrorIs(t, err, database.ErrConflict, "names differing only in case")
+		_, err = categories.CreateCategory(ctx, dto.CategoryCreateDTO{Name: "Golang", Slug: "golang"})
+		assert.ErrorIs(t, err, database.ErrConflict)
+	})
+
+	t.Run("Parents must exist", func(t *testing.T) {
+		_, err := categories.CreateCategory(ctx, dto.CategoryCreateDTO{Name: "Orphan", ParentID: missingID()})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = categories.CreateCategory(ctx, dto.CategoryCreateDTO{Name: "Orphan", ParentID: "nope"})
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+
+	t.Run("Lists by name", func(t *testing.T) {
+		category(t, categories, "art", "")
+		list, err := categories.ListCategories(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"art", "Go", "Programming"}, categoryNames(list))
+	})
+
+	t.Run("Missing categories", func(t *testing.T) {
+		_, err := categories.GetCategory(ctx, missingID())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = categories.GetCategory(ctx, "nope")
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+}
+
+func testUpdateCategory(t *testing.T, blogs database.BlogRepository, categories database.CategoryRepository) {
+	ctx := context.Background()
+	top := category(t, categories, "Top", "")
+	middle := category(t, categories, "Middle", top.ID.Hex())
+	bottom := category(t, categories, "Bottom", middle.ID.Hex())
+	filed := create(t, blogs, dto.BlogCreateDto{Title: "Filed", Category: bottom.Slug})
+	trashed := create(t, blogs, dto.BlogCreateDto{Title: "Trashed", Category: bottom.Slug})
+	_, err := blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: trashed})
+	require.NoError(t, err)
+
+	t.Run("Renames", func(t *testing.T) {
+		name, description := "Lowest", "At the bottom"
+		updated, err := categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: bottom.ID.Hex(), Name: &name, Description: &description})
+		require.NoError(t, err)
+		assert.Equal(t, "Lowest", updated.Name)
+		assert.Equal(t, "bottom", updated.Slug, "the slug stays")
+		assert.Equal(t, "At the bottom", updated.Description)
+
+		got, err := categories.GetCategory(ctx, bottom.ID.Hex())
+		require.NoError(t, err)
+		assert.Equal(t, "Lowest", got.Name)
+	})
+
+	t.Run("A new slug takes the blogs along", func(t *testing.T) {
+		blog, err := blogs.GetBlog(ctx, filed)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+
+		newSlug := "lowest"
+		_, err = categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: bottom.ID.Hex(), Slug: &newSlug})
+		require.NoError(t, err)
+
+		moved, err := blogs.GetBlog(ctx, filed)
+		require.NoError(t, err)
+		assert.Equal(t, "lowest", moved.Category)
+		assert.Greater(t, moved.Version, blog.Version, "clients holding the old blog see it changed")
+		assert.True(t, moved.UpdatedAt.After(blog.UpdatedAt), "updated_at must move forward")
+		assert.Equal(t, blog.Revision, moved.Revision, "moving a category writes no revision")
+
+		page, err := blogs.ListBlogs(ctx, database.ListOptions{Category: "lowest", Trash: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Trashed"}, titles(page), "blogs in the trash move too")
+
+		hits, err := blogs.SearchBlogs(ctx, database.SearchOptions{Query: "lowest"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Filed"}, hitTitles(hits))
+	})
+
+	t.Run("Moves between parents", func(t *testing.T) {
+		none := ""
+		updated, err := categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: middle.ID.Hex(), ParentID: &none})
+		require.NoError(t, err)
+		assert.Nil(t, updated.ParentID)
+
+		parent := bottom.ID.Hex()
+		_, err = categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: top.ID.Hex(), ParentID: &parent})
+		require.NoError(t, err)
+	})
+
+	t.Run("Refuses cycles", func(t *testing.T) {
+		self := middle.ID.Hex()
+		_, err := categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: middle.ID.Hex(), ParentID: &self})
+		assert.ErrorIs(t, err, database.ErrConflict)
+
+		// top is below bottom, which is below middle.
+		below := top.ID.Hex()
+		_, err = categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: middle.ID.Hex(), ParentID: &below})
+		assert.ErrorIs(t, err, database.ErrConflict)
+
+		missing := missingID()
+		_, err = categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: middle.ID.Hex(), ParentID: &missing})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Names and slugs stay unique", func(t *testing.T) {
+		name, taken := "TOP", "middle"
+		_, err := categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: middle.ID.Hex(), Name: &name})
+		assert.ErrorIs(t, err, database.ErrConflict)
+		_, err = categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: top.ID.Hex(), Slug: &taken})
+		assert.ErrorIs(t, err, database.ErrConflict)
+	})
+
+	t.Run("Needs something to change", func(t *testing.T) {
+		_, err := categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: top.ID.Hex()})
+		assert.ErrorIs(t, err, database.ErrNoFieldsToUpdate)
+		name := "Anything"
+		_, err = categories.UpdateCategory(ctx, dto.CategoryUpdateDTO{Id: missingID(), Name: &name})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testDeleteCategory(t *testing.T, blogs database.BlogRepository, categories database.CategoryRepository) {
+	ctx := context.Background()
+	parent := category(t, categories, "Parent", "")
+	child := category(t, categories, "Child", parent.ID.Hex())
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Filed", Category: child.Slug})
+
+	t.Run("Keeps categories with something in them", func(t *testing.T) {
+		_, err := categories.DeleteCategory(ctx, parent.ID.Hex())
+		assert.ErrorIs(t, err, database.ErrConflict)
+
+		_, err = blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+		require.NoError(t, err)
+		_, err = categories.DeleteCategory(ctx, child.ID.Hex())
+		assert.ErrorIs(t, err, database.ErrConflict, "a blog in the trash may be restored")
+	})
+
+	t.Run("Deletes empty categories", func(t *testing.T) {
+		_, err := blogs.PurgeBlog(ctx, id)
+		require.NoError(t, err)
+
+		deleted, err := categories.DeleteCategory(ctx, child.ID.Hex())
+		require.NoError(t, err)
+		assert.Equal(t, "Child", deleted.Name)
+		_, err = categories.DeleteCategory(ctx, parent.ID.Hex())
+		require.NoError(t, err)
+
+		list, err := categories.ListCategories(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+		_, err = categories.DeleteCategory(ctx, parent.ID.Hex())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testListingIncludesChildren(t *testing.T, blogs database.BlogRepository, categories database.CategoryRepository) {
+	ctx := context.Background()
+	programming := category(t, categories, "Programming", "")
+	golang := category(t, categories, "Go", programming.ID.Hex())
+	generics := category(t, categories, "Generics", golang.ID.Hex())
+	category(t, categories, "Cooking", "")
+	create(t, blogs, dto.BlogCreateDto{Title: "Pointers", Category: programming.Slug})
+	create(t, blogs, dto.BlogCreateDto{Title: "Goroutines", Category: golang.Slug})
+	create(t, blogs, dto.BlogCreateDto{Title: "Type sets", Category: generics.Slug})
+	create(t, blogs, dto.BlogCreateDto{Title: "Bread", Category: "cooking"})
+	create(t, blogs, dto.BlogCreateDto{Title: "Unmanaged", Category: "Legacy Name"})
+
+	list := func(category string) []string {
+		t.Helper()
+		page, err := blogs.ListBlogs(ctx, database.ListOptions{Category: category, Sort: database.SortTitle, Order: database.SortAsc})
+		require.NoError(t, err)
+		return titles(page)
+	}
+
+	assert.Equal(t, []string{"Goroutines", "Pointers", "Type sets"}, list("programming"))
+	assert.Equal(t, []string{"Goroutines", "Type sets"}, list("go"))
+	assert.Equal(t, []string{"Type sets"}, list("generics"))
+	assert.Equal(t, []string{"Goroutines", "Type sets"}, list("GO"), "categories are found by name too")
+	assert.Equal(t, []string{"Unmanaged"}, list("Legacy Name"), "unknown categories match as they are")
+	assert.Empty(t, list("legacy-name"))
+
+	page, err := blogs.ListBlogs(ctx, database.ListOptions{Category: "programming", Limit: 2, Sort: database.SortTitle, Order: database.SortAsc})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, page.Total)
+}
@@ -0,0 +1,399 @@
+package databasetest
+
+import (
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// CommentFactory returns an empty blog repository and the comment repository
+// that belongs with it, which may well be the same value.
+type CommentFactory func(t *testing.T) (database.BlogRepository, database.CommentRepository)
+
+func RunCommentConformance(t *testing.T, newRepository CommentFactory) {
+	run := func(name string, test func(*testing.T, database.BlogRepository, database.CommentRepository)) {
+		t.Run(name, func(t *testing.T) {
+			blogs, comments := newRepository(t)
+			test(t, blogs, comments)
+		})
+	}
+	run("CreateComment", testCreateComment)
+	run("ListComments", testListComments)
+	run("DeleteComment", testDeleteComment)
+	run("CommentsFollowTheirBlog", testCommentsFollowTheirBlog)
+	run("Moderation", testModeration)
+}
+
+func comment(t *testing.T, comments database.CommentRepository, blogID, parentID, body string) *database.Comment {
+	t.Helper()
+	c, err := comments.CreateComment(context.Background(), dto.CommentCreateDTO{
+		BlogID:     blogID,
+		ParentID:   parentID,
+		Body:       body,
+		AuthorID:   "u1",
+		AuthorName: "Ada",
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func bodies(comments []*database.Comment) []string {
+	var b []string
+	for _, c := range comments {
+		b = append(b, c.Body)
+	}
+	return b
+}
+
+// decided returns the moderated comments as the moderation left them.
+func decided(moderated []*database.ModeratedComment) []*database.Comment {
+	var comments []*database.Comment
+	for _, m := range moderated {
+		comments = append(comments, m.After)
+	}
+	return comments
+}
+
+func testCreateComment(t *testing.T, blogs database.BlogRepository, comments database.CommentRepository) {
+	ctx := context.Background()
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Commented"})
+	other := create(t, blogs, dto.BlogCreateDto{Title: "Other"})
+
+	first := comment(t, comments, id, "", "First")
+	reply := comment(t, comments, id, first.ID.Hex(), "Reply")
+
+	t.Run("Records who said what where", func(t *testing.T) {
+		got, err := comments.GetComment(ctx, reply.ID.Hex())
+		require.NoError(t, err)
+		assert.Equal(t, id, got.BlogID.Hex())
+		require.NotNil(t, got.ParentID)
+		assert.Equal(t, first.ID, *got.ParentID)
+		assert.Equal(t, "u1", got.AuthorID)
+		assert.Equal(t, "Ada", got.AuthorName)
+		assert.Equal(t, "Reply", got.Body)
+		assert.False(t, got.CreatedAt.IsZero())
+	})
+
+	t.Run("Replies answer comments on the same blog", func(t *testing.T) {
+		_, err := comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: other, ParentID: first.ID.Hex(), Body: "Elsewhere"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: id, ParentID: missingID(), Body: "Nobody"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Missing blogs and comments", func(t *testing.T) {
+		_, err := comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: missingID(), Body: "Hello"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: "not-an-id", Body: "Hello"})
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+		_, err = comments.GetComment(ctx, missingID())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.ListComments(ctx, database.CommentListOptions{BlogID: missingID()})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testListComments(t *testing.T, blogs database.BlogRepository, comments database.CommentRepository) {
+	ctx := context.Background()
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Commented"})
+
+	comment(t, comments, id, "", "A")
+	b := comment(t, comments, id, "", "B")
+	c := comment(t, comments, id, "", "C")
+	b1 := comment(t, comments, id, b.ID.Hex(), "B1")
+	comment(t, comments, id, b1.ID.Hex(), "B1a")
+	comment(t, comments, id, b.ID.Hex(), "B2")
+	comment(t, comments, id, c.ID.Hex(), "C1")
+
+	list := func(opts database.CommentListOptions) *database.CommentPage {
+		t.Helper()
+		opts.BlogID = id
+		page, err := comments.ListComments(ctx, opts)
+		require.NoError(t, err)
+		return page
+	}
+
+	t.Run("Nests replies oldest first", func(t *testing.T) {
+		page := list(database.CommentListOptions{Sort: database.CommentsOldest})
+		assert.EqualValues(t, 3, page.Total)
+		require.Equal(t, []string{"A", "B", "C"}, bodies(page.Items))
+
+		assert.Empty(t, page.Items[0].Replies)
+		assert.Equal(t, []string{"B1", "B2"}, bodies(page.Items[1].Replies))
+		assert.Equal(t, []string{"B1a"}, bodies(page.Items[1].Replies[0].Replies))
+		assert.Equal(t, []string{"C1"}, bodies(page.Items[2].Replies))
+	})
+
+	t.Run("Sorts threads", func(t *testing.T) {
+		assert.Equal(t, []string{"C", "B", "A"}, bodies(list(database.CommentListOptions{}).Items))
+		assert.Equal(t, []string{"C", "B", "A"}, bodies(list(database.CommentListOptions{Sort: database.CommentsNewest}).Items))
+		assert.Equal(t, []string{"B", "C", "A"}, bodies(list(database.CommentListOptions{Sort: database.CommentsTop}).Items))
+	})
+
+	t.Run("Pages through threads", func(t *testing.T) {
+		page := list(database.CommentListOptions{Sort: database.CommentsTop, Limit: 2})
+		assert.Equal(t, []string{"B", "C"}, bodies(page.Items))
+		require.NotEmpty(t, page.NextCursor)
+
+		next := list(database.CommentListOptions{Sort: database.CommentsTop, Limit: 2, Cursor: page.NextCursor})
+		assert.Equal(t, []string{"A"}, bodies(next.Items))
+		assert.Empty(t, next.NextCursor)
+		assert.EqualValues(t, 3, next.Total)
+
+		_, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id, Sort: database.CommentsOldest, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, database.ErrInvalidCursor)
+		_, err = comments.ListComments(ctx, database.CommentListOptions{BlogID: id, Cursor: "garbage"})
+		assert.ErrorIs(t, err, database.ErrInvalidCursor)
+	})
+
+	t.Run("Blogs without comments", func(t *testing.T) {
+		empty := create(t, blogs, dto.BlogCreateDto{Title: "Quiet"})
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: empty})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+		assert.NotNil(t, page.Items)
+		assert.Zero(t, page.Total)
+	})
+}
+
+func testDeleteComment(t *testing.T, blogs database.BlogRepository, comments database.CommentRepository) {
+	ctx := context.Background()
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Commented"})
+
+	parent := comment(t, comments, id, "", "Parent")
+	reply := comment(t, comments, id, parent.ID.Hex(), "Reply")
+	lonely := comment(t, comments, id, "", "Lonely")
+
+	t.Run("Edits comments", func(t *testing.T) {
+		updated, err := comments.UpdateComment(ctx, dto.CommentUpdateDTO{Id: reply.ID.Hex(), Body: "Edited"})
+		require.NoError(t, err)
+		assert.Equal(t, "Edited", updated.Body)
+		assert.False(t, updated.UpdatedAt.Before(updated.CreatedAt))
+
+		_, err = comments.UpdateComment(ctx, dto.CommentUpdateDTO{Id: missingID(), Body: "Edited"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Deleted comments with replies stay as placeholders", func(t *testing.T) {
+		deleted, err := comments.DeleteComment(ctx, parent.ID.Hex())
+		require.NoError(t, err)
+		require.NotNil(t, deleted.DeletedAt)
+		assert.Empty(t, deleted.Body)
+		assert.Empty(t, deleted.AuthorID)
+		assert.Empty(t, deleted.AuthorName)
+
+		_, err = comments.DeleteComment(ctx, lonely.ID.Hex())
+		require.NoError(t, err)
+
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.EqualValues(t, 1, page.Total)
+		assert.Equal(t, parent.ID, page.Items[0].ID)
+		assert.NotNil(t, page.Items[0].DeletedAt)
+		assert.Equal(t, []string{"Edited"}, bodies(page.Items[0].Replies))
+	})
+
+	t.Run("Deleted comments cannot be changed or answered", func(t *testing.T) {
+		_, err := comments.UpdateComment(ctx, dto.CommentUpdateDTO{Id: parent.ID.Hex(), Body: "Back"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.DeleteComment(ctx, parent.ID.Hex())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: id, ParentID: parent.ID.Hex(), Body: "Hello?"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("A thread goes once its last reply is deleted", func(t *testing.T) {
+		_, err := comments.DeleteComment(ctx, reply.ID.Hex())
+		require.NoError(t, err)
+
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	})
+}
+
+func testCommentsFollowTheirBlog(t *testing.T, blogs database.BlogRepository, comments database.CommentRepository) {
+	ctx := context.Background()
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Commented"})
+	c := comment(t, comments, id, "", "Hello")
+
+	_, err := blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+	require.NoError(t, err)
+
+	t.Run("Hidden while the blog is in the trash", func(t *testing.T) {
+		_, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.GetComment(ctx, c.ID.Hex())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.UpdateComment(ctx, dto.CommentUpdateDTO{Id: c.ID.Hex(), Body: "Edited"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: id, Body: "Hello?"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Back when it is restored", func(t *testing.T) {
+		_, err := blogs.RestoreBlog(ctx, id)
+		require.NoError(t, err)
+
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Hello"}, bodies(page.Items))
+	})
+
+	t.Run("Gone when it is purged", func(t *testing.T) {
+		_, err := blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+		require.NoError(t, err)
+		_, err = blogs.PurgeBlog(ctx, id)
+		require.NoError(t, err)
+
+		_, err = comments.GetComment(ctx, c.ID.Hex())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testModeration(t *testing.T, blogs database.BlogRepository, comments database.CommentRepository) {
+	ctx := context.Background()
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Commented"})
+
+	held := func(body string) *database.Comment {
+		t.Helper()
+		c, err := comments.CreateComment(ctx, dto.CommentCreateDTO{
+			BlogID:    id,
+			Body:      body,
+			AuthorID:  "u2",
+			Status:    string(database.CommentPending),
+			SpamScore: 0.9,
+		})
+		require.NoError(t, err)
+		return c
+	}
+
+	approved := comment(t, comments, id, "", "Approved")
+	first := held("First")
+	second := held("Second")
+	third := held("Third")
+
+	t.Run("Comments are approved unless held", func(t *testing.T) {
+		assert.Equal(t, database.CommentApproved, approved.Status)
+		assert.Equal(t, database.CommentPending, first.Status)
+		assert.Equal(t, 0.9, first.SpamScore)
+	})
+
+	t.Run("Readers only see approved comments", func(t *testing.T) {
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Approved"}, bodies(page.Items))
+
+		_, err = comments.CreateComment(ctx, dto.CommentCreateDTO{BlogID: id, ParentID: first.ID.Hex(), Body: "Reply"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Queues pending comments oldest first", func(t *testing.T) {
+		page, err := comments.ModerationQueue(ctx, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"First", "Second", "Third"}, bodies(page.Items))
+		assert.EqualValues(t, 3, page.Total)
+
+		page, err = comments.ModerationQueue(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"First", "Second"}, bodies(page.Items))
+		assert.EqualValues(t, 3, page.Total)
+	})
+
+	t.Run("Moderates in bulk", func(t *testing.T) {
+		moderated, err := comments.ModerateComments(ctx, dto.CommentModerationDTO{
+			Ids:       []string{first.ID.Hex(), missingID(), second.ID.Hex()},
+			Status:    string(database.CommentApproved),
+			Moderator: "m1",
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"First", "Second"}, bodies(decided(moderated)))
+		for _, m := range moderated {
+			assert.Equal(t, database.CommentPending, m.Before.Status)
+			assert.Nil(t, m.Before.ModeratedAt)
+			assert.Equal(t, database.CommentApproved, m.After.Status)
+			assert.Equal(t, "m1", m.After.ModeratedBy)
+			assert.NotNil(t, m.After.ModeratedAt)
+		}
+
+		moderated, err = comments.ModerateComments(ctx, dto.CommentModerationDTO{
+			Ids:       []string{third.ID.Hex()},
+			Status:    string(database.CommentSpam),
+			Moderator: "m1",
+		})
+		require.NoError(t, err)
+		require.Len(t, moderated, 1)
+		assert.Equal(t, database.CommentSpam, moderated[0].After.Status)
+
+		queue, err := comments.ModerationQueue(ctx, 0)
+		require.NoError(t, err)
+		assert.Empty(t, queue.Items)
+
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id, Sort: database.CommentsOldest})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Approved", "First", "Second"}, bodies(page.Items))
+	})
+
+	t.Run("Hidden comments with replies stay as placeholders", func(t *testing.T) {
+		comment(t, comments, id, first.ID.Hex(), "Reply")
+		_, err := comments.ModerateComments(ctx, dto.CommentModerationDTO{
+			Ids:    []string{first.ID.Hex()},
+			Status: string(database.CommentRejected),
+		})
+		require.NoError(t, err)
+
+		page, err := comments.ListComments(ctx, database.CommentListOptions{BlogID: id, Sort: database.CommentsOldest})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 3)
+		placeholder := page.Items[1]
+		assert.Equal(t, first.ID, placeholder.ID)
+		assert.Empty(t, placeholder.Body)
+		assert.Empty(t, placeholder.AuthorID)
+		assert.Equal(t, []string{"Reply"}, bodies(placeholder.Replies))
+	})
+
+	t.Run("Edits can be held back", func(t *testing.T) {
+		updated, err := comments.UpdateComment(ctx, dto.CommentUpdateDTO{
+			Id:        approved.ID.Hex(),
+			Body:      "Buy now",
+			Status:    string(database.CommentPending),
+			SpamScore: 0.8,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, database.CommentPending, updated.Status)
+		assert.Equal(t, 0.8, updated.SpamScore)
+
+		updated, err = comments.UpdateComment(ctx, dto.CommentUpdateDTO{Id: approved.ID.Hex(), Body: "Still pending"})
+		require.NoError(t, err)
+		assert.Equal(t, database.CommentPending, updated.Status)
+	})
+
+	t.Run("Lists moderated comments to learn from", func(t *testing.T) {
+		_, err := comments.DeleteComment(ctx, second.ID.Hex())
+		require.NoError(t, err)
+
+		moderated, err := comments.ListModeratedComments(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"First", "Third"}, bodies(moderated))
+	})
+
+	t.Run("Deleted comments and trashed blogs are left alone", func(t *testing.T) {
+		moderated, err := comments.ModerateComments(ctx, dto.CommentModerationDTO{
+			Ids:    []string{second.ID.Hex(), "not-an-id"},
+			Status: string(database.CommentSpam),
+		})
+		require.NoError(t, err)
+		assert.Empty(t, moderated)
+
+		_, err = blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+		require.NoError(t, err)
+		queue, err := comments.ModerationQueue(ctx, 0)
+		require.NoError(t, err)
+		assert.Empty(t, queue.Items, "the pending edit is on a trashed blog")
+	})
+}
@@ -0,0 +1,910 @@
+// Package databasetest holds the behaviour every database.BlogRepository
+// backend has to share. New backends prove they match MongoBlogRepository by
+// calling RunConformance from their own tests.
+package databasetest
+
+import (
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/render"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Factory returns an empty repository. It is called once per conformance
+// test, so state never leaks between them.
+type Factory func(t *testing.T) database.BlogRepository
+
+func RunConformance(t *testing.T, newRepository Factory) {
+	t.Run("Health", func(t *testing.T) {
+		assert.NoError(t, newRepository(t).Health(context.Background()))
+	})
+	t.Run("CreateBlog", func(t *testing.T) { testCreateBlog(t, newRepository(t)) })
+	t.Run("GetBlog", func(t *testing.T) { testGetBlog(t, newRepository(t)) })
+	t.Run("ListBlogs", func(t *testing.T) { testListBlogs(t, newRepository(t)) })
+	t.Run("UpdateBlog", func(t *testing.T) { testUpdateBlog(t, newRepository(t)) })
+	t.Run("DeleteBlog", func(t *testing.T) { testDeleteBlog(t, newRepository(t)) })
+	t.Run("SearchBlogs", func(t *testing.T) { testSearchBlogs(t, newRepository(t)) })
+	t.Run("BlogStatus", func(t *testing.T) { testBlogStatus(t, newRepository(t)) })
+	t.Run("Slugs", func(t *testing.T) { testSlugs(t, newRepository(t)) })
+	t.Run("Revisions", func(t *testing.T) { testRevisions(t, newRepository(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, newRepository(t)) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, newRepository(t)) })
+	t.Run("Authors", func(t *testing.T) { testAuthors(t, newRepository(t)) })
+	t.Run("Summaries", func(t *testing.T) { testSummaries(t, newRepository(t)) })
+}
+
+func create(t *testing.T, repository database.BlogRepository, blog dto.BlogCreateDto) string {
+	t.Helper()
+	id, err := repository.CreateBlog(context.Background(), blog)
+	require.NoError(t, err)
+	require.NotNil(t, id)
+	return *id
+}
+
+func missingID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+func testCreateBlog(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	id := create(t, repository, dto.BlogCreateDto{
+		Title:    "Test Blog",
+		Category: "Tech",
+		Content:  "Test Content",
+		Tags:     []string{"go", "echo"},
+	})
+
+	blog, err := repository.GetBlog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, blog.ID.Hex())
+	assert.Equal(t, "Test Blog", blog.Title)
+	assert.Equal(t, "Tech", blog.Category)
+	assert.Equal(t, "Test Content", blog.Content)
+	assert.Equal(t, []string{"go", "echo"}, blog.Tags)
+	assert.False(t, blog.CreatedAt.IsZero())
+	assert.Equal(t, time.UTC, blog.CreatedAt.Location())
+	assert.Equal(t, blog.CreatedAt, blog.CreatedAt.Truncate(time.Millisecond))
+	assert.Equal(t, blog.CreatedAt, blog.UpdatedAt)
+}
+
+func testGetBlog(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+
+	_, err := repository.GetBlog(ctx, missingID())
+	assert.ErrorIs(t, err, database.ErrNotFound)
+
+	_, err = repository.GetBlog(ctx, "not-an-object-id")
+	assert.ErrorIs(t, err, database.ErrInvalidID)
+}
+
+func titles(page *database.BlogPage) []string {
+	var result []string
+	for _, blog := range page.Items {
+		result = append(result, blog.Title)
+	}
+	return result
+}
+
+func testListBlogs(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+
+	page, err := repository.ListBlogs(ctx, database.ListOptions{})
+	require.NoError(t, err, "empty collection")
+	assert.NotNil(t, page.Items)
+	assert.Empty(t, page.Items)
+	assert.Zero(t, page.Total)
+	assert.Empty(t, page.NextCursor)
+
+	create(t, repository, dto.BlogCreateDto{Title: "Charlie", Category: "Tech", Tags: []string{"go", "mongo"}})
+	alpha := create(t, repository, dto.BlogCreateDto{Title: "Alpha", Category: "Tech", Tags: []string{"go"}})
+	echo := create(t, repository, dto.BlogCreateDto{Title: "Echo", Category: "Food", Tags: []string{"bread"}})
+	create(t, repository, dto.BlogCreateDto{Title: "Bravo", Category: "Tech", Tags: []string{"mongo"}})
+	create(t, repository, dto.BlogCreateDto{Title: "Delta", Category: "Food", Tags: []string{"go", "bread"}})
+
+	t.Run("Lists newest first by default", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Delta", "Bravo", "Echo", "Alpha", "Charlie"}, titles(page))
+		assert.EqualValues(t, 5, page.Total)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Pages with a cursor", func(t *testing.T) {
+		var pages [][]string
+		opts := database.ListOptions{Limit: 2}
+		for {
+			page, err := repository.ListBlogs(ctx, opts)
+			require.NoError(t, err)
+			assert.EqualValues(t, 5, page.Total)
+			pages = append(pages, titles(page))
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		assert.Equal(t, [][]string{{"Delta", "Bravo"}, {"Echo", "Alpha"}, {"Charlie"}}, pages)
+	})
+
+	t.Run("Sorts by title in both directions", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Sort: database.SortTitle, Order: database.SortAsc, Limit: 3})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alpha", "Bravo", "Charlie"}, titles(page))
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Sort: database.SortTitle, Order: database.SortAsc, Limit: 3, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Delta", "Echo"}, titles(page))
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Sort: database.SortTitle, Order: database.SortDesc})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Echo", "Delta", "Charlie", "Bravo", "Alpha"}, titles(page))
+	})
+
+	t.Run("Sorts by last update", func(t *testing.T) {
+		oldest, err := repository.ListBlogs(ctx, database.ListOptions{Order: database.SortAsc, Limit: 1})
+		require.NoError(t, err)
+
+		time.Sleep(5 * time.Millisecond)
+		content := "edited"
+		_, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: oldest.Items[0].ID.Hex(), Content: &content})
+		require.NoError(t, err)
+
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Sort: database.SortUpdatedAt, Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Charlie"}, titles(page))
+	})
+
+	t.Run("Filters by category and tags", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Category: "Food"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Delta", "Echo"}, titles(page))
+		assert.EqualValues(t, 2, page.Total)
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Tags: []string{"bread", "mongo"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Delta", "Bravo", "Echo", "Charlie"}, titles(page))
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Tags: []string{"go", "mongo"}, TagMatch: database.TagMatchAll})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Charlie"}, titles(page))
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Category: "Tech", Tags: []string{"go"}, Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Alpha"}, titles(page))
+		assert.EqualValues(t, 2, page.Total)
+		assert.NotEmpty(t, page.NextCursor)
+	})
+
+	t.Run("Filters by id", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{IDs: []string{alpha, echo, "000000000000000000000000", "malformed"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Echo", "Alpha"}, titles(page))
+		assert.EqualValues(t, 2, page.Total)
+	})
+
+	t.Run("Filters by creation time, excluding the bounds", func(t *testing.T) {
+		all, err := repository.ListBlogs(ctx, database.ListOptions{Order: database.SortAsc})
+		require.NoError(t, err)
+		after := all.Items[0].CreatedAt
+		before := all.Items[4].CreatedAt
+		if after.Equal(before) {
+			t.Skip("blogs were created within the same millisecond")
+		}
+
+		page, err := repository.ListBlogs(ctx, database.ListOptions{CreatedAfter: &after, CreatedBefore: &before})
+		require.NoError(t, err)
+		for _, blog := range page.Items {
+			assert.True(t, blog.CreatedAt.After(after) && blog.CreatedAt.Before(before), blog.Title)
+		}
+		assert.NotContains(t, titles(page), "Charlie")
+		assert.NotContains(t, titles(page), "Delta")
+	})
+
+	t.Run("Rejects foreign or malformed cursors", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Limit: 1})
+		require.NoError(t, err)
+
+		_, err = repository.ListBlogs(ctx, database.ListOptions{Limit: 1, Sort: database.SortTitle, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, database.ErrInvalidCursor)
+
+		_, err = repository.ListBlogs(ctx, database.ListOptions{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, database.ErrInvalidCursor)
+	})
+
+	t.Run("Clamps the page size", func(t *testing.T) {
+		for i := 0; i < database.MaxLimit+1; i++ {
+			create(t, repository, dto.BlogCreateDto{Title: "Filler"})
+		}
+		page, err := repository.ListBlogs(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		assert.Len(t, page.Items, database.DefaultLimit)
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Limit: database.MaxLimit + 50})
+		require.NoError(t, err)
+		assert.Len(t, page.Items, database.MaxLimit)
+	})
+}
+
+func testUpdateBlog(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	id := create(t, repository, dto.BlogCreateDto{
+		Title:    "Test Blog",
+		Category: "Tech",
+		Content:  "Test Content",
+		Tags:     []string{"go"},
+	})
+	original, err := repository.GetBlog(ctx, id)
+	require.NoError(t, err)
+
+	t.Run("Only sets the given fields", func(t *testing.T) {
+		time.Sleep(5 * time.Millisecond)
+		title := "Updated Title"
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Title: &title})
+		require.NoError(t, err)
+		assert.Equal(t, "Updated Title", updated.Title)
+		assert.Equal(t, "Tech", updated.Category)
+		assert.Equal(t, "Test Content", updated.Content)
+		assert.Equal(t, []string{"go"}, updated.Tags)
+		assert.Equal(t, original.CreatedAt, updated.CreatedAt)
+		assert.True(t, updated.UpdatedAt.After(original.UpdatedAt), "updated_at must move forward")
+
+		stored, err := repository.GetBlog(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, updated, stored)
+	})
+
+	t.Run("Replaces tags", func(t *testing.T) {
+		tags := []string{"echo", "mongo"}
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Tags: &tags})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"echo", "mongo"}, updated.Tags)
+		assert.Equal(t, "Updated Title", updated.Title)
+	})
+
+	t.Run("Rejects an update without fields", func(t *testing.T) {
+		_, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id})
+		assert.ErrorIs(t, err, database.ErrNoFieldsToUpdate)
+	})
+
+	t.Run("Fails for a missing or malformed id", func(t *testing.T) {
+		title := "Updated Title"
+		_, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: missingID(), Title: &title})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: "not-an-object-id", Title: &title})
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+}
+
+func testDeleteBlog(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	id := create(t, repository, dto.BlogCreateDto{Title: "Test Blog"})
+
+	deleted, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+	require.NoError(t, err)
+	assert.Equal(t, id, deleted.ID.Hex())
+	assert.Equal(t, "Test Blog", deleted.Title)
+
+	_, err = repository.GetBlog(ctx, id)
+	assert.ErrorIs(t, err, database.ErrNotFound, "deleted blog is gone")
+
+	_, err = repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+	assert.ErrorIs(t, err, database.ErrNotFound, "second delete")
+
+	_, err = repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: "not-an-object-id"})
+	assert.ErrorIs(t, err, database.ErrInvalidID)
+}
+
+func hitTitles(result *database.SearchResult) []string {
+	var titles []string
+	for _, hit := range result.Items {
+		titles = append(titles, hit.Blog.Title)
+	}
+	return titles
+}
+
+func testSearchBlogs(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	create(t, repository, dto.BlogCreateDto{Title: "Learning Go", Category: "Tech", Content: "Channels and goroutines.", Tags: []string{"golang"}})
+	create(t, repository, dto.BlogCreateDto{Title: "Baking", Category: "Food", Content: "Bread, then a trip to go karting.", Tags: []string{"bread"}})
+	create(t, repository, dto.BlogCreateDto{Title: "Travel", Category: "Outdoors", Content: "Golf in Scotland, then go home.", Tags: []string{"golf"}})
+	create(t, repository, dto.BlogCreateDto{Title: "Gardening", Category: "Home", Content: "Roses need a café au lait.", Tags: []string{"roses"}})
+
+	searchFor := func(t *testing.T, query string) *database.SearchResult {
+		t.Helper()
+		result, err := repository.SearchBlogs(ctx, database.SearchOptions{Query: query})
+		require.NoError(t, err)
+		require.NotNil(t, result.Items)
+		return result
+	}
+
+	t.Run("Matches words case-insensitively, title matches first", func(t *testing.T) {
+		result := searchFor(t, "GO")
+		assert.Equal(t, []string{"Learning Go", "Travel", "Baking"}, hitTitles(result))
+		assert.EqualValues(t, 3, result.Total)
+		assert.Greater(t, result.Items[0].Score, result.Items[1].Score)
+	})
+
+	t.Run("Requires every word", func(t *testing.T) {
+		assert.Equal(t, []string{"Baking"}, hitTitles(searchFor(t, "go bread")))
+	})
+
+	t.Run("Matches prefixes", func(t *testing.T) {
+		assert.Equal(t, []string{"Gardening"}, hitTitles(searchFor(t, "garden*")))
+		assert.Equal(t, []string{"Travel", "Learning Go"}, hitTitles(searchFor(t, "gol*")))
+	})
+
+	t.Run("Matches phrases only when the words are adjacent", func(t *testing.T) {
+		assert.Equal(t, []string{"Baking"}, hitTitles(searchFor(t, `"go karting"`)))
+		assert.Empty(t, hitTitles(searchFor(t, `"karting go"`)))
+	})
+
+	t.Run("Matches tags and ignores accents", func(t *testing.T) {
+		assert.Equal(t, []string{"Baking"}, hitTitles(searchFor(t, "bread")))
+		assert.Equal(t, []string{"Gardening"}, hitTitles(searchFor(t, "CAFE")))
+	})
+
+	t.Run("Treats regular expression syntax as text", func(t *testing.T) {
+		assert.Empty(t, hitTitles(searchFor(t, "(.*")))
+		assert.Equal(t, []string{"Learning Go"}, hitTitles(searchFor(t, "^golang$")))
+	})
+
+	t.Run("Highlights matches", func(t *testing.T) {
+		result := searchFor(t, "roses")
+		require.Len(t, result.Items, 1)
+		assert.Equal(t, "Gardening", result.Items[0].Highlights["title"])
+		assert.Equal(t, "<mark>Roses</mark> need a café au lait.", result.Items[0].Highlights["content"])
+	})
+
+	t.Run("Pages with limit and offset", func(t *testing.T) {
+		result, err := repository.SearchBlogs(ctx, database.SearchOptions{Query: "go", Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Travel"}, hitTitles(result))
+		assert.EqualValues(t, 3, result.Total)
+		assert.False(t, result.Truncated)
+	})
+
+	t.Run("Returns no hits when nothing matches", func(t *testing.T) {
+		result := searchFor(t, "nothing matches this")
+		assert.Empty(t, result.Items)
+		assert.Zero(t, result.Total)
+		assert.Empty(t, searchFor(t, "!!!").Items)
+	})
+}
+
+func testBlogStatus(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	publishAt := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+
+	draft := create(t, repository, dto.BlogCreateDto{Title: "Draft", Content: "status"})
+	published := create(t, repository, dto.BlogCreateDto{Title: "Published", Content: "status", Status: "published"})
+	scheduled := create(t, repository, dto.BlogCreateDto{Title: "Scheduled", Content: "status", Status: "scheduled", PublishAt: &publishAt})
+
+	t.Run("Creates drafts by default", func(t *testing.T) {
+		blog, err := repository.GetBlog(ctx, draft)
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusDraft, blog.Status)
+		assert.Nil(t, blog.PublishAt)
+		assert.Nil(t, blog.PublishedAt)
+	})
+
+	t.Run("Creates published and scheduled blogs", func(t *testing.T) {
+		blog, err := repository.GetBlog(ctx, published)
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusPublished, blog.Status)
+		require.NotNil(t, blog.PublishedAt)
+		assert.Equal(t, blog.CreatedAt, *blog.PublishedAt)
+
+		blog, err = repository.GetBlog(ctx, scheduled)
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusScheduled, blog.Status)
+		require.NotNil(t, blog.PublishAt)
+		assert.True(t, publishAt.Equal(*blog.PublishAt))
+		assert.Nil(t, blog.PublishedAt)
+	})
+
+	t.Run("Filters lists and searches by status", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Statuses: []database.Status{database.StatusPublished}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Published"}, titles(page))
+		assert.EqualValues(t, 1, page.Total)
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Statuses: []database.Status{database.StatusDraft, database.StatusScheduled}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Scheduled", "Draft"}, titles(page))
+
+		result, err := repository.SearchBlogs(ctx, database.SearchOptions{Query: "status", Statuses: []database.Status{database.StatusPublished}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Published"}, hitTitles(result))
+	})
+
+	t.Run("Publishes only due blogs", func(t *testing.T) {
+		count, err := repository.PublishDue(ctx, publishAt.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		count, err = repository.PublishDue(ctx, publishAt)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, count)
+
+		blog, err := repository.GetBlog(ctx, scheduled)
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusPublished, blog.Status)
+		assert.Nil(t, blog.PublishAt)
+		require.NotNil(t, blog.PublishedAt)
+		assert.True(t, publishAt.Equal(*blog.PublishedAt))
+
+		draftBlog, err := repository.GetBlog(ctx, draft)
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusDraft, draftBlog.Status)
+	})
+
+	t.Run("Keeps the first publish time when republishing", func(t *testing.T) {
+		first, err := repository.GetBlog(ctx, published)
+		require.NoError(t, err)
+
+		blog, err := repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: published, Status: "draft"})
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusDraft, blog.Status)
+
+		blog, err = repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: published, Status: "published"})
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusPublished, blog.Status)
+		assert.Equal(t, first.PublishedAt, blog.PublishedAt)
+		assert.True(t, blog.UpdatedAt.After(first.UpdatedAt) || blog.UpdatedAt.Equal(first.UpdatedAt))
+	})
+
+	t.Run("Reschedules and archives", func(t *testing.T) {
+		later := publishAt.Add(24 * time.Hour)
+		blog, err := repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: draft, Status: "scheduled", PublishAt: &later})
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusScheduled, blog.Status)
+		require.NotNil(t, blog.PublishAt)
+		assert.True(t, later.Equal(*blog.PublishAt))
+
+		blog, err = repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: draft, Status: "archived"})
+		require.NoError(t, err)
+		assert.Equal(t, database.StatusArchived, blog.Status)
+		assert.Nil(t, blog.PublishAt)
+	})
+
+	t.Run("Fails for a missing or malformed id", func(t *testing.T) {
+		_, err := repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: missingID(), Status: "published"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: "not-an-object-id", Status: "published"})
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+}
+
+func testSlugs(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	blog := func(id string) *database.Blog {
+		t.Helper()
+		b, err := repository.GetBlog(ctx, id)
+		require.NoError(t, err)
+		return b
+	}
+
+	first := create(t, repository, dto.BlogCreateDto{Title: "Crème Brûlée, Explained!"})
+	second := create(t, repository, dto.BlogCreateDto{Title: "Creme brulee explained"})
+	third := create(t, repository, dto.BlogCreateDto{Title: "CRÈME BRÛLÉE EXPLAINED"})
+
+	t.Run("Generates unique slugs from titles", func(t *testing.T) {
+		assert.Equal(t, "creme-brulee-explained", blog(first).Slug)
+		assert.Equal(t, "creme-brulee-explained-2", blog(second).Slug)
+		assert.Equal(t, "creme-brulee-explained-3", blog(third).Slug)
+	})
+
+	t.Run("Finds blogs by slug", func(t *testing.T) {
+		found, err := repository.GetBlogBySlug(ctx, "creme-brulee-explained-2")
+		require.NoError(t, err)
+		assert.Equal(t, second, found.ID.Hex())
+
+		_, err = repository.GetBlogBySlug(ctx, "no-such-slug")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Uses a custom slug and rejects one that is taken", func(t *testing.T) {
+		custom := create(t, repository, dto.BlogCreateDto{Title: "Anything", Slug: "my-custom-slug"})
+		assert.Equal(t, "my-custom-slug", blog(custom).Slug)
+
+		_, err := repository.CreateBlog(ctx, dto.BlogCreateDto{Title: "Anything", Slug: "my-custom-slug"})
+		assert.ErrorIs(t, err, database.ErrConflict)
+	})
+
+	t.Run("Keeps the slug when the title changes", func(t *testing.T) {
+		title := "A new title"
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: first, Title: &title})
+		require.NoError(t, err)
+		assert.Equal(t, "creme-brulee-explained", updated.Slug)
+	})
+
+	t.Run("Keeps old slugs pointing at the blog", func(t *testing.T) {
+		newSlug := "custard"
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: first, Slug: &newSlug})
+		require.NoError(t, err)
+		assert.Equal(t, "custard", updated.Slug)
+
+		for _, s := range []string{"custard", "creme-brulee-explained"} {
+			found, err := repository.GetBlogBySlug(ctx, s)
+			require.NoError(t, err)
+			assert.Equal(t, first, found.ID.Hex())
+			assert.Equal(t, "custard", found.Slug)
+		}
+
+		_, err = repository.CreateBlog(ctx, dto.BlogCreateDto{Title: "x", Slug: "creme-brulee-explained"})
+		assert.ErrorIs(t, err, database.ErrConflict)
+
+		oldSlug := "creme-brulee-explained"
+		updated, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: first, Slug: &oldSlug})
+		require.NoError(t, err)
+		assert.Equal(t, oldSlug, updated.Slug)
+	})
+
+	t.Run("Rejects a slug owned by another blog", func(t *testing.T) {
+		taken := "creme-brulee-explained-3"
+		_, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: second, Slug: &taken})
+		assert.ErrorIs(t, err, database.ErrConflict)
+		assert.Equal(t, "creme-brulee-explained-2", blog(second).Slug)
+	})
+
+	t.Run("Frees the slugs of a purged blog", func(t *testing.T) {
+		_, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: first})
+		require.NoError(t, err)
+
+		_, err = repository.CreateBlog(ctx, dto.BlogCreateDto{Title: "x", Slug: "custard"})
+		assert.ErrorIs(t, err, database.ErrConflict, "a blog in the trash keeps its slugs")
+
+		_, err = repository.PurgeBlog(ctx, first)
+		require.NoError(t, err)
+
+		_, err = repository.GetBlogBySlug(ctx, "custard")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		reused := create(t, repository, dto.BlogCreateDto{Title: "Custard"})
+		assert.Equal(t, "custard", blog(reused).Slug)
+	})
+}
+
+func testRevisions(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	id := create(t, repository, dto.BlogCreateDto{
+		Title:    "First",
+		Category: "Tech",
+		Content:  "one",
+		Tags:     []string{"go"},
+		Author:   "ada",
+	})
+
+	content := "<p>two</p>"
+	format := string(render.HTML)
+	tags := []string{"go", "echo"}
+	updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Content: &content, ContentFormat: &format, Tags: &tags, Author: "grace"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+	assert.Equal(t, "ada", updated.AuthorID, "the blog keeps the author who created it")
+	assert.Equal(t, render.HTML, updated.ContentFormat)
+
+	title := "Second"
+	updated, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Title: &title, Category: &updated.Category})
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated.Revision)
+
+	t.Run("Records every create and update", func(t *testing.T) {
+		revisions, err := repository.ListRevisions(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, revisions, 3)
+
+		assert.Equal(t, 3, revisions[0].Number)
+		assert.Equal(t, []string{"title"}, revisions[0].Changed)
+		assert.Equal(t, updated.UpdatedAt, revisions[0].CreatedAt)
+
+		assert.Equal(t, 2, revisions[1].Number)
+		assert.Equal(t, "grace", revisions[1].Author)
+		assert.Equal(t, []string{"content", "contentFormat", "tags"}, revisions[1].Changed)
+
+		assert.Equal(t, 1, revisions[2].Number)
+		assert.Equal(t, "ada", revisions[2].Author)
+		assert.Equal(t, []string{"title", "category", "content", "contentFormat", "tags"}, revisions[2].Changed)
+	})
+
+	t.Run("Keeps the content of each revision", func(t *testing.T) {
+		revision, err := repository.GetRevision(ctx, id, 1)
+		require.NoError(t, err)
+		assert.Equal(t, id, revision.BlogID.Hex())
+		assert.Equal(t, "First", revision.Title)
+		assert.Equal(t, "one", revision.Content)
+		assert.Equal(t, render.Markdown, revision.ContentFormat, "content is Markdown unless it says otherwise")
+		assert.Equal(t, []string{"go"}, revision.Tags)
+
+		revision, err = repository.GetRevision(ctx, id, 2)
+		require.NoError(t, err)
+		assert.Equal(t, "First", revision.Title)
+		assert.Equal(t, "<p>two</p>", revision.Content)
+		assert.Equal(t, render.HTML, revision.ContentFormat)
+		assert.Equal(t, []string{"go", "echo"}, revision.Tags)
+	})
+
+	t.Run("Fails for a missing revision or blog", func(t *testing.T) {
+		_, err := repository.GetRevision(ctx, id, 4)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.GetRevision(ctx, missingID(), 1)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.ListRevisions(ctx, missingID())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.ListRevisions(ctx, "not-an-object-id")
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+
+	t.Run("Deletes revisions with the purged blog", func(t *testing.T) {
+		_, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id})
+		require.NoError(t, err)
+
+		_, err = repository.PurgeBlog(ctx, id)
+		require.NoError(t, err)
+
+		_, err = repository.ListRevisions(ctx, id)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testVersions(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	id := create(t, repository, dto.BlogCreateDto{Title: "Versioned", Content: "one"})
+	version := func(v int) *int { return &v }
+
+	created, err := repository.GetBlog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created.Version)
+
+	t.Run("Updates only the expected version", func(t *testing.T) {
+		content := "two"
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Content: &content, Version: version(1)})
+		require.NoError(t, err)
+		assert.Equal(t, 2, updated.Version)
+
+		stale := "stale"
+		_, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Content: &stale, Version: version(1)})
+		assert.ErrorIs(t, err, database.ErrVersionMismatch)
+
+		blog, err := repository.GetBlog(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "two", blog.Content)
+		assert.Equal(t, 2, blog.Version)
+		assert.Equal(t, 2, blog.Revision)
+	})
+
+	t.Run("Updates any version without one", func(t *testing.T) {
+		content := "three"
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Content: &content})
+		require.NoError(t, err)
+		assert.Equal(t, 3, updated.Version)
+	})
+
+	t.Run("Counts status changes as new versions", func(t *testing.T) {
+		blog, err := repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: id, Status: "published"})
+		require.NoError(t, err)
+		assert.Equal(t, 4, blog.Version)
+	})
+
+	t.Run("Keeps a slug the blog owned when a versioned update fails", func(t *testing.T) {
+		own := "versioned"
+		_, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Slug: &own, Version: version(1)})
+		assert.ErrorIs(t, err, database.ErrVersionMismatch)
+
+		blog, err := repository.GetBlogBySlug(ctx, own)
+		require.NoError(t, err)
+		assert.Equal(t, id, blog.ID.Hex())
+	})
+
+	t.Run("Tells a stale version from a missing blog", func(t *testing.T) {
+		content := "x"
+		_, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: missingID(), Content: &content, Version: version(1)})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: missingID(), Version: version(1)})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Deletes only the expected version", func(t *testing.T) {
+		_, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id, Version: version(3)})
+		assert.ErrorIs(t, err, database.ErrVersionMismatch)
+
+		deleted, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: id, Version: version(4)})
+		require.NoError(t, err)
+		assert.Equal(t, 5, deleted.Version, "moving to the trash is a change too")
+	})
+}
+
+func testTrash(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	kept := create(t, repository, dto.BlogCreateDto{Title: "Kept", Content: "trash", Status: "published"})
+	trashed := create(t, repository, dto.BlogCreateDto{Title: "Trashed", Content: "trash", Status: "published"})
+	expired := create(t, repository, dto.BlogCreateDto{Title: "Expired", Content: "trash", Status: "published"})
+
+	expiredBlog, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: expired})
+	require.NoError(t, err)
+	cutoff := *expiredBlog.DeletedAt
+
+	deleted, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: trashed})
+	require.NoError(t, err)
+	require.NotNil(t, deleted.DeletedAt)
+	assert.Equal(t, 2, deleted.Version)
+
+	t.Run("Hides blogs in the trash from every other read", func(t *testing.T) {
+		_, err := repository.GetBlog(ctx, trashed)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.GetBlogBySlug(ctx, "trashed")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		page, err := repository.ListBlogs(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Kept"}, titles(page))
+		assert.EqualValues(t, 1, page.Total)
+
+		result, err := repository.SearchBlogs(ctx, database.SearchOptions{Query: "trash"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Kept"}, hitTitles(result))
+
+		content := "edited"
+		_, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: trashed, Content: &content})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.SetBlogStatus(ctx, dto.BlogStatusDTO{Id: trashed, Status: "draft"})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: trashed})
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Lists the trash", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Trash: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Expired", "Trashed"}, titles(page))
+		assert.EqualValues(t, 2, page.Total)
+	})
+
+	t.Run("Restores blogs from the trash", func(t *testing.T) {
+		restored, err := repository.RestoreBlog(ctx, trashed)
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, 3, restored.Version)
+
+		blog, err := repository.GetBlogBySlug(ctx, "trashed")
+		require.NoError(t, err)
+		assert.Equal(t, trashed, blog.ID.Hex())
+
+		_, err = repository.RestoreBlog(ctx, trashed)
+		assert.ErrorIs(t, err, database.ErrNotFound, "only blogs in the trash can be restored")
+	})
+
+	t.Run("Purges only blogs in the trash", func(t *testing.T) {
+		_, err := repository.PurgeBlog(ctx, kept)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		_, err = repository.PurgeBlog(ctx, "not-an-object-id")
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+
+	t.Run("Purges blogs past the retention period", func(t *testing.T) {
+		time.Sleep(5 * time.Millisecond)
+		_, err := repository.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: trashed})
+		require.NoError(t, err)
+
+		purged, err := repository.PurgeTrash(ctx, cutoff.Add(-time.Millisecond))
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		purged, err = repository.PurgeTrash(ctx, cutoff)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, purged)
+
+		_, err = repository.RestoreBlog(ctx, expired)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Trash: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Trashed"}, titles(page))
+	})
+}
+
+func testAuthors(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	ada, grace, linus := missingID(), missingID(), missingID()
+	solo := create(t, repository, dto.BlogCreateDto{Title: "Solo", Author: ada, Status: "published"})
+	create(t, repository, dto.BlogCreateDto{Title: "Joint", Author: grace, CoAuthors: []string{ada, grace, ada}, Status: "published"})
+	create(t, repository, dto.BlogCreateDto{Title: "Other", Author: linus, Status: "published"})
+
+	t.Run("Records the author and co-authors", func(t *testing.T) {
+		blog, err := repository.GetBlog(ctx, solo)
+		require.NoError(t, err)
+		assert.Equal(t, ada, blog.AuthorID)
+		assert.Empty(t, blog.CoAuthors)
+
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Author: grace})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, []string{ada}, page.Items[0].CoAuthors, "duplicates and the author are dropped")
+		assert.Equal(t, []string{grace, ada}, page.Items[0].Authors())
+	})
+
+	t.Run("Filters by author or co-author", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{Author: ada, Sort: database.SortTitle, Order: database.SortAsc})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Joint", "Solo"}, titles(page))
+		assert.EqualValues(t, 2, page.Total)
+
+		page, err = repository.ListBlogs(ctx, database.ListOptions{Author: missingID()})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	})
+}
+
+func testSummaries(t *testing.T, repository database.BlogRepository) {
+	ctx := context.Background()
+	id := create(t, repository, dto.BlogCreateDto{
+		Title:   "Summarised",
+		Content: "# Intro\n\nThree words here.\n\n" + render.MoreMarker + "\n\n## Details\n\nMore.",
+		Status:  string(database.StatusPublished),
+	})
+
+	get := func() *database.Blog {
+		t.Helper()
+		blog, err := repository.GetBlog(ctx, id)
+		require.NoError(t, err)
+		return blog
+	}
+
+	t.Run("Summarised when created", func(t *testing.T) {
+		blog := get()
+		assert.Equal(t, "Intro Three words here.", blog.Excerpt)
+		assert.Equal(t, 6, blog.WordCount)
+		assert.Equal(t, 1, blog.ReadingTimeMinutes)
+		assert.Equal(t, []render.Heading{
+			{Level: 1, Text: "Intro", ID: "intro"},
+			{Level: 2, Text: "Details", ID: "details"},
+		}, blog.TableOfContents)
+	})
+
+	t.Run("Summarised again when updated", func(t *testing.T) {
+		content := "<h2>Only</h2><p>one two</p>"
+		format := string(render.HTML)
+		updated, err := repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Content: &content, ContentFormat: &format})
+		require.NoError(t, err)
+		assert.Equal(t, 3, updated.WordCount)
+
+		blog := get()
+		assert.Equal(t, "Only one two", blog.Excerpt)
+		assert.Equal(t, 3, blog.WordCount)
+		assert.Equal(t, []render.Heading{{Level: 2, Text: "Only", ID: "only"}}, blog.TableOfContents)
+
+		plain := string(render.Plain)
+		updated, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, ContentFormat: &plain})
+		require.NoError(t, err)
+		assert.Equal(t, render.Plain, updated.ContentFormat)
+		assert.Empty(t, updated.TableOfContents, "plain text has no headings")
+
+		unknown := "rst"
+		_, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, ContentFormat: &unknown})
+		assert.Error(t, err)
+		assert.Equal(t, render.Plain, get().ContentFormat)
+	})
+
+	t.Run("Lists leave the content out on request", func(t *testing.T) {
+		page, err := repository.ListBlogs(ctx, database.ListOptions{OmitContent: true})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Empty(t, page.Items[0].Content)
+		assert.Equal(t, 2, page.Items[0].WordCount, "the markup counts as text now")
+		assert.NotEmpty(t, get().Content, "the stored blog keeps its content")
+	})
+}
@@ -0,0 +1,117 @@
+package databasetest
+
+import (
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MediaFactory returns an empty blog repository and the media repository
+// that belongs with it, which may well be the same value.
+type MediaFactory func(t *testing.T) (database.BlogRepository, database.MediaRepository)
+
+func RunMediaConformance(t *testing.T, newRepository MediaFactory) {
+	run := func(name string, test func(*testing.T, database.BlogRepository, database.MediaRepository)) {
+		t.Run(name, func(t *testing.T) {
+			blogs, media := newRepository(t)
+			test(t, blogs, media)
+		})
+	}
+	run("CreateMedia", testCreateMedia)
+	run("ListMedia", testListMedia)
+	run("SetBlogCover", testSetBlogCover)
+}
+
+func upload(t *testing.T, media database.MediaRepository, hash string) *database.Media {
+	t.Helper()
+	m, err := media.CreateMedia(context.Background(), dto.MediaCreateDTO{
+		Hash:        hash,
+		Filename:    hash + ".png",
+		ContentType: "image/png",
+		Size:        1024,
+		Width:       800,
+		Height:      600,
+		Uploader:    "u1",
+		Variants: []dto.MediaVariantDTO{
+			{Name: "original", Key: hash + "/original.png", ContentType: "image/png", Width: 800, Height: 600, Size: 1024},
+			{Name: "thumbnail", Key: hash + "/thumbnail.png", ContentType: "image/png", Width: 200, Height: 200, Size: 256},
+		},
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func testCreateMedia(t *testing.T, _ database.BlogRepository, media database.MediaRepository) {
+	ctx := context.Background()
+	created := upload(t, media, "abc")
+	assert.False(t, created.ID.IsZero())
+	assert.False(t, created.CreatedAt.IsZero())
+
+	got, err := media.GetMedia(ctx, created.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+	require.NotNil(t, got.Variant("thumbnail"))
+	assert.Equal(t, "abc/thumbnail.png", got.Variant("thumbnail").Key)
+	assert.Nil(t, got.Variant("large"))
+
+	byHash, err := media.GetMediaByHash(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, byHash.ID)
+
+	_, err = media.CreateMedia(ctx, dto.MediaCreateDTO{Hash: "abc"})
+	assert.ErrorIs(t, err, database.ErrConflict, "the same content is stored once")
+
+	_, err = media.GetMedia(ctx, missingID())
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	_, err = media.GetMedia(ctx, "nope")
+	assert.ErrorIs(t, err, database.ErrInvalidID)
+	_, err = media.GetMediaByHash(ctx, "def")
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func testListMedia(t *testing.T, _ database.BlogRepository, media database.MediaRepository) {
+	ctx := context.Background()
+	first := upload(t, media, "first")
+	second := upload(t, media, "second")
+
+	list, err := media.ListMedia(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, second.ID, list[0].ID, "newest first")
+	assert.Equal(t, first.ID, list[1].ID)
+
+	list, err = media.ListMedia(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, second.ID, list[0].ID)
+}
+
+func testSetBlogCover(t *testing.T, blogs database.BlogRepository, media database.MediaRepository) {
+	ctx := context.Background()
+	id := create(t, blogs, dto.BlogCreateDto{Title: "Covered", Category: "Tech", Content: "Covered", Tags: []string{"go"}})
+	cover := upload(t, media, "cover")
+
+	blog, err := media.SetBlogCover(ctx, dto.BlogCoverDTO{Id: id, MediaID: cover.ID.Hex()})
+	require.NoError(t, err)
+	assert.Equal(t, cover.ID.Hex(), blog.CoverMediaID)
+	assert.Equal(t, 2, blog.Version)
+
+	stored, err := blogs.GetBlog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, cover.ID.Hex(), stored.CoverMediaID)
+
+	stale := 1
+	_, err = media.SetBlogCover(ctx, dto.BlogCoverDTO{Id: id, Version: &stale})
+	assert.ErrorIs(t, err, database.ErrVersionMismatch)
+
+	blog, err = media.SetBlogCover(ctx, dto.BlogCoverDTO{Id: id, Version: &blog.Version})
+	require.NoError(t, err)
+	assert.Empty(t, blog.CoverMediaID)
+
+	_, err = media.SetBlogCover(ctx, dto.BlogCoverDTO{Id: missingID(), MediaID: cover.ID.Hex()})
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
@@ -0,0 +1,158 @@
+package databasetest
+
+import (
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TagFactory returns an empty blog repository and the tag repository that
+// belongs with it, which may well be the same value.
+type TagFactory func(t *testing.T) (database.BlogRepository, database.TagRepository)
+
+func RunTagConformance(t *testing.T, newRepository TagFactory) {
+	run := func(name string, test func(*testing.T, database.BlogRepository, database.TagRepository)) {
+		t.Run(name, func(t *testing.T) {
+			blogs, tags := newRepository(t)
+			test(t, blogs, tags)
+		})
+	}
+	run("NormalizeTags", testNormalizeTags)
+	run("ListTags", testListTags)
+	run("MergeTags", testMergeTags)
+}
+
+func tagged(t *testing.T, blogs database.BlogRepository, title, status string, tags ...string) string {
+	t.Helper()
+	return create(t, blogs, dto.BlogCreateDto{Title: title, Category: "Tech", Content: title, Status: status, Tags: tags})
+}
+
+func testNormalizeTags(t *testing.T, blogs database.BlogRepository, _ database.TagRepository) {
+	ctx := context.Background()
+	id := tagged(t, blogs, "Normal", "", " Go ", "GO", "Web  Development", "  ")
+
+	blog, err := blogs.GetBlog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "web development"}, blog.Tags)
+
+	tags := []string{"Echo", "echo", "HTTP"}
+	updated, err := blogs.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: id, Tags: &tags})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "http"}, updated.Tags)
+
+	blog, err = blogs.GetBlog(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "http"}, blog.Tags)
+}
+
+func testListTags(t *testing.T, blogs database.BlogRepository, tags database.TagRepository) {
+	ctx := context.Background()
+	published := string(database.StatusPublished)
+	tagged(t, blogs, "One", published, "go", "web")
+	tagged(t, blogs, "Two", published, "go", "gophers")
+	tagged(t, blogs, "Draft", "", "go", "draft")
+	trashed := tagged(t, blogs, "Trashed", published, "web", "trash")
+	_, err := blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: trashed})
+	require.NoError(t, err)
+
+	list := func(opts database.TagOptions) []database.TagCount {
+		t.Helper()
+		counts, err := tags.ListTags(ctx, opts)
+		require.NoError(t, err)
+		result := []database.TagCount{}
+		for _, count := range counts {
+			result = append(result, *count)
+		}
+		return result
+	}
+
+	t.Run("Most used first", func(t *testing.T) {
+		assert.Equal(t, []database.TagCount{
+			{Tag: "go", Count: 3},
+			{Tag: "draft", Count: 1},
+			{Tag: "gophers", Count: 1},
+			{Tag: "web", Count: 1},
+		}, list(database.TagOptions{}), "the trash is left out")
+	})
+
+	t.Run("By status", func(t *testing.T) {
+		assert.Equal(t, []database.TagCount{
+			{Tag: "go", Count: 2},
+			{Tag: "gophers", Count: 1},
+			{Tag: "web", Count: 1},
+		}, list(database.TagOptions{Statuses: []database.Status{database.StatusPublished}}))
+	})
+
+	t.Run("By prefix", func(t *testing.T) {
+		assert.Equal(t, []database.TagCount{{Tag: "go", Count: 3}, {Tag: "gophers", Count: 1}}, list(database.TagOptions{Prefix: "go"}))
+		assert.Equal(t, []database.TagCount{{Tag: "go", Count: 3}}, list(database.TagOptions{Prefix: "go", Limit: 1}))
+		assert.Empty(t, list(database.TagOptions{Prefix: "g.*"}), "prefixes are not patterns")
+	})
+}
+
+func testMergeTags(t *testing.T, blogs database.BlogRepository, tags database.TagRepository) {
+	ctx := context.Background()
+	both := tagged(t, blogs, "Both", "", "golang", "web", "go")
+	one := tagged(t, blogs, "One", "", "web", "golang")
+	other := tagged(t, blogs, "Other", "", "rust")
+	trashed := tagged(t, blogs, "Trashed", "", "gopher")
+	_, err := blogs.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: trashed})
+	require.NoError(t, err)
+
+	get := func(id string) *database.Blog {
+		t.Helper()
+		page, err := blogs.ListBlogs(ctx, database.ListOptions{Trash: id == trashed, Limit: database.MaxLimit})
+		require.NoError(t, err)
+		for _, blog := range page.Items {
+			if blog.ID.Hex() == id {
+				return blog
+			}
+		}
+		require.Failf(t, "blog is missing", "id %v", id)
+		return nil
+	}
+	before := get(one)
+	time.Sleep(5 * time.Millisecond)
+
+	merged, err := tags.MergeTags(ctx, []string{"Golang", "gopher"}, " Go")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, merged)
+
+	assert.Equal(t, []string{"go", "web"}, get(both).Tags, "the first of the same tags stays")
+	assert.Equal(t, []string{"web", "go"}, get(one).Tags, "the order stays")
+	assert.Equal(t, []string{"rust"}, get(other).Tags)
+	assert.Equal(t, []string{"go"}, get(trashed).Tags, "blogs in the trash are merged too")
+	assert.Greater(t, get(one).Version, before.Version, "clients holding the old blog see it changed")
+	assert.True(t, get(one).UpdatedAt.After(before.UpdatedAt), "updated_at must move forward")
+	assert.Equal(t, before.Revision, get(one).Revision, "merging tags writes no revision")
+	assert.Equal(t, 1, get(other).Version)
+
+	hits, err := blogs.SearchBlogs(ctx, database.SearchOptions{Query: "golang"})
+	require.NoError(t, err)
+	assert.Empty(t, hits.Items, "search forgets the old tags")
+	hits, err = blogs.SearchBlogs(ctx, database.SearchOptions{Query: "go"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Both", "One"}, hitTitles(hits))
+
+	t.Run("Renames", func(t *testing.T) {
+		renamed, err := tags.MergeTags(ctx, []string{"rust"}, "Rust Lang")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, renamed)
+		assert.Equal(t, []string{"rust lang"}, get(other).Tags)
+	})
+
+	t.Run("Unused tags change nothing", func(t *testing.T) {
+		merged, err := tags.MergeTags(ctx, []string{"missing"}, "go")
+		require.NoError(t, err)
+		assert.Zero(t, merged)
+
+		merged, err = tags.MergeTags(ctx, []string{"go"}, "go")
+		require.NoError(t, err)
+		assert.Zero(t, merged, "a tag merged into itself")
+	})
+}
@@ -0,0 +1,208 @@
+package databasetest
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UserFactory returns an empty user repository, like Factory does for blogs.
+type UserFactory func(t *testing.T) database.UserRepository
+
+func RunUserConformance(t *testing.T, newRepository UserFactory) {
+	t.Run("CreateUser", func(t *testing.T) { testCreateUser(t, newRepository(t)) })
+	t.Run("UpdateUser", func(t *testing.T) { testUpdateUser(t, newRepository(t)) })
+	t.Run("RevokeToken", func(t *testing.T) { testRevokeToken(t, newRepository(t)) })
+	t.Run("APIKeys", func(t *testing.T) { testAPIKeys(t, newRepository(t)) })
+}
+
+func createUser(t *testing.T, repository database.UserRepository, email string) *database.User {
+	t.Helper()
+	user, err := repository.CreateUser(context.Background(), dto.UserCreateDTO{
+		Email:        email,
+		Name:         "Ada Lovelace",
+		PasswordHash: "hash",
+		Roles:        []auth.Role{auth.RoleReader},
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func testCreateUser(t *testing.T, repository database.UserRepository) {
+	ctx := context.Background()
+	created := createUser(t, repository, " Ada@Example.com ")
+	assert.Equal(t, "ada@example.com", created.Email)
+	assert.False(t, created.ID.IsZero())
+
+	t.Run("Finds users by id and by email in any case", func(t *testing.T) {
+		user, err := repository.GetUser(ctx, created.ID.Hex())
+		require.NoError(t, err)
+		assert.Equal(t, "Ada Lovelace", user.Name)
+		assert.Equal(t, "hash", user.PasswordHash)
+		assert.Equal(t, []auth.Role{auth.RoleReader}, user.Roles)
+
+		user, err = repository.GetUserByEmail(ctx, "ADA@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, user.ID)
+	})
+
+	t.Run("Emails are unique", func(t *testing.T) {
+		_, err := repository.CreateUser(ctx, dto.UserCreateDTO{Email: "ada@EXAMPLE.com", Name: "Impostor"})
+		assert.ErrorIs(t, err, database.ErrConflict)
+	})
+
+	t.Run("Missing users", func(t *testing.T) {
+		_, err := repository.GetUser(ctx, "000000000000000000000000")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = repository.GetUser(ctx, "not-an-id")
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+		_, err = repository.GetUserByEmail(ctx, "grace@example.com")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testUpdateUser(t *testing.T, repository database.UserRepository) {
+	ctx := context.Background()
+	ada := createUser(t, repository, "ada@example.com")
+	createUser(t, repository, "grace@example.com")
+
+	t.Run("Updates the profile", func(t *testing.T) {
+		name := "Countess of Lovelace"
+		email := "Countess@Example.com"
+		bio := "Wrote the first program."
+		avatar := "https://example.com/ada.png"
+		user, err := repository.UpdateUser(ctx, dto.UserUpdateDTO{Id: ada.ID.Hex(), Name: &name, Email: &email, Bio: &bio, AvatarURL: &avatar})
+		require.NoError(t, err)
+		assert.Equal(t, name, user.Name)
+		assert.Equal(t, "countess@example.com", user.Email)
+		assert.Equal(t, bio, user.Bio)
+		assert.Equal(t, avatar, user.AvatarURL)
+
+		empty := ""
+		user, err = repository.UpdateUser(ctx, dto.UserUpdateDTO{Id: ada.ID.Hex(), Bio: &empty})
+		require.NoError(t, err)
+		assert.Empty(t, user.Bio)
+		assert.Equal(t, avatar, user.AvatarURL)
+
+		_, err = repository.GetUserByEmail(ctx, "ada@example.com")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		found, err := repository.GetUserByEmail(ctx, "countess@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, ada.ID, found.ID)
+	})
+
+	t.Run("Refuses an email another user has", func(t *testing.T) {
+		email := "grace@example.com"
+		_, err := repository.UpdateUser(ctx, dto.UserUpdateDTO{Id: ada.ID.Hex(), Email: &email})
+		assert.ErrorIs(t, err, database.ErrConflict)
+	})
+
+	t.Run("Refuses empty updates", func(t *testing.T) {
+		_, err := repository.UpdateUser(ctx, dto.UserUpdateDTO{Id: ada.ID.Hex()})
+		assert.ErrorIs(t, err, database.ErrNoFieldsToUpdate)
+	})
+
+	t.Run("Sets the password and roles", func(t *testing.T) {
+		require.NoError(t, repository.SetPassword(ctx, ada.ID.Hex(), "new hash"))
+		user, err := repository.SetRoles(ctx, dto.UserRolesDTO{Id: ada.ID.Hex(), Roles: []auth.Role{auth.RoleAuthor, auth.RoleEditor}})
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleAuthor, auth.RoleEditor}, user.Roles)
+		assert.Equal(t, "new hash", user.PasswordHash)
+
+		err = repository.SetPassword(ctx, "000000000000000000000000", "hash")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+}
+
+func testRevokeToken(t *testing.T, repository database.UserRepository) {
+	ctx := context.Background()
+
+	revoked, err := repository.TokenRevoked(ctx, "token-1")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, repository.RevokeToken(ctx, "token-1", time.Now().Add(time.Hour)))
+	require.NoError(t, repository.RevokeToken(ctx, "token-1", time.Now().Add(time.Hour)), "revoking twice is harmless")
+
+	revoked, err = repository.TokenRevoked(ctx, "token-1")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = repository.TokenRevoked(ctx, "token-2")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func testAPIKeys(t *testing.T, repository database.UserRepository) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+
+	ci, err := repository.CreateAPIKey(ctx, dto.APIKeyCreateDTO{
+		Name:      "CI",
+		Prefix:    "bp_1234",
+		Hash:      "hash-1",
+		UserID:    "u1",
+		Scopes:    []auth.Scope{auth.ScopePostsRead, auth.ScopePostsWrite},
+		ExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+	assert.False(t, ci.ID.IsZero())
+	assert.True(t, ci.Active(time.Now()))
+	assert.False(t, ci.Active(expiresAt))
+
+	_, err = repository.CreateAPIKey(ctx, dto.APIKeyCreateDTO{Name: "Backup", Hash: "hash-2", UserID: "u1", ExpiresAt: expiresAt})
+	require.NoError(t, err)
+
+	t.Run("Finds keys by hash", func(t *testing.T) {
+		key, err := repository.GetAPIKeyByHash(ctx, "hash-1")
+		require.NoError(t, err)
+		assert.Equal(t, ci.ID, key.ID)
+		assert.Equal(t, "u1", key.UserID)
+		assert.Equal(t, []auth.Scope{auth.ScopePostsRead, auth.ScopePostsWrite}, key.Scopes)
+		assert.True(t, expiresAt.Equal(key.ExpiresAt))
+
+		_, err = repository.GetAPIKeyByHash(ctx, "hash-3")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("Lists the newest keys first", func(t *testing.T) {
+		keys, err := repository.ListAPIKeys(ctx)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "Backup", keys[0].Name)
+		assert.Equal(t, "CI", keys[1].Name)
+	})
+
+	t.Run("Records the last use", func(t *testing.T) {
+		used := time.Now().UTC().Truncate(time.Millisecond)
+		require.NoError(t, repository.TouchAPIKey(ctx, ci.ID.Hex(), used))
+		require.NoError(t, repository.TouchAPIKey(ctx, ci.ID.Hex(), used.Add(-time.Minute)), "older uses are ignored")
+
+		key, err := repository.GetAPIKeyByHash(ctx, "hash-1")
+		require.NoError(t, err)
+		require.NotNil(t, key.LastUsedAt)
+		assert.True(t, used.Equal(*key.LastUsedAt))
+	})
+
+	t.Run("Revokes keys once", func(t *testing.T) {
+		key, err := repository.RevokeAPIKey(ctx, ci.ID.Hex())
+		require.NoError(t, err)
+		require.NotNil(t, key.RevokedAt)
+		assert.False(t, key.Active(time.Now()))
+
+		again, err := repository.RevokeAPIKey(ctx, ci.ID.Hex())
+		require.NoError(t, err)
+		assert.True(t, key.RevokedAt.Equal(*again.RevokedAt))
+
+		_, err = repository.RevokeAPIKey(ctx, "000000000000000000000000")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = repository.RevokeAPIKey(ctx, "not-an-id")
+		assert.ErrorIs(t, err, database.ErrInvalidID)
+	})
+}
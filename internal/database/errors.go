@@ -0,0 +1,14 @@
+package database
+
+import "errors"
+
+// Errors returned by every BlogRepository. Implementations wrap them with
+// context, so callers should compare with errors.Is.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidID        = errors.New("invalid id")
+	ErrNoFieldsToUpdate = errors.New("no fields to update")
+	ErrConflict         = errors.New("conflict")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrVersionMismatch  = errors.New("version mismatch")
+)
@@ -4,7 +4,9 @@
 package database_test
 
 import (
+	"blog-platform/internal/auth"
 	"blog-platform/internal/database"
+	"blog-platform/internal/database/databasetest"
 	"blog-platform/internal/server"
 	"encoding/json"
 	"fmt"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"context"
+	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -22,6 +25,7 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -96,6 +100,88 @@ func createMongoContainer(ctx context.Context) (testcontainers.Container, *datab
 	return container, db, uri, nil
 }
 
+// conformanceDatabases counts the databases newConformanceDB connected to, so
+// every conformance test starts from an empty one.
+var conformanceDatabases int
+
+// newConformanceDB connects to a database of its own, named after prefix, on
+// the server the environment points to.
+func newConformanceDB(t *testing.T, prefix string) *database.MongoBlogRepository {
+	t.Helper()
+	conformanceDatabases++
+	repository, err := database.New(database.Settings{
+		HostName:   os.Getenv("DB_HOST"),
+		Port:       os.Getenv("DB_PORT"),
+		Username:   os.Getenv("DB_USERNAME"),
+		Password:   os.Getenv("DB_PASSWORD"),
+		DbName:     fmt.Sprintf("%s_%d", prefix, conformanceDatabases),
+		AuthSource: os.Getenv("DB_AUTHSOURCE"),
+	})
+	require.NoError(t, err)
+	return repository
+}
+
+func TestMongoConformance(t *testing.T) {
+	testDatabase := SetupTestDatabase()
+	defer testDatabase.TearDown()
+
+	databasetest.RunConformance(t, func(t *testing.T) database.BlogRepository {
+		return newConformanceDB(t, "conformance")
+	})
+}
+
+func TestMongoUserConformance(t *testing.T) {
+	testDatabase := SetupTestDatabase()
+	defer testDatabase.TearDown()
+
+	databasetest.RunUserConformance(t, func(t *testing.T) database.UserRepository {
+		blogs := newConformanceDB(t, "user_conformance")
+		users, err := database.NewUsers(blogs)
+		require.NoError(t, err)
+		return users
+	})
+}
+
+func TestMongoCommentConformance(t *testing.T) {
+	testDatabase := SetupTestDatabase()
+	defer testDatabase.TearDown()
+
+	databasetest.RunCommentConformance(t, func(t *testing.T) (database.BlogRepository, database.CommentRepository) {
+		repository := newConformanceDB(t, "comment_conformance")
+		return repository, repository
+	})
+}
+
+func TestMongoCategoryConformance(t *testing.T) {
+	testDatabase := SetupTestDatabase()
+	defer testDatabase.TearDown()
+
+	databasetest.RunCategoryConformance(t, func(t *testing.T) (database.BlogRepository, database.CategoryRepository) {
+		repository := newConformanceDB(t, "category_conformance")
+		return repository, repository
+	})
+}
+
+func TestMongoTagConformance(t *testing.T) {
+	testDatabase := SetupTestDatabase()
+	defer testDatabase.TearDown()
+
+	databasetest.RunTagConformance(t, func(t *testing.T) (database.BlogRepository, database.TagRepository) {
+		repository := newConformanceDB(t, "tag_conformance")
+		return repository, repository
+	})
+}
+
+func TestMongoMediaConformance(t *testing.T) {
+	testDatabase := SetupTestDatabase()
+	defer testDatabase.TearDown()
+
+	databasetest.RunMediaConformance(t, func(t *testing.T) (database.BlogRepository, database.MediaRepository) {
+		repository := newConformanceDB(t, "media_conformance")
+		return repository, repository
+	})
+}
+
 type IntegrationTestSuite struct {
 	suite.Suite
 	repository   *database.MongoBlogRepository
@@ -127,19 +213,27 @@ func TestSuite(t *testing.T) {
 }
 
 func (suite *IntegrationTestSuite) TestSuite() {
+	secret := make([]byte, auth.MinSecretLength)
+	_, err := rand.Read(secret)
+	suite.Require().NoError(err)
+	keys, err := auth.NewHMACKeys(secret, "")
+	suite.Require().NoError(err)
+	token, err := keys.Sign(auth.Principal{ID: "integration", Roles: []auth.Role{auth.RoleEditor}}, time.Hour)
+	suite.Require().NoError(err)
 
 	createBlog := func(payload string) (*httptest.ResponseRecorder, error) {
 		e := echo.New()
 
 		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
 		s := &server.Server{
 			DB: suite.repository,
 		}
-		err := s.CreateBlogHandler(c)
+		err := server.Authenticate(keys, nil)(s.CreateBlogHandler)(c)
 		if err != nil {
 			return nil, err
 		}
@@ -150,6 +244,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 		e := echo.New()
 
 		req := httptest.NewRequest(http.MethodDelete, "/posts/:id", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames("id")
@@ -158,7 +253,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 		s := &server.Server{
 			DB: suite.repository,
 		}
-		err := s.DeleteBlogHandler(c)
+		err := server.Authenticate(keys, nil)(s.DeleteBlogHandler)(c)
 		if err != nil {
 			return nil, err
 		}
@@ -170,6 +265,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 
 		req := httptest.NewRequest(http.MethodPut, "/posts/:id", strings.NewReader(payload))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames("id")
@@ -178,7 +274,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 		s := &server.Server{
 			DB: suite.repository,
 		}
-		err := s.UpdateBlogHandler(c)
+		err := server.Authenticate(keys, nil)(s.UpdateBlogHandler)(c)
 		if err != nil {
 			return nil, err
 		}
@@ -238,14 +334,15 @@ func (suite *IntegrationTestSuite) TestSuite() {
 			"tags": ["go", "two"]
 		}`)
 
-		blogs, err := suite.repository.GetBlogs(context.Background())
+		page, err := suite.repository.ListBlogs(context.Background(), database.ListOptions{})
 		suite.NoError(err)
-		suite.Equal(2, len(blogs))
+		suite.Equal(2, len(page.Items))
 
-		blogs, err = suite.repository.GetBlogsByTerm(context.Background(), "foo")
-		suite.Equal("My Test Blog 2", (*blogs[0]).Title)
-		suite.Equal("Example", (*blogs[0]).Category)
-		suite.Equal("foo bar baz", (*blogs[0]).Content)
+		result, err := suite.repository.SearchBlogs(context.Background(), database.SearchOptions{Query: "foo"})
+		suite.NoError(err)
+		suite.Equal("My Test Blog 2", result.Items[0].Blog.Title)
+		suite.Equal("Example", result.Items[0].Blog.Category)
+		suite.Equal("foo bar baz", result.Items[0].Blog.Content)
 	})
 	//
 	suite.Run("Creates a blog and is able to update it", func() {
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+type SortField string
+
+const (
+	SortCreatedAt SortField = "createdAt"
+	SortUpdatedAt SortField = "updatedAt"
+	SortTitle     SortField = "title"
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+type TagMatch string
+
+const (
+	TagMatchAny TagMatch = "any"
+	TagMatchAll TagMatch = "all"
+)
+
+// ListOptions selects one page of blogs. The zero value lists the newest
+// DefaultLimit blogs whatever their status.
+type ListOptions struct {
+	Limit         int
+	Cursor        string
+	Sort          SortField
+	Order         SortOrder
+	Statuses      []Status
+	Category      string
+	Tags          []string
+	TagMatch      TagMatch
+	CreatedBefore *time.Time
+	CreatedAfter  *time.Time
+
+	// Author lists the blogs the user with this id wrote or co-wrote.
+	Author string
+
+	// IDs lists only the blogs with these ids, unless it is empty.
+	IDs []string
+
+	// Trash lists the blogs in the trash instead of every other blog.
+	Trash bool
+
+	// OmitContent leaves the content out of the blogs listed, for lists
+	// that only show their summaries.
+	OmitContent bool
+}
+
+// BlogPage is one page of a listing. NextCursor is empty on the last page and
+// Total counts every blog matching the filters, not just this page. Truncated
+// marks a page of search hits whose Total only counts the blogs ranked, as in
+// SearchResult.
+type BlogPage struct {
+	Items      []*Blog `json:"items"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+	Total      int64   `json:"total"`
+	Truncated  bool    `json:"truncated,omitempty"`
+}
+
+// normalize fills in defaults and rejects values no backend understands.
+func (o ListOptions) normalize() (ListOptions, error) {
+	switch {
+	case o.Limit <= 0:
+		o.Limit = DefaultLimit
+	case o.Limit > MaxLimit:
+		o.Limit = MaxLimit
+	}
+
+	switch o.Sort {
+	case "":
+		o.Sort = SortCreatedAt
+	case SortCreatedAt, SortUpdatedAt, SortTitle:
+	default:
+		return o, fmt.Errorf("unknown sort field %q", o.Sort)
+	}
+
+	switch o.Order {
+	case "":
+		o.Order = SortDesc
+	case SortAsc, SortDesc:
+	default:
+		return o, fmt.Errorf("unknown sort order %q", o.Order)
+	}
+
+	switch o.TagMatch {
+	case "":
+		o.TagMatch = TagMatchAny
+	case TagMatchAny, TagMatchAll:
+	default:
+		return o, fmt.Errorf("unknown tag match %q", o.TagMatch)
+	}
+
+	return o, nil
+}
+
+// cursor marks the last blog of a page. It records the sort it was issued
+// for, so it cannot be replayed against a different ordering.
+type cursor struct {
+	Sort  SortField `json:"s"`
+	Order SortOrder `json:"o"`
+	Value string    `json:"v"`
+	ID    string    `json:"id"`
+}
+
+// sortValue is the value of the sort field of b, in the form a cursor stores.
+func sortValue(b *Blog, field SortField) string {
+	switch field {
+	case SortUpdatedAt:
+		return b.UpdatedAt.Format(time.RFC3339Nano)
+	case SortTitle:
+		return b.Title
+	default:
+		return b.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+func encodeCursor(b *Blog, opts ListOptions) string {
+	raw, _ := json.Marshal(cursor{
+		Sort:  opts.Sort,
+		Order: opts.Order,
+		Value: sortValue(b, opts.Sort),
+		ID:    b.ID.Hex(),
+	})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor returns the position after which the next page starts. The
+// value is a time.Time for date sorts and a string for title sorts.
+func decodeCursor(encoded string, opts ListOptions) (any, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, primitive.NilObjectID, fmt.Errorf("cannot decode cursor - %w", ErrInvalidCursor)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, primitive.NilObjectID, fmt.Errorf("cannot decode cursor - %w", ErrInvalidCursor)
+	}
+	if c.Sort != opts.Sort || c.Order != opts.Order {
+		return nil, primitive.NilObjectID, fmt.Errorf("cursor was issued for another sort - %w", ErrInvalidCursor)
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, primitive.NilObjectID, fmt.Errorf("cannot decode cursor id - %w", ErrInvalidCursor)
+	}
+
+	if c.Sort == SortTitle {
+		return c.Value, id, nil
+	}
+
+	value, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return nil, primitive.NilObjectID, fmt.Errorf("cannot decode cursor time - %w", ErrInvalidCursor)
+	}
+	return value.UTC(), id, nil
+}
+
+// newPage trims blogs, fetched with one extra item, to the page size and
+// issues a cursor when that extra item shows there is more to come.
+func newPage(blogs []*Blog, total int64, opts ListOptions) *BlogPage {
+	page := &BlogPage{Items: blogs, Total: total}
+	if len(blogs) > opts.Limit {
+		page.Items = blogs[:opts.Limit]
+		page.NextCursor = encodeCursor(page.Items[opts.Limit-1], opts)
+	}
+	return page
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MediaRepository keeps track of uploaded images and which blog shows which
+// as its cover. The files themselves live in a media.MediaStore.
+type MediaRepository interface {
+	// CreateMedia records an upload. Media are unique by the hash of their
+	// content, so uploading the same file twice fails with ErrConflict.
+	CreateMedia(ctx context.Context, create dto.MediaCreateDTO) (*Media, error)
+	GetMedia(ctx context.Context, id string) (*Media, error)
+	GetMediaByHash(ctx context.Context, hash string) (*Media, error)
+
+	// ListMedia returns the most recent uploads first.
+	ListMedia(ctx context.Context, limit int) ([]*Media, error)
+
+	// SetBlogCover makes the media with MediaID the cover of a blog, or
+	// takes its cover away when MediaID is empty.
+	SetBlogCover(ctx context.Context, change dto.BlogCoverDTO) (*Blog, error)
+}
+
+// Media is an uploaded image. Its Variants hold the image as uploaded, named
+// "original", and the resized copies made of it.
+type Media struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Hash        string             `bson:"hash" json:"hash"`
+	Filename    string             `bson:"filename" json:"filename"`
+	ContentType string             `bson:"content_type" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"`
+	Width       int                `bson:"width" json:"width"`
+	Height      int                `bson:"height" json:"height"`
+	UploaderID  string             `bson:"uploader_id" json:"uploaderId"`
+	Variants    []MediaVariant     `bson:"variants" json:"variants"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// MediaVariant is one stored file of a Media. Key is where the MediaStore
+// keeps it; URL is where it is served and is never filled in by the
+// repository.
+type MediaVariant struct {
+	Name        string `bson:"name" json:"name"`
+	Key         string `bson:"key" json:"-"`
+	ContentType string `bson:"content_type" json:"contentType"`
+	Width       int    `bson:"width" json:"width"`
+	Height      int    `bson:"height" json:"height"`
+	Size        int64  `bson:"size" json:"size"`
+	URL         string `bson:"-" json:"url,omitempty"`
+}
+
+// Variant returns the variant called name, or nil when there is none.
+func (m *Media) Variant(name string) *MediaVariant {
+	for i := range m.Variants {
+		if m.Variants[i].Name == name {
+			return &m.Variants[i]
+		}
+	}
+	return nil
+}
+
+// defaultMediaLimit is how many media ListMedia returns when no limit is
+// given.
+const defaultMediaLimit = 50
+
+func newMedia(create dto.MediaCreateDTO) Media {
+	media := Media{
+		ID:          primitive.NewObjectID(),
+		Hash:        create.Hash,
+		Filename:    create.Filename,
+		ContentType: create.ContentType,
+		Size:        create.Size,
+		Width:       create.Width,
+		Height:      create.Height,
+		UploaderID:  create.Uploader,
+		Variants:    make([]MediaVariant, 0, len(create.Variants)),
+		CreatedAt:   now(),
+	}
+	for _, v := range create.Variants {
+		media.Variants = append(media.Variants, MediaVariant{
+			Name:        v.Name,
+			Key:         v.Key,
+			ContentType: v.ContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+			Size:        v.Size,
+		})
+	}
+	return media
+}
+
+func (s *MongoBlogRepository) CreateMedia(ctx context.Context, create dto.MediaCreateDTO) (*Media, error) {
+	media := newMedia(create)
+	if _, err := s.media.InsertOne(ctx, media); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("media %v exists - %w", media.Hash, ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to insert media - %w", err)
+	}
+	return &media, nil
+}
+
+func (s *MongoBlogRepository) GetMedia(ctx context.Context, id string) (*Media, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var media *Media
+	if err := s.media.FindOne(ctx, bson.M{"_id": objID}).Decode(&media); err != nil {
+		return nil, notFound(id, err)
+	}
+	return media, nil
+}
+
+func (s *MongoBlogRepository) GetMediaByHash(ctx context.Context, hash string) (*Media, error) {
+	var media *Media
+	if err := s.media.FindOne(ctx, bson.M{"hash": hash}).Decode(&media); err != nil {
+		return nil, notFound(hash, err)
+	}
+	return media, nil
+}
+
+func (s *MongoBlogRepository) ListMedia(ctx context.Context, limit int) ([]*Media, error) {
+	if limit <= 0 {
+		limit = defaultMediaLimit
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cur, err := s.media.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find media - %w", err)
+	}
+
+	media := []*Media{}
+	if err := cur.All(ctx, &media); err != nil {
+		return nil, fmt.Errorf("failed to decode media - %w", err)
+	}
+	return media, nil
+}
+
+func (s *MongoBlogRepository) SetBlogCover(ctx context.Context, change dto.BlogCoverDTO) (*Blog, error) {
+	objID, err := primitive.ObjectIDFromHex(change.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", change.Id, ErrInvalidID)
+	}
+
+	set := bson.M{"updated_at": now()}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if change.MediaID == "" {
+		update["$unset"] = bson.M{"cover_media_id": ""}
+	} else {
+		set["cover_media_id"] = change.MediaID
+	}
+
+	var blog *Blog
+	err = s.collection.FindOneAndUpdate(
+		ctx,
+		versionFilter(objID, change.Version),
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&blog)
+	if err != nil {
+		return nil, s.missing(ctx, objID, err)
+	}
+	return blog, nil
+}
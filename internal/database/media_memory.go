@@ -0,0 +1,111 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *MemoryBlogRepository) CreateMedia(ctx context.Context, create dto.MediaCreateDTO) (*Media, error) {
+	media := newMedia(create)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.media {
+		if m.Hash == media.Hash {
+			return nil, fmt.Errorf("media %v exists - %w", media.Hash, ErrConflict)
+		}
+	}
+
+	s.media[media.ID] = cloneMedia(&media)
+	return &media, nil
+}
+
+func (s *MemoryBlogRepository) GetMedia(ctx context.Context, id string) (*Media, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	media, ok := s.media[objID]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	return cloneMedia(media), nil
+}
+
+func (s *MemoryBlogRepository) GetMediaByHash(ctx context.Context, hash string) (*Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, m := range s.media {
+		if m.Hash == hash {
+			return cloneMedia(m), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot find id %v - %w", hash, ErrNotFound)
+}
+
+func (s *MemoryBlogRepository) ListMedia(ctx context.Context, limit int) ([]*Media, error) {
+	if limit <= 0 {
+		limit = defaultMediaLimit
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*Media, 0, len(s.media))
+	for _, m := range s.media {
+		list = append(list, cloneMedia(m))
+	}
+	slices.SortFunc(list, func(a, b *Media) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(b.ID[:], a.ID[:])
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
+
+func (s *MemoryBlogRepository) SetBlogCover(ctx context.Context, change dto.BlogCoverDTO) (*Blog, error) {
+	objID, err := primitive.ObjectIDFromHex(change.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", change.Id, ErrInvalidID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog, ok := s.live(objID)
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", change.Id, ErrNotFound)
+	}
+	if change.Version != nil && *change.Version != blog.Version {
+		return nil, fmt.Errorf("id %v has another version - %w", change.Id, ErrVersionMismatch)
+	}
+
+	blog.CoverMediaID = change.MediaID
+	blog.UpdatedAt = now()
+	blog.Version++
+
+	return cloneBlog(blog), nil
+}
+
+// cloneMedia copies stored media so callers can never mutate repository
+// state.
+func cloneMedia(m *Media) *Media {
+	c := *m
+	c.Variants = slices.Clone(m.Variants)
+	return &c
+}
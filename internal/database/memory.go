@@ -0,0 +1,520 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"blog-platform/internal/render"
+	"blog-platform/internal/search"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryBlogRepository is a BlogRepository that keeps every blog in process
+// memory. It mirrors the behaviour of MongoBlogRepository and is intended for
+// tests and running the API locally without a database.
+type MemoryBlogRepository struct {
+	mu    sync.RWMutex
+	blogs map[primitive.ObjectID]*Blog
+	order []primitive.ObjectID
+	slugs map[string]primitive.ObjectID
+
+	// revisions holds the revisions of each blog, oldest first, so revision
+	// n is at index n-1.
+	revisions map[primitive.ObjectID][]*Revision
+
+	comments map[primitive.ObjectID]*Comment
+
+	categories map[primitive.ObjectID]*Category
+
+	media map[primitive.ObjectID]*Media
+}
+
+func NewMemory() *MemoryBlogRepository {
+	return &MemoryBlogRepository{
+		blogs:      make(map[primitive.ObjectID]*Blog),
+		slugs:      make(map[string]primitive.ObjectID),
+		revisions:  make(map[primitive.ObjectID][]*Revision),
+		comments:   make(map[primitive.ObjectID]*Comment),
+		categories: make(map[primitive.ObjectID]*Category),
+		media:      make(map[primitive.ObjectID]*Media),
+	}
+}
+
+func (s *MemoryBlogRepository) Health(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (s *MemoryBlogRepository) CreateBlog(ctx context.Context, create dto.BlogCreateDto) (*string, error) {
+	created, err := newBlog(create)
+	if err != nil {
+		return nil, err
+	}
+	blog := cloneBlog(&created)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog.Slug, err = s.reserveSlug(blog.Slug, blog.ID, create.Slug != "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.blogs[blog.ID] = blog
+	s.order = append(s.order, blog.ID)
+	s.revisions[blog.ID] = []*Revision{newRevision(blog, create.Author, slices.Clone(revisionFields))}
+
+	stringObjectID := blog.ID.Hex()
+
+	return &stringObjectID, nil
+}
+
+func (s *MemoryBlogRepository) ListBlogs(ctx context.Context, opts ListOptions) (*BlogPage, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var categories []string
+	if opts.Category != "" {
+		categories = categorySubtree(s.categoryList(), opts.Category)
+	}
+	var blogs []*Blog
+	for _, id := range s.order {
+		if b := s.blogs[id]; matchesList(b, opts, categories) {
+			clone := cloneBlog(b)
+			if opts.OmitContent {
+				clone.Content = ""
+			}
+			blogs = append(blogs, clone)
+		}
+	}
+	s.mu.RUnlock()
+
+	total := int64(len(blogs))
+	slices.SortFunc(blogs, func(a, b *Blog) int { return compareBlogs(a, b, opts) })
+
+	if opts.Cursor != "" {
+		value, id, err := decodeCursor(opts.Cursor, opts)
+		if err != nil {
+			return nil, err
+		}
+		position := &Blog{ID: id}
+		switch v := value.(type) {
+		case string:
+			position.Title = v
+		case time.Time:
+			position.CreatedAt, position.UpdatedAt = v, v
+		}
+		start := slices.IndexFunc(blogs, func(b *Blog) bool { return compareBlogs(b, position, opts) > 0 })
+		if start < 0 {
+			start = len(blogs)
+		}
+		blogs = blogs[start:]
+	}
+
+	if len(blogs) > opts.Limit+1 {
+		blogs = blogs[:opts.Limit+1]
+	}
+	if blogs == nil {
+		blogs = []*Blog{}
+	}
+
+	return newPage(blogs, total, opts), nil
+}
+
+// matchesList reports whether b is one of the blogs opts lists, with the
+// category filter widened to the slugs in categories.
+func matchesList(b *Blog, opts ListOptions, categories []string) bool {
+	if (b.DeletedAt != nil) != opts.Trash {
+		return false
+	}
+	if len(opts.Statuses) > 0 && !slices.Contains(opts.Statuses, b.Status) {
+		return false
+	}
+	if len(categories) > 0 && !slices.Contains(categories, b.Category) {
+		return false
+	}
+	if opts.Author != "" && !slices.Contains(b.Authors(), opts.Author) {
+		return false
+	}
+	if len(opts.IDs) > 0 && !slices.Contains(opts.IDs, b.ID.Hex()) {
+		return false
+	}
+	if len(opts.Tags) > 0 {
+		matches := slices.ContainsFunc(opts.Tags, func(tag string) bool { return slices.Contains(b.Tags, tag) })
+		if opts.TagMatch == TagMatchAll {
+			matches = !slices.ContainsFunc(opts.Tags, func(tag string) bool { return !slices.Contains(b.Tags, tag) })
+		}
+		if !matches {
+			return false
+		}
+	}
+	if opts.CreatedBefore != nil && !b.CreatedAt.Before(*opts.CreatedBefore) {
+		return false
+	}
+	if opts.CreatedAfter != nil && !b.CreatedAt.After(*opts.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// compareBlogs orders blogs the way MongoBlogRepository sorts them: by the
+// sort field, then by id, both in the requested direction.
+func compareBlogs(a, b *Blog, opts ListOptions) int {
+	var c int
+	switch opts.Sort {
+	case SortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = bytes.Compare(a.ID[:], b.ID[:])
+	}
+	if opts.Order == SortDesc {
+		return -c
+	}
+	return c
+}
+
+func (s *MemoryBlogRepository) GetBlog(ctx context.Context, id string) (*Blog, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blog, ok := s.live(idFromHex)
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+
+	return cloneBlog(blog), nil
+}
+
+func (s *MemoryBlogRepository) DeleteBlog(ctx context.Context, del dto.BlogDeleteDTO) (*Blog, error) {
+	id := del.Id
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog, ok := s.live(idFromHex)
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	if del.Version != nil && *del.Version != blog.Version {
+		return nil, fmt.Errorf("id %v has another version - %w", id, ErrVersionMismatch)
+	}
+
+	deletedAt := now()
+	blog.DeletedAt = &deletedAt
+	blog.Version++
+
+	return cloneBlog(blog), nil
+}
+
+func (s *MemoryBlogRepository) RestoreBlog(ctx context.Context, id string) (*Blog, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog, ok := s.blogs[idFromHex]
+	if !ok || blog.DeletedAt == nil {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+
+	blog.DeletedAt = nil
+	blog.Version++
+
+	return cloneBlog(blog), nil
+}
+
+func (s *MemoryBlogRepository) PurgeBlog(ctx context.Context, id string) (*Blog, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog, ok := s.blogs[idFromHex]
+	if !ok || blog.DeletedAt == nil {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+
+	s.purge(idFromHex)
+
+	return cloneBlog(blog), nil
+}
+
+func (s *MemoryBlogRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, blog := range s.blogs {
+		if blog.DeletedAt != nil && !blog.DeletedAt.After(before) {
+			s.purge(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purge forgets the blog with id and everything kept about it. The caller
+// must hold the write lock.
+func (s *MemoryBlogRepository) purge(id primitive.ObjectID) {
+	delete(s.blogs, id)
+	s.order = slices.DeleteFunc(s.order, func(o primitive.ObjectID) bool { return o == id })
+	maps.DeleteFunc(s.slugs, func(_ string, owner primitive.ObjectID) bool { return owner == id })
+	delete(s.revisions, id)
+	maps.DeleteFunc(s.comments, func(_ primitive.ObjectID, c *Comment) bool { return c.BlogID == id })
+}
+
+// live returns the blog with id unless it is missing or in the trash. The
+// caller must hold the lock.
+func (s *MemoryBlogRepository) live(id primitive.ObjectID) (*Blog, bool) {
+	blog, ok := s.blogs[id]
+	if !ok || blog.DeletedAt != nil {
+		return nil, false
+	}
+	return blog, true
+}
+
+func (s *MemoryBlogRepository) GetBlogBySlug(ctx context.Context, slug string) (*Blog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blog, ok := s.live(s.slugs[slug])
+	if !ok {
+		return nil, fmt.Errorf("cannot find slug %v - %w", slug, ErrNotFound)
+	}
+
+	return cloneBlog(blog), nil
+}
+
+// reserveSlug claims a unique slug based on base for the blog with id. The
+// caller must hold the write lock.
+func (s *MemoryBlogRepository) reserveSlug(base string, id primitive.ObjectID, exact bool) (string, error) {
+	return uniqueSlug(base, exact, func(candidate string) (bool, error) {
+		if owner, taken := s.slugs[candidate]; taken && owner != id {
+			return false, nil
+		}
+		s.slugs[candidate] = id
+		return true, nil
+	})
+}
+
+func (s *MemoryBlogRepository) UpdateBlog(ctx context.Context, update dto.BlogUpdateDTO) (*Blog, error) {
+	objID, err := primitive.ObjectIDFromHex(update.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", update.Id, ErrInvalidID)
+	}
+
+	if update.Title == nil && update.Category == nil && update.Content == nil && update.ContentFormat == nil && update.Tags == nil && update.Slug == nil {
+		return nil, fmt.Errorf("cannot update id %v - %w", update.Id, ErrNoFieldsToUpdate)
+	}
+
+	if update.ContentFormat != nil {
+		if _, err := render.ParseFormat(*update.ContentFormat); err != nil {
+			return nil, err
+		}
+	}
+	update.Tags = normalizeTagUpdate(update.Tags)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog, ok := s.live(objID)
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", update.Id, ErrNotFound)
+	}
+	if update.Version != nil && *update.Version != blog.Version {
+		return nil, fmt.Errorf("id %v has another version - %w", update.Id, ErrVersionMismatch)
+	}
+
+	before := cloneBlog(blog)
+	applyUpdate(blog, update)
+	if err := summarize(blog); err != nil {
+		*blog = *before
+		return nil, err
+	}
+	// The slug is only claimed once nothing else can fail the update, so a
+	// failed update leaves no slug behind.
+	if update.Slug != nil {
+		if _, err := s.reserveSlug(*update.Slug, objID, true); err != nil {
+			*blog = *before
+			return nil, err
+		}
+	}
+	blog.UpdatedAt = now()
+	blog.Revision++
+	blog.Version++
+	s.revisions[objID] = append(s.revisions[objID], newRevision(blog, update.Author, changedFields(before, blog)))
+
+	return cloneBlog(blog), nil
+}
+
+func (s *MemoryBlogRepository) ListRevisions(ctx context.Context, id string) ([]*RevisionSummary, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions, ok := s.revisions[idFromHex]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+
+	summaries := make([]*RevisionSummary, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		summary := revisions[i].RevisionSummary
+		summary.Changed = slices.Clone(summary.Changed)
+		summaries = append(summaries, &summary)
+	}
+
+	return summaries, nil
+}
+
+func (s *MemoryBlogRepository) GetRevision(ctx context.Context, id string, number int) (*Revision, error) {
+	idFromHex, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := s.revisions[idFromHex]
+	if number < 1 || number > len(revisions) {
+		return nil, fmt.Errorf("cannot find id %v revision %d - %w", id, number, ErrNotFound)
+	}
+
+	revision := *revisions[number-1]
+	revision.Changed = slices.Clone(revision.Changed)
+	revision.Tags = slices.Clone(revision.Tags)
+
+	return &revision, nil
+}
+
+func (s *MemoryBlogRepository) SearchBlogs(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
+	opts = opts.normalize()
+	q := search.Parse(opts.Query)
+	if q.IsEmpty() {
+		return &SearchResult{Items: []*SearchHit{}}, nil
+	}
+
+	s.mu.RLock()
+	var candidates []*Blog
+	for i := len(s.order) - 1; i >= 0 && len(candidates) <= MaxSearchCandidates; i-- {
+		b := s.blogs[s.order[i]]
+		if b.DeletedAt != nil {
+			continue
+		}
+		if len(opts.Statuses) > 0 && !slices.Contains(opts.Statuses, b.Status) {
+			continue
+		}
+		if containsTerms(searchTerms(b), q) {
+			candidates = append(candidates, cloneBlog(b))
+		}
+	}
+	s.mu.RUnlock()
+
+	return rankHits(candidates, q, opts), nil
+}
+
+func (s *MemoryBlogRepository) SetBlogStatus(ctx context.Context, change dto.BlogStatusDTO) (*Blog, error) {
+	objID, err := primitive.ObjectIDFromHex(change.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", change.Id, ErrInvalidID)
+	}
+
+	status, err := parseStatus(change.Status)
+	if err != nil {
+		return nil, err
+	}
+	if status == StatusScheduled && change.PublishAt == nil {
+		return nil, fmt.Errorf("scheduling id %v needs a publish time", change.Id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blog, ok := s.live(objID)
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", change.Id, ErrNotFound)
+	}
+
+	blog.Status = status
+	blog.UpdatedAt = now()
+	blog.Version++
+	blog.PublishAt = nil
+	switch status {
+	case StatusPublished:
+		if blog.PublishedAt == nil {
+			publishedAt := blog.UpdatedAt
+			blog.PublishedAt = &publishedAt
+		}
+	case StatusScheduled:
+		blog.PublishAt = storedTime(change.PublishAt)
+	}
+
+	return cloneBlog(blog), nil
+}
+
+func (s *MemoryBlogRepository) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var published int64
+	for _, blog := range s.blogs {
+		if blog.DeletedAt != nil || blog.Status != StatusScheduled || blog.PublishAt == nil || blog.PublishAt.After(now) {
+			continue
+		}
+		blog.Status = StatusPublished
+		blog.PublishedAt = blog.PublishAt
+		blog.PublishAt = nil
+		blog.UpdatedAt = *storedTime(&now)
+		blog.Version++
+		published++
+	}
+
+	return published, nil
+}
+
+// cloneBlog copies a stored blog so callers can never mutate repository state.
+func cloneBlog(b *Blog) *Blog {
+	c := *b
+	c.Tags = slices.Clone(b.Tags)
+	c.CoAuthors = slices.Clone(b.CoAuthors)
+	c.TableOfContents = slices.Clone(b.TableOfContents)
+	c.PublishAt = storedTime(b.PublishAt)
+	c.PublishedAt = storedTime(b.PublishedAt)
+	c.DeletedAt = storedTime(b.DeletedAt)
+	return &c
+}
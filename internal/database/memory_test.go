@@ -0,0 +1,117 @@
+package database_test
+
+import (
+	"blog-platform/internal/database"
+	"blog-platform/internal/database/databasetest"
+	"blog-platform/internal/dto"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	databasetest.RunConformance(t, func(t *testing.T) database.BlogRepository {
+		return database.NewMemory()
+	})
+}
+
+func TestMemoryUserConformance(t *testing.T) {
+	databasetest.RunUserConformance(t, func(t *testing.T) database.UserRepository {
+		return database.NewMemoryUsers()
+	})
+}
+
+func TestMemoryCommentConformance(t *testing.T) {
+	databasetest.RunCommentConformance(t, func(t *testing.T) (database.BlogRepository, database.CommentRepository) {
+		repository := database.NewMemory()
+		return repository, repository
+	})
+}
+
+func TestMemoryCategoryConformance(t *testing.T) {
+	databasetest.RunCategoryConformance(t, func(t *testing.T) (database.BlogRepository, database.CategoryRepository) {
+		repository := database.NewMemory()
+		return repository, repository
+	})
+}
+
+func TestMemoryTagConformance(t *testing.T) {
+	databasetest.RunTagConformance(t, func(t *testing.T) (database.BlogRepository, database.TagRepository) {
+		repository := database.NewMemory()
+		return repository, repository
+	})
+}
+
+func TestMemoryMediaConformance(t *testing.T) {
+	databasetest.RunMediaConformance(t, func(t *testing.T) (database.BlogRepository, database.MediaRepository) {
+		repository := database.NewMemory()
+		return repository, repository
+	})
+}
+
+func TestMemoryBlogRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Returned blogs do not alias stored state", func(t *testing.T) {
+		repository := database.NewMemory()
+		id, err := repository.CreateBlog(ctx, dto.BlogCreateDto{Title: "Test Blog", Tags: []string{"go"}})
+		require.NoError(t, err)
+
+		blog, err := repository.GetBlog(ctx, *id)
+		require.NoError(t, err)
+		blog.Title = "changed"
+		blog.Tags[0] = "changed"
+
+		blog, err = repository.GetBlog(ctx, *id)
+		require.NoError(t, err)
+		assert.Equal(t, "Test Blog", blog.Title)
+		assert.Equal(t, []string{"go"}, blog.Tags)
+	})
+
+	t.Run("Is safe for concurrent use", func(t *testing.T) {
+		repository := database.NewMemory()
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				id, err := repository.CreateBlog(ctx, dto.BlogCreateDto{Title: "Concurrent"})
+				assert.NoError(t, err)
+				content := "updated"
+				_, err = repository.UpdateBlog(ctx, dto.BlogUpdateDTO{Id: *id, Content: &content})
+				assert.NoError(t, err)
+				_, err = repository.ListBlogs(ctx, database.ListOptions{})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		page, err := repository.ListBlogs(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		assert.EqualValues(t, 50, page.Total)
+	})
+
+	t.Run("Ranks only the newest matches of a broad search", func(t *testing.T) {
+		repository := database.NewMemory()
+		_, err := repository.CreateBlog(ctx, dto.BlogCreateDto{Title: "Oldest gopher"})
+		require.NoError(t, err)
+		for i := 0; i < database.MaxSearchCandidates; i++ {
+			_, err := repository.CreateBlog(ctx, dto.BlogCreateDto{Title: fmt.Sprintf("Gopher %d", i)})
+			require.NoError(t, err)
+		}
+
+		result, err := repository.SearchBlogs(ctx, database.SearchOptions{Query: "gopher"})
+		require.NoError(t, err)
+		assert.True(t, result.Truncated)
+		assert.EqualValues(t, database.MaxSearchCandidates, result.Total)
+
+		result, err = repository.SearchBlogs(ctx, database.SearchOptions{Query: "oldest gopher"})
+		require.NoError(t, err)
+		assert.False(t, result.Truncated)
+		assert.EqualValues(t, 1, result.Total)
+	})
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"blog-platform/internal/render"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevisionSummary describes a revision without its content: who made it,
+// when, and which fields it changed.
+type RevisionSummary struct {
+	Number    int       `bson:"number" json:"number"`
+	CreatedAt time.Time `bson:"created_at" json:"createdAt"`
+	Author    string    `bson:"author" json:"author"`
+	Changed   []string  `bson:"changed" json:"changed"`
+}
+
+// Revision is the content of a blog as one create or update left it.
+// Revisions are never changed once stored; restoring one writes a new one.
+type Revision struct {
+	RevisionSummary `bson:",inline"`
+	ID              primitive.ObjectID `bson:"_id" json:"-"`
+	BlogID          primitive.ObjectID `bson:"blog_id" json:"postId"`
+	Title           string             `bson:"title" json:"title"`
+	Category        string             `bson:"category" json:"category"`
+	Content         string             `bson:"content" json:"content"`
+	ContentFormat   render.Format      `bson:"content_format,omitempty" json:"contentFormat,omitempty"`
+	Tags            []string           `bson:"tags" json:"tags"`
+}
+
+// revisionFields are the fields a revision records, by their JSON names.
+var revisionFields = []string{"title", "category", "content", "contentFormat", "tags"}
+
+func newRevision(b *Blog, author string, changed []string) *Revision {
+	return &Revision{
+		RevisionSummary: RevisionSummary{
+			Number:    b.Revision,
+			CreatedAt: b.UpdatedAt,
+			Author:    author,
+			Changed:   changed,
+		},
+		ID:            primitive.NewObjectID(),
+		BlogID:        b.ID,
+		Title:         b.Title,
+		Category:      b.Category,
+		Content:       b.Content,
+		ContentFormat: b.ContentFormat,
+		Tags:          slices.Clone(b.Tags),
+	}
+}
+
+// applyUpdate sets the fields update carries on b.
+func applyUpdate(b *Blog, update dto.BlogUpdateDTO) {
+	if update.Title != nil {
+		b.Title = *update.Title
+	}
+	if update.Category != nil {
+		b.Category = *update.Category
+	}
+	if update.Content != nil {
+		b.Content = *update.Content
+	}
+	if update.ContentFormat != nil {
+		b.ContentFormat = render.Format(*update.ContentFormat)
+	}
+	if update.Tags != nil {
+		b.Tags = slices.Clone(*update.Tags)
+	}
+	if update.Slug != nil {
+		b.Slug = *update.Slug
+	}
+}
+
+// changedFields lists the revision fields that differ between before and
+// after. It is never nil, so it is stored and serialised as a list.
+func changedFields(before, after *Blog) []string {
+	changed := []string{}
+	for _, field := range revisionFields {
+		var same bool
+		switch field {
+		case "title":
+			same = before.Title == after.Title
+		case "category":
+			same = before.Category == after.Category
+		case "content":
+			same = before.Content == after.Content
+		case "contentFormat":
+			same = before.ContentFormat == after.ContentFormat
+		case "tags":
+			same = slices.Equal(before.Tags, after.Tags)
+		}
+		if !same {
+			changed = append(changed, field)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"blog-platform/internal/search"
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// MaxSearchCandidates caps how many matching blogs a backend ranks for one
+// query. The newest blogs win when a query matches more than this, and the
+// result is marked as truncated.
+const MaxSearchCandidates = 1000
+
+// snippetLength is the rough size of the content excerpt in a search hit.
+const snippetLength = 200
+
+type SearchOptions struct {
+	Query    string
+	Statuses []Status
+	Limit    int
+	Offset   int
+}
+
+// SearchHit is a blog matching a search, its relevance and its title and
+// content as HTML with the matching words wrapped in <mark>.
+type SearchHit struct {
+	Blog       *Blog             `json:"post"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchResult is one page of hits. Total counts every hit, unless
+// Truncated says the query matched more than MaxSearchCandidates blogs and
+// only the newest of them were ranked and counted.
+type SearchResult struct {
+	Items     []*SearchHit `json:"items"`
+	Total     int64        `json:"total"`
+	Truncated bool         `json:"truncated"`
+}
+
+func (o SearchOptions) normalize() SearchOptions {
+	switch {
+	case o.Limit <= 0:
+		o.Limit = DefaultLimit
+	case o.Limit > MaxLimit:
+		o.Limit = MaxLimit
+	}
+	o.Offset = max(o.Offset, 0)
+	return o
+}
+
+// searchTerms is what a backend indexes for b: every distinct word of the
+// fields a search looks at.
+func searchTerms(b *Blog) []string {
+	return search.Terms(append([]string{b.Title, b.Category, b.Content}, b.Tags...)...)
+}
+
+// rankHits scores the candidates a backend found for q and returns the page
+// of hits opts asks for, best first and newest first among equal scores.
+// Backends pass up to one candidate more than MaxSearchCandidates, newest
+// first, so that rankHits can tell when the candidates were cut short.
+func rankHits(candidates []*Blog, q search.Query, opts SearchOptions) *SearchResult {
+	truncated := len(candidates) > MaxSearchCandidates
+	if truncated {
+		candidates = candidates[:MaxSearchCandidates]
+	}
+
+	var hits []*SearchHit
+	for _, b := range candidates {
+		score, ok := search.Score(q, search.Document{
+			Title:    b.Title,
+			Category: b.Category,
+			Content:  b.Content,
+			Tags:     b.Tags,
+		})
+		if ok {
+			hits = append(hits, &SearchHit{Blog: b, Score: score})
+		}
+	}
+
+	slices.SortFunc(hits, func(a, b *SearchHit) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return compareBlogs(a.Blog, b.Blog, ListOptions{Sort: SortCreatedAt, Order: SortDesc})
+	})
+
+	result := &SearchResult{Items: []*SearchHit{}, Total: int64(len(hits)), Truncated: truncated}
+	if opts.Offset >= len(hits) {
+		return result
+	}
+	hits = hits[opts.Offset:min(len(hits), opts.Offset+opts.Limit)]
+
+	for _, hit := range hits {
+		hit.Highlights = map[string]string{
+			"title":   search.Highlight(hit.Blog.Title, q, 0),
+			"content": search.Highlight(hit.Blog.Content, q, snippetLength),
+		}
+	}
+	result.Items = hits
+	return result
+}
+
+// containsTerms reports whether a blog indexed with terms is a candidate for
+// q, the way MongoBlogRepository selects candidates through its index.
+func containsTerms(terms []string, q search.Query) bool {
+	for _, word := range q.Required() {
+		if _, found := slices.BinarySearch(terms, word); !found {
+			return false
+		}
+	}
+	for _, prefix := range q.Prefixes {
+		if !slices.ContainsFunc(terms, func(term string) bool { return strings.HasPrefix(term, prefix) }) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"blog-platform/internal/slug"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// slugRecord maps a slug to the blog it names. A blog keeps the records of its
+// earlier slugs, so links using them can be redirected to the current one.
+type slugRecord struct {
+	Slug   string             `bson:"_id"`
+	BlogID primitive.ObjectID `bson:"blog_id"`
+}
+
+// uniqueSlug claims the first free candidate for base: base itself, then
+// base-2, base-3 and so on. claim reports whether a candidate now belongs to
+// the blog, which includes a slug the blog already owned. A custom slug is
+// exact and fails with ErrConflict instead of gaining a suffix.
+func uniqueSlug(base string, exact bool, claim func(candidate string) (bool, error)) (string, error) {
+	for n := 1; ; n++ {
+		candidate := slug.WithSuffix(base, n)
+		claimed, err := claim(candidate)
+		if err != nil {
+			return "", err
+		}
+		if claimed {
+			return candidate, nil
+		}
+		if exact {
+			return "", fmt.Errorf("slug %v is taken - %w", candidate, ErrConflict)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Status is where a blog is in the editorial workflow. Only published blogs
+// are visible to readers.
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusScheduled Status = "scheduled"
+	StatusPublished Status = "published"
+	StatusArchived  Status = "archived"
+)
+
+func parseStatus(s string) (Status, error) {
+	switch status := Status(s); status {
+	case StatusDraft, StatusScheduled, StatusPublished, StatusArchived:
+		return status, nil
+	case "":
+		return StatusDraft, nil
+	default:
+		return "", fmt.Errorf("unknown status %q", s)
+	}
+}
+
+// storedTime rounds t the way MongoDB stores it.
+func storedTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	stored := t.UTC().Truncate(time.Millisecond)
+	return &stored
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TagRepository counts and manages the tags blogs carry. Blogs store their
+// tags as NormalizeTag leaves them.
+type TagRepository interface {
+	// ListTags counts the blogs outside the trash carrying each tag, the
+	// most used tags first.
+	ListTags(ctx context.Context, opts TagOptions) ([]*TagCount, error)
+
+	// MergeTags replaces the tags from with into on every blog, those in the
+	// trash too, and returns how many blogs it changed. Renaming a tag is
+	// merging it alone into another. The blogs count as updated but get no
+	// revision, as their authors did not change them.
+	MergeTags(ctx context.Context, from []string, into string) (int64, error)
+}
+
+// TagOptions selects the tags ListTags counts. The zero value counts every
+// tag on every blog.
+type TagOptions struct {
+	// Prefix counts only the tags starting with it.
+	Prefix   string
+	Statuses []Status
+
+	// Limit is how many tags are listed, all of them when zero.
+	Limit int
+}
+
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// NormalizeTag is the form tags are stored and looked up in: lower case,
+// with the spaces around and between words trimmed to one.
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
+
+// NormalizeTags normalizes each of tags, dropping the ones left empty and
+// those that turn out the same as an earlier one.
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// normalizeTagUpdate normalizes the tags an update sets, if it sets any.
+func normalizeTagUpdate(tags *[]string) *[]string {
+	if tags == nil {
+		return nil
+	}
+	normalized := NormalizeTags(*tags)
+	return &normalized
+}
+
+// mergeSources are the tags a merge of from into replaces: each as given and
+// normalized, so tags stored before they were normalized can be merged too.
+func mergeSources(from []string, into string) []string {
+	var sources []string
+	for _, tag := range from {
+		for _, source := range []string{strings.TrimSpace(tag), NormalizeTag(tag)} {
+			if source != "" && source != into && !slices.Contains(sources, source) {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// mergeTags returns tags with those in from replaced by into, keeping the
+// order and the first of any that end up the same.
+func mergeTags(tags, from []string, into string) []string {
+	merged := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if slices.Contains(from, tag) {
+			tag = into
+		}
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+func (s *MongoBlogRepository) ListTags(ctx context.Context, opts TagOptions) ([]*TagCount, error) {
+	match := bson.D{{Key: "deleted_at", Value: notTrashed}}
+	if len(opts.Statuses) > 0 {
+		match = append(match, bson.E{Key: "status", Value: bson.M{"$in": opts.Statuses}})
+	}
+	prefix := bson.M{"$regex": "^" + regexp.QuoteMeta(opts.Prefix)}
+	if opts.Prefix != "" {
+		match = append(match, bson.E{Key: "tags", Value: prefix})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$tags"}},
+	}
+	if opts.Prefix != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"tags": prefix}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	)
+	if opts.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: opts.Limit}})
+	}
+
+	cur, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tags - %w", err)
+	}
+
+	counts := []*TagCount{}
+	if err := cur.All(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("failed to decode tags - %w", err)
+	}
+	return counts, nil
+}
+
+// MergeTags rewrites the tags of every blog in one update, which works out
+// each blog's new tags from those it has as it is written, so no blog keeps
+// an old tag when another update races it. The words search finds the blogs
+// by are worked out again afterwards.
+func (s *MongoBlogRepository) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	into = NormalizeTag(into)
+	sources := mergeSources(from, into)
+	if len(sources) == 0 {
+		return 0, nil
+	}
+
+	// $literal keeps tags starting with $ from being read as field paths.
+	tag := bson.M{"$cond": bson.A{
+		bson.M{"$in": bson.A{"$$this", bson.M{"$literal": sources}}},
+		bson.M{"$literal": into},
+		"$$this",
+	}}
+	tags := bson.M{"$reduce": bson.M{
+		"input":        "$tags",
+		"initialValue": bson.A{},
+		"in": bson.M{"$let": bson.M{
+			"vars": bson.M{"tag": tag},
+			"in": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$$tag", "$$value"}},
+				"$$value",
+				bson.M{"$concatArrays": bson.A{"$$value", bson.A{"$$tag"}}},
+			}},
+		}},
+	}}
+
+	result, err := s.collection.UpdateMany(ctx,
+		bson.M{"tags": bson.M{"$in": sources}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"tags":       tags,
+			"updated_at": now(),
+			"version":    bson.M{"$add": bson.A{"$version", 1}},
+		}}}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge tags into %v - %w", into, err)
+	}
+	if result.ModifiedCount == 0 {
+		return 0, nil
+	}
+
+	cur, err := s.collection.Find(ctx, bson.M{"tags": into}, options.Find().SetProjection(bson.M{"search_terms": 0}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find blogs tagged %v - %w", into, err)
+	}
+	blogs, err := decodeBlogs(ctx, cur)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range blogs {
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": bson.M{"search_terms": searchTerms(b)}})
+		if err != nil {
+			return 0, fmt.Errorf("failed to index blog %v - %w", b.ID.Hex(), err)
+		}
+	}
+
+	return result.ModifiedCount, nil
+}
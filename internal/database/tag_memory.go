@@ -0,0 +1,66 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+)
+
+func (s *MemoryBlogRepository) ListTags(ctx context.Context, opts TagOptions) ([]*TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]*TagCount{}
+	for _, b := range s.blogs {
+		if b.DeletedAt != nil || len(opts.Statuses) > 0 && !slices.Contains(opts.Statuses, b.Status) {
+			continue
+		}
+		for _, tag := range b.Tags {
+			if !strings.HasPrefix(tag, opts.Prefix) {
+				continue
+			}
+			if counts[tag] == nil {
+				counts[tag] = &TagCount{Tag: tag}
+			}
+			counts[tag].Count++
+		}
+	}
+
+	list := make([]*TagCount, 0, len(counts))
+	for _, count := range counts {
+		list = append(list, count)
+	}
+	sortTagCounts(list)
+	if opts.Limit > 0 && len(list) > opts.Limit {
+		list = list[:opts.Limit]
+	}
+	return list, nil
+}
+
+func (s *MemoryBlogRepository) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	into = NormalizeTag(into)
+	sources := mergeSources(from, into)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var merged int64
+	updatedAt := now()
+	for _, b := range s.blogs {
+		if !slices.ContainsFunc(b.Tags, func(tag string) bool { return slices.Contains(sources, tag) }) {
+			continue
+		}
+		b.Tags = mergeTags(b.Tags, sources, into)
+		b.UpdatedAt = updatedAt
+		b.Version++
+		merged++
+	}
+	return merged, nil
+}
+
+func sortTagCounts(counts []*TagCount) {
+	slices.SortFunc(counts, func(a, b *TagCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Tag, b.Tag))
+	})
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/dto"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, create dto.UserCreateDTO) (*User, error)
+	GetUser(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUser(ctx context.Context, update dto.UserUpdateDTO) (*User, error)
+
+	// SetPassword and SetRoles move the user's TokenVersion on, so tokens
+	// issued before the change stop working.
+	SetPassword(ctx context.Context, id string, hash string) error
+	SetRoles(ctx context.Context, change dto.UserRolesDTO) (*User, error)
+
+	RevokeToken(ctx context.Context, id string, expiresAt time.Time) error
+	TokenRevoked(ctx context.Context, id string) (bool, error)
+
+	CreateAPIKey(ctx context.Context, create dto.APIKeyCreateDTO) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) (*APIKey, error)
+	TouchAPIKey(ctx context.Context, id string, t time.Time) error
+}
+
+// User is an account that can sign in. Emails are unique and stored in lower
+// case, so they match however they are typed.
+type User struct {
+	ID           primitive.ObjectID `bson:"_id" json:"id"`
+	Email        string             `bson:"email" json:"email"`
+	Name         string             `bson:"name" json:"name"`
+	Bio          string             `bson:"bio,omitempty" json:"bio"`
+	AvatarURL    string             `bson:"avatar_url,omitempty" json:"avatarUrl"`
+	PasswordHash string             `bson:"password_hash" json:"-"`
+	Roles        []auth.Role        `bson:"roles" json:"roles"`
+	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updatedAt"`
+
+	// TokenVersion is what the tokens of the user have to carry to be
+	// accepted.
+	TokenVersion int `bson:"token_version,omitempty" json:"-"`
+}
+
+// Principal is whom a token issued to u speaks for.
+func (u *User) Principal() auth.Principal {
+	return auth.Principal{ID: u.ID.Hex(), Name: u.Name, Roles: slices.Clone(u.Roles), TokenVersion: u.TokenVersion}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func newUser(create dto.UserCreateDTO) User {
+	createdAt := now()
+	return User{
+		ID:           primitive.NewObjectID(),
+		Email:        normalizeEmail(create.Email),
+		Name:         create.Name,
+		PasswordHash: create.PasswordHash,
+		Roles:        slices.Clone(create.Roles),
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+	}
+}
+
+type MongoUserRepository struct {
+	users   *mongo.Collection
+	revoked *mongo.Collection
+	keys    *mongo.Collection
+}
+
+// NewUsers returns the users stored in the same database as blogs.
+func NewUsers(blogs *MongoBlogRepository) (*MongoUserRepository, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	db := blogs.collection.Database()
+	repository := &MongoUserRepository{
+		users:   db.Collection("users"),
+		revoked: db.Collection("revoked_tokens"),
+		keys:    db.Collection("api_keys"),
+	}
+
+	_, err := repository.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user indexes - %w", err)
+	}
+
+	_, err = repository.revoked.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create revoked token indexes - %w", err)
+	}
+
+	_, err = repository.keys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key indexes - %w", err)
+	}
+
+	return repository, nil
+}
+
+func (s *MongoUserRepository) CreateUser(ctx context.Context, create dto.UserCreateDTO) (*User, error) {
+	user := newUser(create)
+
+	if _, err := s.users.InsertOne(ctx, user); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("email %v is taken - %w", user.Email, ErrConflict)
+		}
+		return nil, fmt.Errorf("failed to insert user - %w", err)
+	}
+
+	return &user, nil
+}
+
+func (s *MongoUserRepository) GetUser(ctx context.Context, id string) (*User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var user *User
+	if err := s.users.FindOne(ctx, bson.M{"_id": objID}).Decode(&user); err != nil {
+		return nil, notFound(id, err)
+	}
+	return user, nil
+}
+
+func (s *MongoUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	var user *User
+	if err := s.users.FindOne(ctx, bson.M{"email": normalizeEmail(email)}).Decode(&user); err != nil {
+		return nil, notFound(email, err)
+	}
+	return user, nil
+}
+
+func (s *MongoUserRepository) UpdateUser(ctx context.Context, update dto.UserUpdateDTO) (*User, error) {
+	set := bson.M{}
+	if update.Email != nil {
+		set["email"] = normalizeEmail(*update.Email)
+	}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Bio != nil {
+		set["bio"] = *update.Bio
+	}
+	if update.AvatarURL != nil {
+		set["avatar_url"] = *update.AvatarURL
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("cannot update id %v - %w", update.Id, ErrNoFieldsToUpdate)
+	}
+	set["updated_at"] = now()
+
+	return s.update(ctx, update.Id, bson.M{"$set": set})
+}
+
+func (s *MongoUserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	_, err := s.update(ctx, id, bson.M{"$set": bson.M{"password_hash": hash, "updated_at": now()}, "$inc": bson.M{"token_version": 1}})
+	return err
+}
+
+func (s *MongoUserRepository) SetRoles(ctx context.Context, change dto.UserRolesDTO) (*User, error) {
+	return s.update(ctx, change.Id, bson.M{"$set": bson.M{"roles": change.Roles, "updated_at": now()}, "$inc": bson.M{"token_version": 1}})
+}
+
+func (s *MongoUserRepository) update(ctx context.Context, id string, update bson.M) (*User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	var user *User
+	err = s.users.FindOneAndUpdate(ctx, bson.M{"_id": objID}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("email of id %v is taken - %w", id, ErrConflict)
+	}
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+	return user, nil
+}
+
+func (s *MongoUserRepository) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := s.revoked.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"expires_at": expiresAt}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to revoke token - %w", err)
+	}
+	return nil
+}
+
+// TokenRevoked reports whether the token with id was revoked. Mongo drops a
+// revocation once its token has expired, when the token is refused anyway.
+func (s *MongoUserRepository) TokenRevoked(ctx context.Context, id string) (bool, error) {
+	err := s.revoked.FindOne(ctx, bson.M{"_id": id}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query revoked token - %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"blog-platform/internal/dto"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUserRepository is a UserRepository that keeps every user in process
+// memory, the counterpart of MemoryBlogRepository.
+type MemoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[primitive.ObjectID]*User
+	emails map[string]primitive.ObjectID
+
+	// revoked maps the id of every revoked token to when it expires.
+	revoked map[string]time.Time
+
+	keys map[primitive.ObjectID]*APIKey
+}
+
+func NewMemoryUsers() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:   make(map[primitive.ObjectID]*User),
+		emails:  make(map[string]primitive.ObjectID),
+		revoked: make(map[string]time.Time),
+		keys:    make(map[primitive.ObjectID]*APIKey),
+	}
+}
+
+func (s *MemoryUserRepository) CreateUser(ctx context.Context, create dto.UserCreateDTO) (*User, error) {
+	user := newUser(create)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, taken := s.emails[user.Email]; taken {
+		return nil, fmt.Errorf("email %v is taken - %w", user.Email, ErrConflict)
+	}
+	s.users[user.ID] = &user
+	s.emails[user.Email] = user.ID
+
+	return cloneUser(&user), nil
+}
+
+func (s *MemoryUserRepository) GetUser(ctx context.Context, id string) (*User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[objID]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.emails[normalizeEmail(email)]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", email, ErrNotFound)
+	}
+	return cloneUser(s.users[id]), nil
+}
+
+func (s *MemoryUserRepository) UpdateUser(ctx context.Context, update dto.UserUpdateDTO) (*User, error) {
+	if update.Email == nil && update.Name == nil && update.Bio == nil && update.AvatarURL == nil {
+		return nil, fmt.Errorf("cannot update id %v - %w", update.Id, ErrNoFieldsToUpdate)
+	}
+
+	return s.update(update.Id, func(user *User) error {
+		if update.Email != nil {
+			email := normalizeEmail(*update.Email)
+			if owner, taken := s.emails[email]; taken && owner != user.ID {
+				return fmt.Errorf("email of id %v is taken - %w", update.Id, ErrConflict)
+			}
+			delete(s.emails, user.Email)
+			s.emails[email] = user.ID
+			user.Email = email
+		}
+		if update.Name != nil {
+			user.Name = *update.Name
+		}
+		if update.Bio != nil {
+			user.Bio = *update.Bio
+		}
+		if update.AvatarURL != nil {
+			user.AvatarURL = *update.AvatarURL
+		}
+		return nil
+	})
+}
+
+func (s *MemoryUserRepository) SetPassword(ctx context.Context, id string, hash string) error {
+	_, err := s.update(id, func(user *User) error {
+		user.PasswordHash = hash
+		user.TokenVersion++
+		return nil
+	})
+	return err
+}
+
+func (s *MemoryUserRepository) SetRoles(ctx context.Context, change dto.UserRolesDTO) (*User, error) {
+	return s.update(change.Id, func(user *User) error {
+		user.Roles = slices.Clone(change.Roles)
+		user.TokenVersion++
+		return nil
+	})
+}
+
+// update applies change to the user with id under the write lock.
+func (s *MemoryUserRepository) update(id string, change func(*User) error) (*User, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse id %v - %w", id, ErrInvalidID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[objID]
+	if !ok {
+		return nil, fmt.Errorf("cannot find id %v - %w", id, ErrNotFound)
+	}
+	if err := change(user); err != nil {
+		return nil, err
+	}
+	user.UpdatedAt = now()
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserRepository) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Forget revocations of tokens that have expired since, as Mongo does.
+	current := time.Now()
+	maps.DeleteFunc(s.revoked, func(_ string, expiry time.Time) bool { return expiry.Before(current) })
+	s.revoked[id] = expiresAt
+	return nil
+}
+
+func (s *MemoryUserRepository) TokenRevoked(ctx context.Context, id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, revoked := s.revoked[id]
+	return revoked, nil
+}
+
+func cloneUser(u *User) *User {
+	c := *u
+	c.Roles = slices.Clone(u.Roles)
+	return &c
+}
@@ -0,0 +1,206 @@
+// Package diff compares two versions of a text and describes how to turn one
+// into the other, line by line or word by word.
+package diff
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// MaxDistance is the most tokens a comparison inserts and deletes before it
+// gives up looking for a shortest edit script. Texts further apart than that
+// are shown as the old text replaced by the new, past what they share at
+// either end, so the work stays bounded however different they are.
+const MaxDistance = 1000
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is a run of text that both versions share, or that only the new
+// (Insert) or the old (Delete) version has. Joining the Equal and Delete
+// edits gives the old text back, joining the Equal and Insert edits the new.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines compares a and b line by line. Every line keeps its line break. It
+// fails only when ctx is done before the comparison is.
+func Lines(ctx context.Context, a, b string) ([]Edit, error) {
+	return compare(ctx, strings.SplitAfter(a, "\n"), strings.SplitAfter(b, "\n"))
+}
+
+// Words compares a and b word by word. Runs of whitespace count as words of
+// their own, so a change in spacing shows up as well.
+func Words(ctx context.Context, a, b string) ([]Edit, error) {
+	return compare(ctx, words(a), words(b))
+}
+
+func words(s string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != isSpaceAt(s, start) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+func isSpaceAt(s string, i int) bool {
+	for _, r := range s[i:] {
+		return unicode.IsSpace(r)
+	}
+	return false
+}
+
+// compare diffs a and b and merges neighbouring tokens with the same
+// operation into one edit. What the two share at either end is set aside
+// first, which is cheap and leaves less for Myers' algorithm.
+func compare(ctx context.Context, a, b []string) ([]Edit, error) {
+	a, b = dropEmpty(a), dropEmpty(b)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	middle, err := shortest(ctx, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []Edit
+	for _, token := range a[:prefix] {
+		edits = append(edits, Edit{Equal, token})
+	}
+	edits = append(edits, middle...)
+	for _, token := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, token})
+	}
+	return merge(edits), nil
+}
+
+// shortest finds a shortest edit script from a to b with Myers' algorithm,
+// or replaces a with b when none is within MaxDistance. Its memory grows
+// with the square of the distance, which is what the limit bounds.
+func shortest(ctx context.Context, a, b []string) ([]Edit, error) {
+	n, m := len(a), len(b)
+	limit := min(n+m, MaxDistance)
+
+	// v[k+offset] is the furthest x reached on diagonal k. trace keeps the
+	// part of v each round started from, which is all backtracking needs.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace), nil
+			}
+		}
+	}
+	return replace(a, b), nil
+}
+
+// replace deletes every token of a and inserts every token of b.
+func replace(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, token := range a {
+		edits = append(edits, Edit{Delete, token})
+	}
+	for _, token := range b {
+		edits = append(edits, Edit{Insert, token})
+	}
+	return edits
+}
+
+// backtrack walks trace from the end of both sequences to their start and
+// returns the edits in order.
+func backtrack(a, b []string, trace [][]int) []Edit {
+	var edits []Edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x, y = x-1, y-1
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Insert, b[y-1]})
+			} else {
+				edits = append(edits, Edit{Delete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func merge(edits []Edit) []Edit {
+	merged := []Edit{}
+	for _, e := range edits {
+		if last := len(merged) - 1; last >= 0 && merged[last].Op == e.Op {
+			merged[last].Text += e.Text
+			continue
+		}
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// dropEmpty removes the empty token strings.SplitAfter leaves after a final
+// line break.
+func dropEmpty(tokens []string) []string {
+	if len(tokens) > 0 && tokens[len(tokens)-1] == "" {
+		return tokens[:len(tokens)-1]
+	}
+	return tokens
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apply rebuilds the old and the new text from edits.
+func apply(edits []Edit) (string, string) {
+	var old, new strings.Builder
+	for _, e := range edits {
+		if e.Op != Insert {
+			old.WriteString(e.Text)
+		}
+		if e.Op != Delete {
+			new.WriteString(e.Text)
+		}
+	}
+	return old.String(), new.String()
+}
+
+func lines(t *testing.T, a, b string) []Edit {
+	t.Helper()
+	edits, err := Lines(context.Background(), a, b)
+	require.NoError(t, err)
+	return edits
+}
+
+func wordsOf(t *testing.T, a, b string) []Edit {
+	t.Helper()
+	edits, err := Words(context.Background(), a, b)
+	require.NoError(t, err)
+	return edits
+}
+
+func TestLines(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\n"
+	b := "one\n2\nthree\nfour\nfive\n"
+
+	edits := lines(t, a, b)
+	assert.Equal(t, []Edit{
+		{Equal, "one\n"},
+		{Delete, "two\n"},
+		{Insert, "2\n"},
+		{Equal, "three\nfour\n"},
+		{Insert, "five\n"},
+	}, edits)
+}
+
+func TestWords(t *testing.T) {
+	edits := wordsOf(t, "the quick brown fox", "the slow brown  fox jumps")
+	assert.Equal(t, []Edit{
+		{Equal, "the "},
+		{Delete, "quick"},
+		{Insert, "slow"},
+		{Equal, " brown"},
+		{Delete, " "},
+		{Insert, "  "},
+		{Equal, "fox"},
+		{Insert, " jumps"},
+	}, edits)
+}
+
+func TestEdgeCases(t *testing.T) {
+	assert.Equal(t, []Edit{}, lines(t, "", ""))
+	assert.Equal(t, []Edit{{Equal, "same\n"}}, lines(t, "same\n", "same\n"))
+	assert.Equal(t, []Edit{{Insert, "new\n"}}, lines(t, "", "new\n"))
+	assert.Equal(t, []Edit{{Delete, "old"}}, wordsOf(t, "old", ""))
+	assert.Equal(t, []Edit{{Delete, "last"}, {Insert, "last\n"}}, lines(t, "last", "last\n"))
+}
+
+func TestEditsRebuildBothTexts(t *testing.T) {
+	pairs := [][2]string{
+		{"a\nb\nc\nd\ne\n", "x\nb\nc\ny\ne\nz\n"},
+		{"Café au lait\nsans sucre", "Café noir\navec sucre\n"},
+		{strings.Repeat("line\n", 50), strings.Repeat("line\nother\n", 30)},
+		{"", "only new"},
+	}
+	for _, p := range pairs {
+		for _, edits := range [][]Edit{lines(t, p[0], p[1]), wordsOf(t, p[0], p[1])} {
+			old, new := apply(edits)
+			assert.Equal(t, p[0], old)
+			assert.Equal(t, p[1], new)
+		}
+	}
+}
+
+func TestFarApartTextsAreReplaced(t *testing.T) {
+	var a, b strings.Builder
+	for i := range MaxDistance {
+		a.WriteString("old " + strings.Repeat("x", i%7) + "\n")
+		b.WriteString("new " + strings.Repeat("y", i%5) + "\n")
+	}
+	old, new := "intro\n"+a.String()+"outro\n", "intro\n"+b.String()+"outro\n"
+
+	edits := lines(t, old, new)
+	assert.Equal(t, []Edit{
+		{Equal, "intro\n"},
+		{Delete, a.String()},
+		{Insert, b.String()},
+		{Equal, "outro\n"},
+	}, edits)
+}
+
+func TestStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Lines(ctx, "a\nb\n", "c\nd\n")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = Words(ctx, "one two", "three four")
+	assert.ErrorIs(t, err, context.Canceled)
+}
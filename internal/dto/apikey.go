@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"blog-platform/internal/auth"
+	"time"
+)
+
+type APIKeyRequestDTO struct {
+	Name      string       `json:"name" validate:"required,max=100"`
+	UserID    string       `json:"userId" validate:"omitempty,mongodb"`
+	Scopes    []auth.Scope `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write comments:write comments:moderate"`
+	ExpiresAt *time.Time   `json:"expiresAt"`
+}
+
+type APIKeyCreateDTO struct {
+	Name      string
+	Prefix    string
+	Hash      string
+	UserID    string
+	Scopes    []auth.Scope
+	ExpiresAt time.Time
+}
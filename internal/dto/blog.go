@@ -1,16 +1,83 @@
 package dto
 
+import "time"
+
 type BlogUpdateDTO struct {
-	Id       string    `validate:"required"`
-	Title    *string   `json:"title"`
-	Category *string   `json:"category"`
-	Content  *string   `json:"content"`
-	Tags     *[]string `json:"tags"`
+	Id            string    `json:"-" validate:"required"`
+	Title         *string   `json:"title"`
+	Category      *string   `json:"category"`
+	Content       *string   `json:"content"`
+	ContentFormat *string   `json:"contentFormat" validate:"omitempty,oneof=markdown html plain"`
+	Tags          *[]string `json:"tags"`
+	Slug          *string   `json:"slug" validate:"omitempty,slug"`
+	Author        string    `json:"-"`
+
+	// Version, when set, makes the update fail unless the blog is still at
+	// this version.
+	Version *int `json:"-"`
+}
+
+type BlogDeleteDTO struct {
+	Id      string `json:"-" validate:"required"`
+	Version *int   `json:"-"`
 }
 
 type BlogCreateDto struct {
-	Title    string   `json:"title" validate:"required"`
-	Category string   `json:"category" validate:"required"`
-	Content  string   `json:"content" validate:"required"`
-	Tags     []string `json:"tags" validate:"required"`
+	Title         string     `json:"title" validate:"required"`
+	Category      string     `json:"category" validate:"required"`
+	Content       string     `json:"content" validate:"required"`
+	ContentFormat string     `json:"contentFormat" validate:"omitempty,oneof=markdown html plain"`
+	Tags          []string   `json:"tags" validate:"required"`
+	Slug          string     `json:"slug" validate:"omitempty,slug"`
+	Author        string     `json:"-"`
+	CoAuthors     []string   `json:"coAuthors" validate:"omitempty,max=10,dive,mongodb"`
+	Status        string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt     *time.Time `json:"publishAt" validate:"required_if=Status scheduled"`
+}
+
+type BlogStatusDTO struct {
+	Id        string     `json:"-" validate:"required"`
+	Status    string     `json:"-" validate:"oneof=draft scheduled published archived"`
+	PublishAt *time.Time `json:"publishAt" validate:"required_if=Status scheduled"`
+}
+
+type BlogListQuery struct {
+	Term          string     `query:"term"`
+	Limit         int        `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor        string     `query:"cursor"`
+	Sort          string     `query:"sort" validate:"omitempty,oneof=createdAt updatedAt title"`
+	Order         string     `query:"order" validate:"omitempty,oneof=asc desc"`
+	Category      string     `query:"category"`
+	Author        string     `query:"author" validate:"omitempty,mongodb"`
+	Tags          []string   `query:"tag"`
+	TagMatch      string     `query:"tagMatch" validate:"omitempty,oneof=any all"`
+	CreatedBefore *time.Time `query:"createdBefore"`
+	CreatedAfter  *time.Time `query:"createdAfter"`
+	View          string     `query:"view" validate:"omitempty,oneof=full summary"`
+}
+
+type TrashQuery struct {
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+}
+
+type SearchQuery struct {
+	Q      string `query:"q" validate:"required"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Offset int    `query:"offset" validate:"omitempty,min=0"`
+}
+
+type RevisionDiffQuery struct {
+	From int    `query:"from" validate:"required,min=1"`
+	To   int    `query:"to" validate:"required,min=1"`
+	Mode string `query:"mode" validate:"omitempty,oneof=line word"`
+}
+
+type AuthorPostsQuery struct {
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+}
+
+type RelatedQuery struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=20"`
 }
@@ -0,0 +1,18 @@
+package dto
+
+type CategoryCreateDTO struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Slug        string `json:"slug" validate:"omitempty,slug"`
+	Description string `json:"description" validate:"max=1000"`
+	ParentID    string `json:"parentId" validate:"omitempty,mongodb"`
+}
+
+type CategoryUpdateDTO struct {
+	Id          string  `json:"-" validate:"required"`
+	Name        *string `json:"name" validate:"omitnil,min=1,max=100"`
+	Slug        *string `json:"slug" validate:"omitnil,slug"`
+	Description *string `json:"description" validate:"omitnil,max=1000"`
+
+	// ParentID moves the category below another, or to the top when empty.
+	ParentID *string `json:"parentId" validate:"omitnil,len=0|mongodb"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+type CommentCreateDTO struct {
+	BlogID     string `json:"-" validate:"required"`
+	ParentID   string `json:"parentId" validate:"omitempty,mongodb"`
+	Body       string `json:"body" validate:"required,max=5000"`
+	AuthorID   string `json:"-"`
+	AuthorName string `json:"-"`
+
+	// Status and SpamScore are what the spam filter made of Body.
+	Status    string  `json:"-"`
+	SpamScore float64 `json:"-"`
+}
+
+type CommentUpdateDTO struct {
+	Id   string `json:"-" validate:"required"`
+	Body string `json:"body" validate:"required,max=5000"`
+
+	// Status, when set, replaces the comment's status along with its
+	// SpamScore, for edits the spam filter holds back.
+	Status    string  `json:"-"`
+	SpamScore float64 `json:"-"`
+}
+
+type CommentModerationDTO struct {
+	Ids       []string `json:"ids" validate:"required,min=1,max=100,dive,mongodb"`
+	Status    string   `json:"-" validate:"required,oneof=approved rejected spam"`
+	Moderator string   `json:"-"`
+}
+
+type ModerationQueueQuery struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+type CommentListQuery struct {
+	Sort   string `query:"sort" validate:"omitempty,oneof=newest oldest top"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+}
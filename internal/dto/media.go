@@ -0,0 +1,39 @@
+package dto
+
+// MediaCreateDTO describes an uploaded image once it has been stored: the
+// original and the variants made of it.
+type MediaCreateDTO struct {
+	Hash        string
+	Filename    string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+	Uploader    string
+	Variants    []MediaVariantDTO
+}
+
+type MediaVariantDTO struct {
+	Name        string
+	Key         string
+	ContentType string
+	Width       int
+	Height      int
+	Size        int64
+}
+
+type MediaListQuery struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+type BlogCoverDTO struct {
+	Id string `json:"-" validate:"required"`
+
+	// MediaID is the image to show as the cover. The repository takes the
+	// cover away when it is empty.
+	MediaID string `json:"mediaId" validate:"required,mongodb"`
+
+	// Version, when set, makes the change fail unless the blog is still at
+	// this version.
+	Version *int `json:"-"`
+}
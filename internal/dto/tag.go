@@ -0,0 +1,15 @@
+package dto
+
+type TagRenameDTO struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type TagMergeDTO struct {
+	Tags []string `json:"tags" validate:"required,min=1,max=100,dive,required"`
+	Into string   `json:"into" validate:"required,max=100"`
+}
+
+type TagAutocompleteQuery struct {
+	Prefix string `query:"prefix" validate:"required"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+import "blog-platform/internal/auth"
+
+type RegisterDTO struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,min=8,password"`
+}
+
+type LoginDTO struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type PasswordChangeDTO struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8,password"`
+}
+
+type UserCreateDTO struct {
+	Email        string
+	Name         string
+	PasswordHash string
+	Roles        []auth.Role
+}
+
+type UserUpdateDTO struct {
+	Id        string  `json:"-" validate:"required"`
+	Email     *string `json:"email" validate:"omitempty,email,max=254"`
+	Name      *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Bio       *string `json:"bio" validate:"omitempty,max=500"`
+	AvatarURL *string `json:"avatarUrl" validate:"omitempty,http_url,max=2048"`
+}
+
+type UserRolesDTO struct {
+	Id    string      `json:"-" validate:"required"`
+	Roles []auth.Role `json:"roles" validate:"required,min=1,dive,oneof=admin editor author reader"`
+}
@@ -0,0 +1,123 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sample = Feed{
+	Title:       "Notes & Queries",
+	Description: "The newest posts",
+	Link:        "https://blog.example/",
+	URL:         "https://blog.example/feed.xml",
+	Updated:     time.Date(2025, time.April, 15, 10, 0, 0, 0, time.UTC),
+	Items: []Item{{
+		ID:         "https://blog.example/posts/1",
+		Title:      "Less <than> more",
+		Link:       "https://blog.example/posts/by-slug/less-than-more",
+		Summary:    "An excerpt",
+		HTML:       "<p>Hello <em>there</em></p>",
+		Authors:    []string{"Ada"},
+		Categories: []string{"Tech", "go"},
+		Published:  time.Date(2025, time.April, 14, 9, 0, 0, 0, time.UTC),
+		Updated:    time.Date(2025, time.April, 15, 10, 0, 0, 0, time.UTC),
+	}},
+}
+
+func TestRSS(t *testing.T) {
+	body, err := RSS(sample)
+	require.NoError(t, err)
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title         string `xml:"title"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title   string   `xml:"title"`
+				Link    string   `xml:"link"`
+				GUID    string   `xml:"guid"`
+				PubDate string   `xml:"pubDate"`
+				Content string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+				Creator string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Tags    []string `xml:"category"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc), string(body))
+
+	assert.Equal(t, "2.0", doc.Version)
+	assert.Equal(t, "Notes & Queries", doc.Channel.Title)
+	assert.Equal(t, "Tue, 15 Apr 2025 10:00:00 +0000", doc.Channel.LastBuildDate)
+	require.Len(t, doc.Channel.Items, 1)
+	item := doc.Channel.Items[0]
+	assert.Equal(t, "Less <than> more", item.Title)
+	assert.Equal(t, "https://blog.example/posts/by-slug/less-than-more", item.Link)
+	assert.Equal(t, "https://blog.example/posts/1", item.GUID)
+	assert.Equal(t, "Mon, 14 Apr 2025 09:00:00 +0000", item.PubDate)
+	assert.Equal(t, "<p>Hello <em>there</em></p>", item.Content)
+	assert.Equal(t, "Ada", item.Creator)
+	assert.Equal(t, []string{"Tech", "go"}, item.Tags)
+	assert.Contains(t, string(body), `<guid isPermaLink="false">`)
+}
+
+func TestAtom(t *testing.T) {
+	body, err := Atom(sample)
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Author  string   `xml:"author>name"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Updated string `xml:"updated"`
+			Author  string `xml:"author>name"`
+			Content struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc), string(body))
+
+	assert.Equal(t, "https://blog.example/feed.xml", doc.ID)
+	assert.Equal(t, "2025-04-15T10:00:00Z", doc.Updated)
+	assert.Equal(t, "Notes & Queries", doc.Author, "the feed stands in for entries without an author")
+	require.Len(t, doc.Entries, 1)
+	assert.Equal(t, "https://blog.example/posts/1", doc.Entries[0].ID)
+	assert.Equal(t, "Ada", doc.Entries[0].Author)
+	assert.Equal(t, "html", doc.Entries[0].Content.Type)
+	assert.Equal(t, "<p>Hello <em>there</em></p>", doc.Entries[0].Content.Value)
+}
+
+func TestJSON(t *testing.T) {
+	body, err := JSON(sample)
+	require.NoError(t, err)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(body, &doc))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", doc["version"])
+	assert.Equal(t, "https://blog.example/feed.xml", doc["feed_url"])
+	assert.Equal(t, []any{map[string]any{
+		"id":             "https://blog.example/posts/1",
+		"url":            "https://blog.example/posts/by-slug/less-than-more",
+		"title":          "Less <than> more",
+		"content_html":   "<p>Hello <em>there</em></p>",
+		"summary":        "An excerpt",
+		"date_published": "2025-04-14T09:00:00Z",
+		"date_modified":  "2025-04-15T10:00:00Z",
+		"authors":        []any{map[string]any{"name": "Ada"}},
+		"tags":           []any{"Tech", "go"},
+	}}, doc["items"])
+
+	empty, err := JSON(Feed{Title: "Empty"})
+	require.NoError(t, err)
+	assert.Contains(t, string(empty), `"items": []`)
+}
@@ -0,0 +1,152 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	// Decoders for the kinds of image that may be uploaded.
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxPixels is the most pixels an uploaded image may have, so that a small
+// file cannot decode to an enormous picture.
+const MaxPixels = 50_000_000
+
+// jpegQuality is the quality variants of JPEG images are encoded at.
+const jpegQuality = 85
+
+var (
+	ErrUnsupported = errors.New("unsupported media type")
+	ErrTooLarge    = errors.New("image has too many pixels")
+)
+
+// Extensions are the kinds of image that may be uploaded, by the type their
+// content sniffs as, and the extension their files are stored with.
+var Extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Size is a variant made of every uploaded image. A Size with a height is
+// cropped to fill it, one without keeps the proportions of the image.
+// Images are never enlarged: a variant wider than the image is not made.
+type Size struct {
+	Name          string
+	Width, Height int
+}
+
+var Sizes = []Size{
+	{Name: "thumbnail", Width: 200, Height: 200},
+	{Name: "small", Width: 480},
+	{Name: "medium", Width: 1024},
+	{Name: "large", Width: 2048},
+}
+
+// File is an uploaded image or one of its variants.
+type File struct {
+	Name          string
+	ContentType   string
+	Width, Height int
+	Data          []byte
+}
+
+// Sniff returns the type of image data holds, whatever the client said it
+// was, failing with ErrUnsupported unless it is one of Extensions.
+func Sniff(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := Extensions[contentType]; !ok {
+		return "", fmt.Errorf("cannot store %v - %w", contentType, ErrUnsupported)
+	}
+	return contentType, nil
+}
+
+// Process checks an uploaded image and makes its variants. The original
+// comes first, named "original" and kept as it was uploaded, followed by a
+// variant for each of Sizes it is big enough for. Variants of JPEG images are
+// JPEGs; all others are PNGs, which keep transparency.
+func Process(data []byte) ([]File, error) {
+	contentType, err := Sniff(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %v - %w", contentType, ErrUnsupported)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, fmt.Errorf("%dx%d image - %w", config.Width, config.Height, ErrTooLarge)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %v - %w", contentType, ErrUnsupported)
+	}
+
+	bounds := img.Bounds()
+	files := []File{{Name: "original", ContentType: contentType, Width: bounds.Dx(), Height: bounds.Dy(), Data: data}}
+	for _, size := range Sizes {
+		variant := resize(img, size)
+		if variant == nil {
+			continue
+		}
+
+		file := File{Name: size.Name, Width: variant.Bounds().Dx(), Height: variant.Bounds().Dy()}
+		var buf bytes.Buffer
+		if contentType == "image/jpeg" {
+			file.ContentType = "image/jpeg"
+			err = jpeg.Encode(&buf, variant, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			file.ContentType = "image/png"
+			err = png.Encode(&buf, variant)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %v variant - %w", size.Name, err)
+		}
+		file.Data = buf.Bytes()
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// resize returns img scaled to size, or nil when size would enlarge it.
+func resize(img image.Image, size Size) image.Image {
+	src := img.Bounds()
+	if size.Height == 0 {
+		if src.Dx() <= size.Width {
+			return nil
+		}
+		height := max(1, src.Dy()*size.Width/src.Dx())
+		dst := image.NewRGBA(image.Rect(0, 0, size.Width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+		return dst
+	}
+
+	// The largest part of the image in the proportions of size, from its
+	// middle.
+	width, height := min(size.Width, src.Dx()), min(size.Height, src.Dy())
+	crop := src
+	if src.Dx()*height > src.Dy()*width {
+		cropWidth := src.Dy() * width / height
+		crop.Min.X += (src.Dx() - cropWidth) / 2
+		crop.Max.X = crop.Min.X + cropWidth
+	} else {
+		cropHeight := src.Dx() * height / width
+		crop.Min.Y += (src.Dy() - cropHeight) / 2
+		crop.Max.Y = crop.Min.Y + cropHeight
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
@@ -0,0 +1,100 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func sizes(files []File) map[string][2]int {
+	result := map[string][2]int{}
+	for _, f := range files {
+		result[f.Name] = [2]int{f.Width, f.Height}
+	}
+	return result
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Makes variants", func(t *testing.T) {
+		data := encodePNG(t, 1200, 600)
+		files, err := Process(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, "original", files[0].Name)
+		assert.Equal(t, data, files[0].Data, "the original is kept as uploaded")
+		assert.Equal(t, map[string][2]int{
+			"original":  {1200, 600},
+			"thumbnail": {200, 200},
+			"small":     {480, 240},
+			"medium":    {1024, 512},
+		}, sizes(files), "nothing is enlarged")
+
+		for _, f := range files[1:] {
+			assert.Equal(t, "image/png", f.ContentType)
+			config, format, err := image.DecodeConfig(bytes.NewReader(f.Data))
+			require.NoError(t, err)
+			assert.Equal(t, "png", format)
+			assert.Equal(t, [2]int{f.Width, f.Height}, [2]int{config.Width, config.Height})
+		}
+	})
+
+	t.Run("Small images", func(t *testing.T) {
+		files, err := Process(encodePNG(t, 100, 50))
+		require.NoError(t, err)
+		assert.Equal(t, map[string][2]int{"original": {100, 50}, "thumbnail": {100, 50}}, sizes(files))
+	})
+
+	t.Run("JPEGs stay JPEGs", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 600, 900)), nil))
+		files, err := Process(buf.Bytes())
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string][2]int{"original": {600, 900}, "thumbnail": {200, 200}, "small": {480, 720}}, sizes(files))
+		for _, f := range files {
+			assert.Equal(t, "image/jpeg", f.ContentType)
+		}
+	})
+
+	t.Run("Sniffs the content", func(t *testing.T) {
+		_, err := Process([]byte("<html><body>not an image</body></html>"))
+		assert.ErrorIs(t, err, ErrUnsupported)
+
+		_, err = Process(append([]byte("\x89PNG\r\n\x1a\n"), "truncated"...))
+		assert.ErrorIs(t, err, ErrUnsupported)
+	})
+
+	t.Run("Refuses decompression bombs", func(t *testing.T) {
+		// A header claiming a huge image is enough; it is never decoded.
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+		data := buf.Bytes()
+		data[16], data[17], data[18], data[19] = 0, 0, 0x40, 0 // width 16384
+		data[20], data[21], data[22], data[23] = 0, 0, 0x40, 0 // height 16384
+		binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+		_, err := Process(data)
+		assert.ErrorIs(t, err, ErrTooLarge)
+	})
+}
@@ -0,0 +1,17 @@
+// Package media stores uploaded images and the resized variants made of
+// them, on the local filesystem or in an S3-compatible bucket.
+package media
+
+import (
+	"context"
+	"io"
+)
+
+// MediaStore keeps the files of uploaded media under keys such as
+// "<hash>/original.png". Get fails with an error matching fs.ErrNotExist
+// when nothing is stored under a key.
+type MediaStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
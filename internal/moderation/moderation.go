@@ -0,0 +1,174 @@
+// Package moderation decides which comments need a moderator to look at them
+// before they are shown, and learns from what moderators decide.
+package moderation
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"slices"
+	"sync"
+
+	"blog-platform/internal/search"
+)
+
+// SpamScorer rates comments for how likely they are to be spam. Comments that
+// score DefaultThreshold or more wait for a moderator.
+type SpamScorer interface {
+	// Score returns how likely text is spam, from 0 to 1.
+	Score(ctx context.Context, text string) (float64, error)
+
+	// Learn trains the scorer with a moderator's decision about text: spam
+	// when they marked it as spam, not spam when they approved it.
+	Learn(ctx context.Context, text string, spam bool) error
+
+	// Unlearn takes back what Learn was taught about text, for a decision
+	// that was changed or a comment that was edited or deleted since.
+	Unlearn(ctx context.Context, text string, spam bool) error
+}
+
+// DefaultThreshold is the score from which comments wait for a moderator.
+const DefaultThreshold = 0.5
+
+// DefaultMaxLinks is how many links a comment may hold before Filter grows
+// suspicious of it.
+const DefaultMaxLinks = 2
+
+// links finds URLs in a comment, with or without a scheme.
+var links = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// Filter is the built-in SpamScorer. A comment scores as high as the worst
+// of three signals: whether it says something on the blocklist, how many
+// links it has beyond MaxLinks, and what a naive Bayes model of the words in
+// comments moderators decided on makes of it. The model is kept in memory and
+// starts out empty.
+type Filter struct {
+	// MaxLinks is how many links a comment may have for free. Each one
+	// beyond it adds a quarter, so one too many is already suspicious.
+	MaxLinks int
+
+	blocklist [][]string
+
+	mu sync.RWMutex
+
+	// spamWords and hamWords count the comments of each kind that each word
+	// appeared in.
+	spamWords, hamWords map[string]int
+	spam, ham           int
+}
+
+// NewFilter returns a Filter that holds back comments containing any word or
+// phrase on blocklist.
+func NewFilter(blocklist []string) *Filter {
+	f := &Filter{
+		MaxLinks:  DefaultMaxLinks,
+		spamWords: make(map[string]int),
+		hamWords:  make(map[string]int),
+	}
+	for _, entry := range blocklist {
+		if words := search.Tokenize(entry); len(words) > 0 {
+			f.blocklist = append(f.blocklist, words)
+		}
+	}
+	return f
+}
+
+func (f *Filter) Score(ctx context.Context, text string) (float64, error) {
+	words := search.Tokenize(text)
+	for _, blocked := range f.blocklist {
+		if contains(words, blocked) {
+			return 1, nil
+		}
+	}
+
+	score := 0.0
+	if over := len(links.FindAllStringIndex(text, -1)) - f.MaxLinks; over > 0 {
+		score = math.Min(1, 0.25*float64(over+1))
+	}
+	return math.Max(score, f.bayes(search.Terms(text))), nil
+}
+
+func (f *Filter) Learn(ctx context.Context, text string, spam bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := f.hamWords
+	if spam {
+		counts = f.spamWords
+		f.spam++
+	} else {
+		f.ham++
+	}
+	for _, word := range search.Terms(text) {
+		counts[word]++
+	}
+	return nil
+}
+
+func (f *Filter) Unlearn(ctx context.Context, text string, spam bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts, total := f.hamWords, &f.ham
+	if spam {
+		counts, total = f.spamWords, &f.spam
+	}
+	if *total == 0 {
+		return nil
+	}
+	*total--
+	for _, word := range search.Terms(text) {
+		if counts[word] <= 1 {
+			delete(counts, word)
+		} else {
+			counts[word]--
+		}
+	}
+	return nil
+}
+
+// bayes is the probability the model gives a comment with the distinct words
+// of being spam. It combines the spamminess of every word the model has seen,
+// smoothed towards a half for rarely seen words as Gary Robinson suggests.
+// Until moderators have decided on both kinds of comment there is nothing to
+// compare, so it is 0.
+func (f *Filter) bayes(words []string) float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.spam == 0 || f.ham == 0 {
+		return 0
+	}
+
+	const strength, neutral = 1.0, 0.5
+	eta, seen := 0.0, false
+	for _, word := range words {
+		spam, ham := f.spamWords[word], f.hamWords[word]
+		if spam+ham == 0 {
+			continue
+		}
+		seen = true
+
+		inSpam := float64(spam) / float64(f.spam)
+		inHam := float64(ham) / float64(f.ham)
+		p := inSpam / (inSpam + inHam)
+		n := float64(spam + ham)
+		p = (strength*neutral + n*p) / (strength + n)
+
+		eta += math.Log(1-p) - math.Log(p)
+	}
+	if !seen {
+		return 0
+	}
+	return 1 / (1 + math.Exp(eta))
+}
+
+// contains reports whether phrase appears in words as a run.
+func contains(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
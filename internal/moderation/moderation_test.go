@@ -0,0 +1,74 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func score(t *testing.T, f *Filter, text string) float64 {
+	t.Helper()
+	s, err := f.Score(context.Background(), text)
+	require.NoError(t, err)
+	return s
+}
+
+func TestFilter(t *testing.T) {
+	t.Run("Ordinary comments pass an untrained filter", func(t *testing.T) {
+		f := NewFilter(nil)
+		assert.Zero(t, score(t, f, "Great post, thanks for writing it up!"))
+		assert.Zero(t, score(t, f, ""))
+	})
+
+	t.Run("Blocklisted words and phrases", func(t *testing.T) {
+		f := NewFilter([]string{"casino", "Cheap Pills", " "})
+		assert.Equal(t, 1.0, score(t, f, "Visit our CASINO today"))
+		assert.Equal(t, 1.0, score(t, f, "buy cheap pills here"))
+		assert.Zero(t, score(t, f, "pills are cheap"))
+		assert.Zero(t, score(t, f, "casinos are not on the list"))
+	})
+
+	t.Run("Too many links", func(t *testing.T) {
+		f := NewFilter(nil)
+		assert.Zero(t, score(t, f, "see https://a.example and www.b.example"))
+		assert.Equal(t, 0.5, score(t, f, "https://a.example http://b.example www.c.example"))
+		assert.Equal(t, 1.0, score(t, f, "https://a https://b https://c https://d https://e"))
+
+		f.MaxLinks = 0
+		assert.Equal(t, 0.5, score(t, f, "see https://a.example"))
+	})
+
+	t.Run("Learns from moderators", func(t *testing.T) {
+		f := NewFilter(nil)
+		ctx := context.Background()
+		require.NoError(t, f.Learn(ctx, "win free money now", true))
+		assert.Zero(t, score(t, f, "free money"), "nothing to compare until both kinds are seen")
+
+		require.NoError(t, f.Learn(ctx, "claim your free prize money", true))
+		require.NoError(t, f.Learn(ctx, "thanks for the clear explanation", false))
+		require.NoError(t, f.Learn(ctx, "the explanation of channels helped", false))
+
+		assert.Greater(t, score(t, f, "free money prize"), DefaultThreshold)
+		assert.Less(t, score(t, f, "a clear explanation, thanks"), DefaultThreshold)
+		assert.Zero(t, score(t, f, "completely unseen words"))
+	})
+
+	t.Run("Unlearns what it was taught", func(t *testing.T) {
+		f := NewFilter(nil)
+		ctx := context.Background()
+		require.NoError(t, f.Learn(ctx, "free money now", true))
+		require.NoError(t, f.Learn(ctx, "thanks for the explanation", false))
+		taught := score(t, f, "free money")
+
+		require.NoError(t, f.Learn(ctx, "free money is a great offer", false))
+		require.NoError(t, f.Unlearn(ctx, "free money is a great offer", false))
+		assert.Equal(t, taught, score(t, f, "free money"), "the filter is as it was before")
+
+		require.NoError(t, f.Unlearn(ctx, "free money now", true))
+		assert.Zero(t, score(t, f, "free money"), "no spam is left to compare with")
+		require.NoError(t, f.Unlearn(ctx, "free money now", true), "unlearning what is not there does nothing")
+		assert.Zero(t, score(t, f, "free money"))
+	})
+}
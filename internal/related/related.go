@@ -0,0 +1,191 @@
+// Package related recommends posts like the one being read: those sharing
+// its tags or category, and those whose content is alike by the cosine of
+// their TF-IDF vectors.
+package related
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"blog-platform/internal/cache"
+	"blog-platform/internal/search"
+)
+
+// Signal weights. Each signal is between 0 and 1, so a score is too.
+const (
+	TagWeight      = 0.4
+	CategoryWeight = 0.2
+	ContentWeight  = 0.4
+)
+
+// MaxResults is the most matches Related ranks for a document.
+const MaxResults = 50
+
+// Document is the part of a post recommendations are worked out from.
+type Document struct {
+	ID       string
+	Category string
+	Tags     []string
+	Content  string
+}
+
+// Match is a document related to another and how closely.
+type Match struct {
+	ID    string
+	Score float64
+}
+
+// Index holds the documents recommendations are picked from, with the
+// weight of each word in each of them worked out once for every lookup.
+type Index struct {
+	docs    []Document
+	byID    map[string]int
+	vectors []map[string]float64
+	idf     map[string]float64
+
+	mu      sync.Mutex
+	matches map[string][]Match
+}
+
+// NewIndex indexes docs. Each word is weighted by 1 + ln of how often it
+// occurs in a document, times ln of how rare it is across them, so words
+// every document has count for nothing.
+func NewIndex(docs []Document) *Index {
+	x := &Index{
+		docs:    docs,
+		byID:    make(map[string]int, len(docs)),
+		vectors: make([]map[string]float64, len(docs)),
+		idf:     map[string]float64{},
+		matches: map[string][]Match{},
+	}
+
+	counts := make([]map[string]int, len(docs))
+	for i, doc := range docs {
+		x.byID[doc.ID] = i
+		counts[i] = termCounts(doc.Content)
+		for term := range counts[i] {
+			x.idf[term]++
+		}
+	}
+	for term, df := range x.idf {
+		x.idf[term] = math.Log(float64(len(docs)) / df)
+	}
+	for i := range docs {
+		x.vectors[i] = x.vector(counts[i])
+	}
+	return x
+}
+
+func termCounts(content string) map[string]int {
+	counts := map[string]int{}
+	for _, word := range search.Tokenize(content) {
+		counts[word]++
+	}
+	return counts
+}
+
+// vector weighs counts against the index and scales the result to unit
+// length, so the cosine of two vectors is their dot product.
+func (x *Index) vector(counts map[string]int) map[string]float64 {
+	vector := make(map[string]float64, len(counts))
+	var norm float64
+	for term, n := range counts {
+		if w := (1 + math.Log(float64(n))) * x.idf[term]; w > 0 {
+			vector[term] = w
+			norm += w * w
+		}
+	}
+	norm = math.Sqrt(norm)
+	for term := range vector {
+		vector[term] /= norm
+	}
+	return vector
+}
+
+// Related ranks the indexed documents other than doc by how related they
+// are to it, the closest first, and returns up to limit of them. Documents
+// with nothing in common with doc are left out. The ranking of a document
+// that is in the index is kept for the next call.
+func (x *Index) Related(doc Document, limit int) []Match {
+	limit = min(limit, MaxResults)
+	i, indexed := x.byID[doc.ID]
+	if indexed {
+		x.mu.Lock()
+		matches, ok := x.matches[doc.ID]
+		x.mu.Unlock()
+		if ok {
+			return matches[:min(limit, len(matches))]
+		}
+	}
+
+	var vector map[string]float64
+	if indexed {
+		doc, vector = x.docs[i], x.vectors[i]
+	} else {
+		vector = x.vector(termCounts(doc.Content))
+	}
+
+	var matches []Match
+	for j, other := range x.docs {
+		if other.ID == doc.ID {
+			continue
+		}
+		score := TagWeight*overlap(doc.Tags, other.Tags) + ContentWeight*dot(vector, x.vectors[j])
+		if doc.Category != "" && doc.Category == other.Category {
+			score += CategoryWeight
+		}
+		if score > 0 {
+			matches = append(matches, Match{ID: other.ID, Score: score})
+		}
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.ID, b.ID))
+	})
+	matches = matches[:min(MaxResults, len(matches))]
+
+	if indexed {
+		x.mu.Lock()
+		x.matches[doc.ID] = matches
+		x.mu.Unlock()
+	}
+	return matches[:min(limit, len(matches))]
+}
+
+// overlap is the Jaccard index of two sets of tags: how many they share out
+// of how many there are between them.
+func overlap(a, b []string) float64 {
+	var shared int
+	for _, tag := range a {
+		if slices.Contains(b, tag) {
+			shared++
+		}
+	}
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func dot(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var sum float64
+	for term, w := range a {
+		sum += w * b[term]
+	}
+	return sum
+}
+
+// Cache keeps an Index until a post changes. The index does not depend on
+// who asks, so it is always kept under the same key.
+type Cache = cache.Rebuilding[*Index]
+
+// NewCache returns a Cache that rebuilds the index at least every maxAge.
+func NewCache(maxAge time.Duration) *Cache {
+	return cache.New[*Index](maxAge)
+}
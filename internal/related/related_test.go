@@ -0,0 +1,54 @@
+package related
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(matches []Match) []string {
+	var result []string
+	for _, m := range matches {
+		result = append(result, m.ID)
+	}
+	return result
+}
+
+func TestRelated(t *testing.T) {
+	docs := []Document{
+		{ID: "channels", Category: "go", Tags: []string{"concurrency", "go"}, Content: "Channels connect goroutines. Send on a channel, receive from a channel."},
+		{ID: "goroutines", Category: "go", Tags: []string{"concurrency", "go"}, Content: "Goroutines are cheap. Start goroutines and let channels connect them."},
+		{ID: "generics", Category: "go", Tags: []string{"go"}, Content: "Type parameters and constraints make generic functions."},
+		{ID: "threads", Category: "rust", Tags: []string{"concurrency"}, Content: "Threads share memory behind a mutex."},
+		{ID: "bread", Category: "cooking", Tags: []string{"baking"}, Content: "Flour, water, salt: bread."},
+	}
+	index := NewIndex(docs)
+
+	t.Run("Ranks by tags, category and content", func(t *testing.T) {
+		matches := index.Related(docs[0], 10)
+		assert.Equal(t, []string{"goroutines", "generics", "threads"}, ids(matches))
+		for _, m := range matches {
+			assert.LessOrEqual(t, m.Score, 1.0)
+		}
+	})
+
+	t.Run("Content counts on its own", func(t *testing.T) {
+		matches := index.Related(Document{ID: "draft", Content: "How channels connect goroutines"}, 10)
+		assert.ElementsMatch(t, []string{"channels", "goroutines"}, ids(matches))
+	})
+
+	t.Run("Limits the matches", func(t *testing.T) {
+		assert.Equal(t, []string{"goroutines"}, ids(index.Related(docs[0], 1)))
+		assert.Equal(t, []string{"goroutines", "generics", "threads"}, ids(index.Related(docs[0], 10)), "the ranking kept holds more than the last limit")
+	})
+
+	t.Run("Unrelated documents match nothing", func(t *testing.T) {
+		assert.Empty(t, index.Related(docs[4], 10))
+		assert.Empty(t, NewIndex(nil).Related(docs[0], 10))
+	})
+
+	t.Run("Words every document has count for nothing", func(t *testing.T) {
+		index := NewIndex([]Document{{ID: "a", Content: "the cat"}, {ID: "b", Content: "the dog"}})
+		assert.Empty(t, index.Related(Document{ID: "a", Content: "the cat"}, 10))
+	})
+}
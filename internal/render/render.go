@@ -0,0 +1,195 @@
+// Package render turns the content of a blog into HTML that is safe to put
+// on a page: Markdown is converted, HTML is sanitised, and plain text is
+// escaped. Whatever the format, the result goes through the same sanitiser,
+// so no format can smuggle in scripts.
+package render
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Format is the language the content of a blog is written in.
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+	Plain    Format = "plain"
+)
+
+// ParseFormat returns the Format named s. Content without a format is
+// Markdown.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case Markdown, HTML, Plain:
+		return format, nil
+	case "":
+		return Markdown, nil
+	default:
+		return "", fmt.Errorf("unknown content format %q", s)
+	}
+}
+
+// markdown is CommonMark with the GitHub extensions, footnotes, and code
+// highlighted into CSS classes rather than inline styles, which the sanitiser
+// would strip. Raw HTML is let through because the sanitiser deals with it.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		extension.Footnote,
+		highlighting.NewHighlighting(highlighting.WithFormatOptions(chromahtml.WithClasses(true))),
+	),
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// policy allows what readers may write on most sites, plus the markup the
+// Markdown extensions produce.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w -]+$`)).OnElements("a", "code", "div", "pre", "span")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// Render returns the HTML for source written in format, with an id on every
+// heading for a table of contents to link to. Content stored before blogs had
+// a format has none and is read as Markdown.
+func Render(format Format, source string) (string, error) {
+	var unsafe string
+	switch format {
+	case "", Markdown:
+		var b bytes.Buffer
+		if err := markdown.Convert([]byte(source), &b); err != nil {
+			return "", fmt.Errorf("failed to render markdown - %w", err)
+		}
+		unsafe = b.String()
+	case HTML:
+		unsafe = source
+	case Plain:
+		unsafe = paragraphs(source)
+	default:
+		return "", fmt.Errorf("unknown content format %q", format)
+	}
+	return anchorHeadings(policy.Sanitize(unsafe)), nil
+}
+
+// blankLines separate the paragraphs of plain text.
+var blankLines = regexp.MustCompile(`\n\s*\n`)
+
+// paragraphs escapes plain text and keeps its paragraphs and line breaks.
+func paragraphs(text string) string {
+	var b strings.Builder
+	for _, paragraph := range blankLines.Split(strings.ReplaceAll(text, "\r\n", "\n"), -1) {
+		if paragraph = strings.TrimSpace(paragraph); paragraph == "" {
+			continue
+		}
+		lines := strings.Split(html.EscapeString(paragraph), "\n")
+		fmt.Fprintf(&b, "<p>%s</p>\n", strings.Join(lines, "<br>\n"))
+	}
+	return b.String()
+}
+
+// Cache remembers the HTML of the most recently rendered blogs. Entries are
+// keyed by blog and only used for the version they were rendered at, so a
+// stale one can never be served; Forget drops one early to free its space. A
+// nil Cache renders every time.
+type Cache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cached struct {
+	key     string
+	version int
+	html    string
+}
+
+// NewCache returns a Cache that holds the HTML of up to size blogs.
+func NewCache(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Render returns the HTML of version of the blog key, rendering it from source
+// in format unless the cache already has it.
+func (c *Cache) Render(key string, version int, format Format, source string) (string, error) {
+	if c == nil {
+		return Render(format, source)
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && e.Value.(*cached).version == version {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cached).html, nil
+	}
+	c.mu.Unlock()
+
+	rendered, err := Render(format, source)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value = &cached{key: key, version: version, html: rendered}
+		c.order.MoveToFront(e)
+		return rendered, nil
+	}
+	c.entries[key] = c.order.PushFront(&cached{key: key, version: version, html: rendered})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cached).key)
+	}
+	return rendered, nil
+}
+
+// Forget drops the HTML of the blog key.
+func (c *Cache) Forget(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns how many blogs the cache holds HTML for.
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,120 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	render := func(format Format, source string) string {
+		t.Helper()
+		html, err := Render(format, source)
+		require.NoError(t, err)
+		return html
+	}
+
+	t.Run("Markdown", func(t *testing.T) {
+		assert.Equal(t, "<h1 id=\"title\">Title</h1>\n<p>Some <em>text</em>.</p>\n", render(Markdown, "# Title\n\nSome *text*."))
+		assert.Equal(t, render(Markdown, "# Title"), render("", "# Title"), "content without a format is Markdown")
+	})
+
+	t.Run("GitHub extensions", func(t *testing.T) {
+		table := render(Markdown, "| a | b |\n|:--|--:|\n| 1 | 2 |\n")
+		assert.Contains(t, table, `<th align="left">a</th>`)
+		assert.Contains(t, table, `<td align="right">2</td>`)
+
+		tasks := render(Markdown, "- [x] done\n- [ ] todo\n")
+		assert.Contains(t, tasks, `<input checked="" disabled="" type="checkbox"> done`)
+		assert.Contains(t, tasks, `<input disabled="" type="checkbox"> todo`)
+
+		assert.Contains(t, render(Markdown, "~~old~~"), "<del>old</del>")
+		assert.Contains(t, render(Markdown, "see https://go.dev"), `<a href="https://go.dev" rel="nofollow">https://go.dev</a>`)
+	})
+
+	t.Run("Footnotes", func(t *testing.T) {
+		html := render(Markdown, "Claim[^1]\n\n[^1]: Source\n")
+		assert.Contains(t, html, `<sup id="fnref:1"><a href="#fn:1" class="footnote-ref"`)
+		assert.Contains(t, html, `<li id="fn:1">`)
+	})
+
+	t.Run("Highlights fenced code", func(t *testing.T) {
+		html := render(Markdown, "```go\nfunc main() {}\n```\n")
+		assert.Contains(t, html, `<pre class="chroma">`)
+		assert.Contains(t, html, `<span class="kd">func</span>`)
+		assert.NotContains(t, html, "style=")
+	})
+
+	t.Run("Sanitises every format", func(t *testing.T) {
+		for _, format := range []Format{Markdown, HTML} {
+			html := render(format, `<p onclick="steal()">Hi</p><script>alert(1)</script><a href="javascript:steal()">x</a>`)
+			assert.NotContains(t, html, "onclick", format)
+			assert.NotContains(t, html, "script", format)
+			assert.NotContains(t, html, "javascript", format)
+		}
+		assert.Equal(t, "<p>Kept <strong>bold</strong></p>", render(HTML, "<p>Kept <strong>bold</strong></p>"))
+	})
+
+	t.Run("Plain text", func(t *testing.T) {
+		assert.Equal(t, "<p>a &lt;b&gt;<br>\nline</p>\n<p># not a heading</p>\n", render(Plain, "a <b>\r\nline\n\n \n# not a heading\n"))
+		assert.Empty(t, render(Plain, "  \n"))
+	})
+
+	t.Run("Anchors headings", func(t *testing.T) {
+		assert.Equal(t,
+			"<h2 id=\"cafe-au-lait\">Café <em>au</em> lait</h2>\n<h2 id=\"cafe-au-lait-1\">Café au lait</h2>\n<h3 id=\"section\">!</h3>\n",
+			render(Markdown, "## Café *au* lait\n## Café au lait\n### !\n"))
+		assert.Equal(t,
+			`<h1 id="intro">Intro</h1><h2 dir="ltr" id="intro-1">Intro</h2>`,
+			render(HTML, `<h1 id="intro">Intro</h1><h2 dir="ltr">Intro</h2>`))
+	})
+
+	t.Run("Unknown formats", func(t *testing.T) {
+		_, err := Render("rst", "text")
+		assert.Error(t, err)
+	})
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+
+	render := func(key string, version int, source string) string {
+		t.Helper()
+		html, err := cache.Render(key, version, Plain, source)
+		require.NoError(t, err)
+		return html
+	}
+
+	t.Run("Serves the version it rendered", func(t *testing.T) {
+		assert.Equal(t, "<p>one</p>\n", render("a", 1, "one"))
+		assert.Equal(t, "<p>one</p>\n", render("a", 1, "ignored"), "the cached version is served")
+		assert.Equal(t, "<p>two</p>\n", render("a", 2, "two"), "a new version is rendered")
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("Forgets", func(t *testing.T) {
+		cache.Forget("a")
+		cache.Forget("missing")
+		assert.Zero(t, cache.Len())
+		assert.Equal(t, "<p>new</p>\n", render("a", 2, "new"))
+	})
+
+	t.Run("Evicts the least recently used", func(t *testing.T) {
+		render("b", 1, "b")
+		render("a", 2, "ignored")
+		render("c", 1, "c")
+		assert.Equal(t, 2, cache.Len())
+		assert.Equal(t, "<p>new</p>\n", render("a", 2, "ignored"), "a was used after b")
+		assert.Equal(t, "<p>b again</p>\n", render("b", 1, "b again"), "b was evicted")
+	})
+
+	t.Run("A nil cache renders every time", func(t *testing.T) {
+		var nilCache *Cache
+		html, err := nilCache.Render("a", 1, Plain, "one")
+		require.NoError(t, err)
+		assert.Equal(t, "<p>one</p>\n", html)
+		nilCache.Forget("a")
+		assert.Zero(t, nilCache.Len())
+	})
+}
@@ -0,0 +1,255 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"blog-platform/internal/slug"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MoreMarker ends the excerpt of a blog wherever its author puts it.
+const MoreMarker = "<!--more-->"
+
+// ExcerptWords is how many words a blog without a MoreMarker is cut to for
+// its excerpt.
+const ExcerptWords = 55
+
+// WordsPerMinute is the reading speed reading times assume.
+const WordsPerMinute = 200
+
+// Summary is what clients show of a blog without its content.
+type Summary struct {
+	Excerpt            string    `bson:"excerpt" json:"excerpt"`
+	WordCount          int       `bson:"word_count" json:"wordCount"`
+	ReadingTimeMinutes int       `bson:"reading_time_minutes" json:"readingTimeMinutes"`
+	TableOfContents    []Heading `bson:"toc" json:"tableOfContents"`
+}
+
+// Heading is an entry in the table of contents. ID is the id of the heading
+// in the rendered HTML, for links to jump to.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Summarize works out the Summary of source written in format from the HTML
+// it renders to, so it counts what readers see rather than markup.
+func Summarize(format Format, source string) (Summary, error) {
+	rendered, err := Render(format, source)
+	if err != nil {
+		return Summary{}, err
+	}
+	text, headings := outline(rendered)
+
+	// Stray punctuation, like a dash between words, is no word. The excerpt
+	// keeps it, and is cut before the first word past ExcerptWords.
+	fields := strings.Fields(text)
+	words, cut := 0, len(fields)
+	for i, field := range fields {
+		if !wordy(field) {
+			continue
+		}
+		if words == ExcerptWords && cut == len(fields) {
+			cut = i
+		}
+		words++
+	}
+
+	summary := Summary{
+		Excerpt:            strings.Join(fields[:cut], " "),
+		WordCount:          words,
+		ReadingTimeMinutes: int(math.Ceil(float64(words) / WordsPerMinute)),
+		TableOfContents:    headings,
+	}
+	if cut < len(fields) {
+		summary.Excerpt += "…"
+	}
+
+	if before, _, found := strings.Cut(source, MoreMarker); found {
+		rendered, err := Render(format, before)
+		if err != nil {
+			return Summary{}, err
+		}
+		text, _ := outline(rendered)
+		summary.Excerpt = strings.Join(strings.Fields(text), " ")
+	}
+	return summary, nil
+}
+
+// outline returns the text of fragment, with blocks separated by spaces, and
+// the headings in it that have an id. The links between footnotes and where
+// they are cited are left out of the text.
+func outline(fragment string) (string, []Heading) {
+	var text strings.Builder
+	headings := []Heading{}
+	var heading *Heading
+	var headingText strings.Builder
+	footnoteLink := false
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return text.String(), headings
+		case html.TextToken:
+			if footnoteLink {
+				continue
+			}
+			t := z.Text()
+			text.Write(t)
+			if heading != nil {
+				headingText.Write(t)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if block[t.DataAtom] {
+				text.WriteByte(' ')
+			}
+			if level := headingLevel(t.DataAtom); level > 0 {
+				heading = &Heading{Level: level, ID: attr(t, "id")}
+				headingText.Reset()
+			}
+			if t.DataAtom == atom.A && strings.HasPrefix(attr(t, "class"), "footnote-") {
+				footnoteLink = true
+			}
+		case html.EndTagToken:
+			t := z.Token()
+			if block[t.DataAtom] {
+				text.WriteByte(' ')
+			}
+			if t.DataAtom == atom.A {
+				footnoteLink = false
+			}
+			if heading != nil && headingLevel(t.DataAtom) > 0 {
+				heading.Text = strings.Join(strings.Fields(headingText.String()), " ")
+				if heading.ID != "" {
+					headings = append(headings, *heading)
+				}
+				heading = nil
+			}
+		}
+	}
+}
+
+// block are the elements whose content does not run on into what follows.
+var block = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Hr: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Li: true, atom.Ul: true, atom.Ol: true, atom.Dd: true, atom.Dt: true,
+	atom.Table: true, atom.Tr: true, atom.Td: true, atom.Th: true, atom.Section: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+// headingLevel returns the level of a heading element, or 0 for any other.
+func headingLevel(a atom.Atom) int {
+	switch a {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(a.String()[1:])
+		return level
+	}
+	return 0
+}
+
+// wordy reports whether s has a letter or digit in it.
+func wordy(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0
+}
+
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// anchorHeadings gives every heading in fragment without an id one made from
+// its text, unique within the fragment, and leaves everything else exactly as
+// it was.
+func anchorHeadings(fragment string) string {
+	if !strings.Contains(fragment, "<h") {
+		return fragment
+	}
+
+	var out, held, text strings.Builder
+	ids := headingIDs{}
+	var open *html.Token
+
+	z := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := string(z.Raw())
+
+		if open == nil {
+			if tt == html.StartTagToken {
+				if t := z.Token(); headingLevel(t.DataAtom) > 0 {
+					if id := attr(t, "id"); id != "" {
+						ids.seen(id)
+					} else {
+						open = &t
+						held.Reset()
+						text.Reset()
+						continue
+					}
+				}
+			}
+			out.WriteString(raw)
+			continue
+		}
+
+		if tt == html.EndTagToken {
+			if t := z.Token(); t.DataAtom == open.DataAtom {
+				open.Attr = append(open.Attr, html.Attribute{Key: "id", Val: ids.make(text.String())})
+				out.WriteString(open.String())
+				out.WriteString(held.String())
+				out.WriteString(raw)
+				open = nil
+				continue
+			}
+		}
+		if tt == html.TextToken {
+			text.Write(z.Text())
+		}
+		held.WriteString(raw)
+	}
+
+	// A heading left open keeps its markup without an id.
+	if open != nil {
+		out.WriteString(open.String())
+		out.WriteString(held.String())
+	}
+	return out.String()
+}
+
+// headingIDs hands out the ids of the headings in one fragment.
+type headingIDs map[string]bool
+
+func (ids headingIDs) seen(id string) {
+	ids[id] = true
+}
+
+// make returns an id for a heading with text, numbered when an earlier
+// heading already has it.
+func (ids headingIDs) make(text string) string {
+	base := "section"
+	if wordy(text) {
+		base = slug.Make(text)
+	}
+
+	id := base
+	for n := 1; ids[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	ids[id] = true
+	return id
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	summarize := func(format Format, source string) Summary {
+		t.Helper()
+		summary, err := Summarize(format, source)
+		require.NoError(t, err)
+		return summary
+	}
+
+	t.Run("Counts the words readers see", func(t *testing.T) {
+		summary := summarize(Markdown, "# Hello *world*\n\nSee [the docs](https://go.dev) - twice[^1].\n\n[^1]: Or more\n")
+		assert.Equal(t, 8, summary.WordCount)
+		assert.Equal(t, 1, summary.ReadingTimeMinutes)
+		assert.Equal(t, "Hello world See the docs - twice. Or more", summary.Excerpt)
+
+		assert.Equal(t, 3, summarize(HTML, "<p>one<br>two</p><ul><li>three</li></ul>").WordCount)
+		assert.Equal(t, 2, summarize(Plain, "<b>bold</b> claim").WordCount)
+	})
+
+	t.Run("Reading time", func(t *testing.T) {
+		assert.Zero(t, summarize(Markdown, "").ReadingTimeMinutes)
+		assert.Equal(t, 1, summarize(Plain, strings.Repeat("word ", WordsPerMinute)).ReadingTimeMinutes)
+		assert.Equal(t, 2, summarize(Plain, strings.Repeat("word ", WordsPerMinute+1)).ReadingTimeMinutes)
+	})
+
+	t.Run("Cuts long excerpts", func(t *testing.T) {
+		summary := summarize(Plain, strings.Repeat("word ", ExcerptWords+1))
+		assert.Equal(t, strings.Repeat("word ", ExcerptWords-1)+"word…", summary.Excerpt)
+	})
+
+	t.Run("Honours the more marker", func(t *testing.T) {
+		summary := summarize(Markdown, "First *part*.\n\n"+MoreMarker+"\n\nThe rest.")
+		assert.Equal(t, "First part.", summary.Excerpt)
+		assert.Equal(t, 4, summary.WordCount)
+		assert.Equal(t, "Intro", summarize(HTML, "<p>Intro</p>"+MoreMarker+"<p>Body</p>").Excerpt)
+	})
+
+	t.Run("Lists headings", func(t *testing.T) {
+		summary := summarize(Markdown, "# Guide\n\n## Install `go`\n\ntext\n\n### Linux\n\n## Install `go`\n")
+		assert.Equal(t, []Heading{
+			{Level: 1, Text: "Guide", ID: "guide"},
+			{Level: 2, Text: "Install go", ID: "install-go"},
+			{Level: 3, Text: "Linux", ID: "linux"},
+			{Level: 2, Text: "Install go", ID: "install-go-1"},
+		}, summary.TableOfContents)
+
+		assert.NotNil(t, summarize(Plain, "# not a heading").TableOfContents)
+		assert.Empty(t, summarize(Plain, "# not a heading").TableOfContents)
+	})
+
+	t.Run("Unknown formats", func(t *testing.T) {
+		_, err := Summarize("rst", "text")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,316 @@
+// Package search implements the full-text search shared by every
+// BlogRepository backend: tokenising, query parsing, relevance scoring and
+// highlighting. Backends only have to find candidate documents that contain
+// every query token; ranking happens here so all of them order results the
+// same way.
+package search
+
+import (
+	"html"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Field weights. A match in the title counts for more than a match in a tag,
+// and both count for more than a match somewhere in the body.
+const (
+	TitleWeight    = 8.0
+	TagWeight      = 4.0
+	CategoryWeight = 2.0
+	ContentWeight  = 1.0
+
+	// phraseBoost multiplies the score of a quoted phrase over the score its
+	// words would get on their own.
+	phraseBoost = 2.0
+)
+
+type token struct {
+	text       string
+	start, end int
+}
+
+// fold lowercases s and strips diacritics so "Café" and "cafe" match.
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// tokenize splits text into runs of letters and digits, remembering where each
+// run sits in text so it can be highlighted later.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{text: fold(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: fold(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// Tokenize returns the normalised words of text in order.
+func Tokenize(text string) []string {
+	var words []string
+	for _, t := range tokenize(text) {
+		words = append(words, t.text)
+	}
+	return words
+}
+
+// Terms returns the sorted, distinct words of all fields. Backends store it
+// with a document so candidates can be found through an index.
+func Terms(fields ...string) []string {
+	var terms []string
+	for _, field := range fields {
+		terms = append(terms, Tokenize(field)...)
+	}
+	slices.Sort(terms)
+	return slices.Compact(terms)
+}
+
+// Query is a parsed search. Every term, prefix and phrase has to match for a
+// document to be a hit.
+type Query struct {
+	Terms    []string
+	Prefixes []string
+	Phrases  [][]string
+}
+
+// Parse reads a user query. Words in double quotes form a phrase and a word
+// ending in * matches every word starting with it. Everything else is a term;
+// punctuation is ignored, so the query cannot inject operators into a backend.
+func Parse(q string) Query {
+	var query Query
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			phrase := Tokenize(part)
+			switch len(phrase) {
+			case 0:
+			case 1:
+				query.Terms = append(query.Terms, phrase[0])
+			default:
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			words := Tokenize(word)
+			if len(words) == 0 {
+				continue
+			}
+			if strings.HasSuffix(word, "*") {
+				query.Terms = append(query.Terms, words[:len(words)-1]...)
+				query.Prefixes = append(query.Prefixes, words[len(words)-1])
+				continue
+			}
+			query.Terms = append(query.Terms, words...)
+		}
+	}
+
+	slices.Sort(query.Terms)
+	query.Terms = slices.Compact(query.Terms)
+	slices.Sort(query.Prefixes)
+	query.Prefixes = slices.Compact(query.Prefixes)
+	return query
+}
+
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Prefixes) == 0 && len(q.Phrases) == 0
+}
+
+// Required lists every exact word a document must contain: the terms and the
+// words of each phrase.
+func (q Query) Required() []string {
+	words := slices.Clone(q.Terms)
+	for _, phrase := range q.Phrases {
+		words = append(words, phrase...)
+	}
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// Document is the searchable part of a blog.
+type Document struct {
+	Title    string
+	Category string
+	Content  string
+	Tags     []string
+}
+
+type field struct {
+	weight float64
+	words  []string
+}
+
+// Score reports whether doc matches q and how relevant it is. A unit of the
+// query (term, prefix or phrase) scores weight * (1 + ln tf) / sqrt(length)
+// in every field it occurs in, so short fields and repeated matches rank
+// higher without long posts drowning out titles.
+func Score(q Query, doc Document) (float64, bool) {
+	if q.IsEmpty() {
+		return 0, false
+	}
+
+	fields := []field{
+		{TitleWeight, Tokenize(doc.Title)},
+		{TagWeight, Tokenize(strings.Join(doc.Tags, " "))},
+		{CategoryWeight, Tokenize(doc.Category)},
+		{ContentWeight, Tokenize(doc.Content)},
+	}
+
+	var score float64
+	unit := func(boost float64, count func(words []string) int) bool {
+		matched := false
+		for _, f := range fields {
+			tf := count(f.words)
+			if tf == 0 {
+				continue
+			}
+			matched = true
+			score += boost * f.weight * (1 + math.Log(float64(tf))) / math.Sqrt(float64(len(f.words)))
+		}
+		return matched
+	}
+
+	for _, term := range q.Terms {
+		if !unit(1, func(words []string) int { return countWords(words, func(w string) bool { return w == term }) }) {
+			return 0, false
+		}
+	}
+	for _, prefix := range q.Prefixes {
+		if !unit(1, func(words []string) int {
+			return countWords(words, func(w string) bool { return strings.HasPrefix(w, prefix) })
+		}) {
+			return 0, false
+		}
+	}
+	for _, phrase := range q.Phrases {
+		if !unit(phraseBoost, func(words []string) int { return countPhrase(words, phrase) }) {
+			return 0, false
+		}
+	}
+
+	return score, true
+}
+
+func countWords(words []string, match func(string) bool) int {
+	n := 0
+	for _, w := range words {
+		if match(w) {
+			n++
+		}
+	}
+	return n
+}
+
+func countPhrase(words, phrase []string) int {
+	n := 0
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			n++
+		}
+	}
+	return n
+}
+
+func (q Query) matchesWord(w string) bool {
+	if slices.Contains(q.Terms, w) {
+		return true
+	}
+	for _, prefix := range q.Prefixes {
+		if strings.HasPrefix(w, prefix) {
+			return true
+		}
+	}
+	for _, phrase := range q.Phrases {
+		if slices.Contains(phrase, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Highlight returns text as escaped HTML with every matching word wrapped in
+// <mark>. With a positive maxLen the result is cut to a window of roughly
+// maxLen bytes around the first match, with ellipses where text was dropped.
+func Highlight(text string, q Query, maxLen int) string {
+	tokens := tokenize(text)
+	var matches []token
+	for _, t := range tokens {
+		if q.matchesWord(t.text) {
+			matches = append(matches, t)
+		}
+	}
+
+	start, end := 0, len(text)
+	if maxLen > 0 && len(text) > maxLen {
+		if len(matches) > 0 {
+			start = max(0, matches[0].start-maxLen/3)
+		}
+		end = min(len(text), start+maxLen)
+		start, end = wordBoundary(text, tokens, start, true), wordBoundary(text, tokens, end, false)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	position := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[position:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		position = m.end
+	}
+	b.WriteString(html.EscapeString(text[position:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// wordBoundary moves offset so a window never cuts a word or a rune in half.
+// A window start moves back to the start of the word it falls in, a window
+// end moves back to the end of the previous word.
+func wordBoundary(text string, tokens []token, offset int, isStart bool) int {
+	for i, t := range tokens {
+		if offset <= t.start || offset >= t.end {
+			continue
+		}
+		if isStart {
+			return t.start
+		}
+		if i == 0 {
+			return 0
+		}
+		return tokens[i-1].end
+	}
+	for offset > 0 && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	return offset
+}
@@ -0,0 +1,62 @@
+package search_test
+
+import (
+	"blog-platform/internal/search"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  search.Query
+	}{
+		{"Go Echo", search.Query{Terms: []string{"echo", "go"}}},
+		{`"Go Echo" mongo*`, search.Query{Prefixes: []string{"mongo"}, Phrases: [][]string{{"go", "echo"}}}},
+		{`"single"`, search.Query{Terms: []string{"single"}}},
+		{"re-use (.*) $where", search.Query{Terms: []string{"re", "use", "where"}}},
+		{"Crème brûlée", search.Query{Terms: []string{"brulee", "creme"}}},
+		{`*** "" "unterminated`, search.Query{Terms: []string{"unterminated"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, search.Parse(tt.query))
+		})
+	}
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"bread", "go", "learning"}, search.Terms("Learning Go", "GO", "bread"))
+}
+
+func TestScore(t *testing.T) {
+	q := search.Parse("go")
+
+	title, ok := search.Score(q, search.Document{Title: "Learning Go", Content: "channels"})
+	assert.True(t, ok)
+	content, ok := search.Score(q, search.Document{Title: "Learning", Content: "channels in go"})
+	assert.True(t, ok)
+	assert.Greater(t, title, content)
+
+	_, ok = search.Score(search.Parse("go rust"), search.Document{Title: "Learning Go"})
+	assert.False(t, ok, "every term must match")
+
+	_, ok = search.Score(search.Parse(`"go learning"`), search.Document{Title: "Learning Go"})
+	assert.False(t, ok, "phrase words must be adjacent and in order")
+
+	_, ok = search.Score(search.Parse(""), search.Document{Title: "Learning Go"})
+	assert.False(t, ok, "an empty query matches nothing")
+}
+
+func TestHighlight(t *testing.T) {
+	q := search.Parse("go* <b>")
+
+	assert.Equal(t, "Learning <mark>Go</mark> &amp; <mark>Golang</mark> &lt;b&gt;", search.Highlight("Learning Go & Golang <b>", search.Parse("go*"), 0))
+	assert.Equal(t, "nothing here", search.Highlight("nothing here", q, 0))
+
+	long := "one two three four five six seven eight nine ten go eleven twelve thirteen fourteen"
+	assert.Equal(t, "…eight nine ten <mark>go</mark> eleven twelve thirteen…", search.Highlight(long, search.Parse("go"), 40))
+	assert.Equal(t, "one two three four…", search.Highlight(long, search.Parse("missing"), 20))
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultAPIKeyTTL is how long an API key lasts when it is created without an
+// expiry.
+const defaultAPIKeyTTL = 90 * 24 * time.Hour
+
+// apiKeyPrefixLength is how much of a key is kept in the clear to recognise
+// it by: the "bp_" prefix and eight hex digits.
+const apiKeyPrefixLength = len(auth.APIKeyPrefix) + 8
+
+// NewAPIKey is the response to creating an API key, the only one that holds
+// the key itself.
+type NewAPIKey struct {
+	Key string `json:"key"`
+	*database.APIKey
+}
+
+// CreateAPIKeyHandler issues an API key acting for a user, the admin creating
+// it unless the request names another.
+func (s *Server) CreateAPIKeyHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	p, err := requireAdmin(c)
+	if err != nil {
+		return err
+	}
+
+	var request dto.APIKeyRequestDTO
+	if err := c.Bind(&request); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(request); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(defaultAPIKeyTTL)
+	if request.ExpiresAt != nil {
+		if !request.ExpiresAt.After(time.Now()) {
+			return NewProblem(http.StatusBadRequest, "expiresAt must be in the future")
+		}
+		expiresAt = *request.ExpiresAt
+	}
+
+	userID := cmp.Or(request.UserID, p.ID)
+	if _, err := s.Users.GetUser(ctx, userID); errors.Is(err, database.ErrNotFound) {
+		return NewProblem(http.StatusBadRequest, fmt.Sprintf("user %s does not exist", userID))
+	} else if err != nil {
+		return err
+	}
+
+	secret := auth.NewAPIKey()
+	key, err := s.Users.CreateAPIKey(ctx, dto.APIKeyCreateDTO{
+		Name:      request.Name,
+		Prefix:    secret[:apiKeyPrefixLength],
+		Hash:      auth.HashAPIKey(secret),
+		UserID:    userID,
+		Scopes:    request.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, NewAPIKey{Key: secret, APIKey: key})
+}
+
+func (s *Server) ListAPIKeysHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	keys, err := s.Users.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKeyHandler stops a key from being used. Revoked keys stay listed,
+// so it is known when they were last used.
+func (s *Server) RevokeAPIKeyHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	if _, err := s.Users.RevokeAPIKey(ctx, c.Param("id")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	users := database.NewMemoryUsers()
+	s := &server.Server{
+		DB:    mockDB,
+		Users: users,
+	}
+
+	ctx := context.Background()
+	newUser := func(email string, role auth.Role) (*database.User, string) {
+		user, err := users.CreateUser(ctx, dto.UserCreateDTO{Email: email, Name: email, Roles: []auth.Role{role}})
+		require.NoError(t, err)
+		token, err := keys.Sign(user.Principal(), time.Minute)
+		require.NoError(t, err)
+		return user, "Bearer " + token
+	}
+	admin, adminToken := newUser("admin@example.com", auth.RoleAdmin)
+	writer, _ := newUser("writer@example.com", auth.RoleAuthor)
+	_, readerToken := newUser("reader@example.com", auth.RoleReader)
+
+	id := "123"
+	draft := database.Blog{Title: "Draft", AuthorID: writer.ID.Hex(), Status: database.StatusDraft, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDB.On("CreateBlog", mock.Anything, mock.Anything).Return(&id, nil)
+	mockDB.On("GetBlog", mock.Anything, "draft").Return(&draft, nil)
+
+	request := func(method string, handler echo.HandlerFunc, payload, authorization string, params ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if len(params) > 0 {
+			c.SetParamNames("id")
+			c.SetParamValues(params...)
+		}
+
+		if err := server.Authenticate(keys, users)(handler)(c); err != nil {
+			server.HTTPErrorHandler(err, c)
+		}
+		return rec
+	}
+	createKey := func(payload string) server.NewAPIKey {
+		rec := request(http.MethodPost, s.CreateAPIKeyHandler, payload, adminToken)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var key server.NewAPIKey
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&key))
+		return key
+	}
+	createPost := func(authorization string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, s.CreateBlogHandler, `{"title": "T", "category": "C", "content": "C", "tags": []}`, authorization)
+	}
+
+	readKey := createKey(`{"name": "Preview", "userId": "` + writer.ID.Hex() + `", "scopes": ["posts:read"]}`)
+	writeKey := createKey(`{"name": "CI", "userId": "` + writer.ID.Hex() + `", "scopes": ["posts:read", "posts:write"]}`)
+
+	t.Run("Admins create keys for a user", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(readKey.Key, auth.APIKeyPrefix))
+		assert.True(t, strings.HasPrefix(readKey.Key, readKey.Prefix))
+		assert.Len(t, readKey.Prefix, len(auth.APIKeyPrefix)+8)
+		assert.Equal(t, writer.ID.Hex(), readKey.UserID)
+		assert.WithinDuration(t, time.Now().Add(90*24*time.Hour), readKey.ExpiresAt, time.Minute)
+
+		own := createKey(`{"name": "Mine", "scopes": ["comments:moderate"], "expiresAt": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`)
+		assert.Equal(t, admin.ID.Hex(), own.UserID)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), own.ExpiresAt, time.Minute)
+	})
+
+	t.Run("Validates keys", func(t *testing.T) {
+		for _, payload := range []string{
+			`{"name": "CI", "scopes": ["posts:delete"]}`,
+			`{"name": "CI", "scopes": []}`,
+			`{"scopes": ["posts:read"]}`,
+			`{"name": "CI", "scopes": ["posts:read"], "userId": "000000000000000000000000"}`,
+			`{"name": "CI", "scopes": ["posts:read"], "expiresAt": "2020-01-01T00:00:00Z"}`,
+		} {
+			rec := request(http.MethodPost, s.CreateAPIKeyHandler, payload, adminToken)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		}
+	})
+
+	t.Run("Only admins who logged in manage keys", func(t *testing.T) {
+		payload := `{"name": "CI", "scopes": ["posts:read"]}`
+		assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, s.CreateAPIKeyHandler, payload, "").Code)
+		assert.Equal(t, http.StatusForbidden, request(http.MethodPost, s.CreateAPIKeyHandler, payload, readerToken).Code)
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, s.ListAPIKeysHandler, "", readerToken).Code)
+
+		adminKey := createKey(`{"name": "Admin", "scopes": ["posts:read", "posts:write", "comments:moderate"]}`)
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, s.ListAPIKeysHandler, "", "ApiKey "+adminKey.Key).Code)
+		assert.Equal(t, http.StatusForbidden, request(http.MethodGet, s.GetProfileHandler, "", "ApiKey "+adminKey.Key).Code)
+	})
+
+	t.Run("Keys act for their user within their scopes", func(t *testing.T) {
+		rec := request(http.MethodGet, s.GetBlogHandler, "", "ApiKey "+readKey.Key, "draft")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = createPost("ApiKey " + readKey.Key)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "posts:write")
+
+		rec = createPost("apikey " + writeKey.Key)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		mockDB.AssertCalled(t, "CreateBlog", mock.Anything, mock.MatchedBy(func(create dto.BlogCreateDto) bool {
+			return create.Author == writer.ID.Hex()
+		}))
+	})
+
+	t.Run("Lists keys with their last use but never the key", func(t *testing.T) {
+		rec := request(http.MethodGet, s.ListAPIKeysHandler, "", adminToken)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), readKey.Key)
+		assert.NotContains(t, rec.Body.String(), auth.HashAPIKey(readKey.Key))
+
+		var listed []database.APIKey
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&listed))
+		require.Len(t, listed, 4)
+		for _, key := range listed {
+			if key.ID == readKey.ID {
+				require.NotNil(t, key.LastUsedAt)
+				assert.WithinDuration(t, time.Now(), *key.LastUsedAt, time.Minute)
+			}
+		}
+	})
+
+	t.Run("Keys take on the roles their user has now", func(t *testing.T) {
+		_, err := users.SetRoles(ctx, dto.UserRolesDTO{Id: writer.ID.Hex(), Roles: []auth.Role{auth.RoleReader}})
+		require.NoError(t, err)
+		defer users.SetRoles(ctx, dto.UserRolesDTO{Id: writer.ID.Hex(), Roles: []auth.Role{auth.RoleAuthor}})
+
+		rec := createPost("ApiKey " + writeKey.Key)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "insufficient role")
+	})
+
+	t.Run("Refuses unknown, expired and revoked keys", func(t *testing.T) {
+		expired := auth.NewAPIKey()
+		_, err := users.CreateAPIKey(ctx, dto.APIKeyCreateDTO{
+			Name:      "Old",
+			Hash:      auth.HashAPIKey(expired),
+			UserID:    writer.ID.Hex(),
+			Scopes:    []auth.Scope{auth.ScopePostsWrite},
+			ExpiresAt: time.Now().Add(-time.Minute),
+		})
+		require.NoError(t, err)
+
+		rec := request(http.MethodDelete, s.RevokeAPIKeyHandler, "", adminToken, writeKey.ID.Hex())
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		for _, key := range []string{auth.NewAPIKey(), expired, writeKey.Key} {
+			rec := createPost("ApiKey " + key)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+
+		rec = request(http.MethodDelete, s.RevokeAPIKeyHandler, "", adminToken, "000000000000000000000000")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
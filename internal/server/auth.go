@@ -0,0 +1,256 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// principalKey is where Authenticate stores the caller in the echo context.
+const principalKey = "principal"
+
+// lastUsedGranularity is how stale the last use recorded for an API key may
+// get, so a busy key is not written to on every request.
+const lastUsedGranularity = time.Minute
+
+// Authenticate verifies the bearer token or API key a request carries and
+// stores whom it speaks for for the handlers. Requests without either carry
+// on anonymously, since reads are public; it is up to each handler to demand
+// the roles and scopes it needs. A token that fails verification, that users
+// says was signed out or that was issued before its user's password or roles
+// last changed is rejected outright, as is an unknown, expired or revoked API
+// key. users may be nil when nothing signs out and no API keys
+// are issued.
+func Authenticate(keys *auth.Keys, users database.UserRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			if header == "" {
+				return next(c)
+			}
+
+			scheme, credentials, _ := strings.Cut(header, " ")
+			credentials = strings.TrimSpace(credentials)
+			if credentials == "" {
+				return unauthorized(c, "authorization must be a bearer token or an API key")
+			}
+
+			var p *auth.Principal
+			var err error
+			switch {
+			case strings.EqualFold(scheme, "Bearer"):
+				p, err = verifyToken(c, keys, users, credentials)
+			case strings.EqualFold(scheme, "ApiKey") && users != nil:
+				p, err = verifyAPIKey(c, users, credentials)
+			default:
+				return unauthorized(c, "authorization must be a bearer token or an API key")
+			}
+			if err != nil {
+				return err
+			}
+
+			c.Set(principalKey, p)
+			return next(c)
+		}
+	}
+}
+
+func verifyToken(c echo.Context, keys *auth.Keys, users database.UserRepository, token string) (*auth.Principal, error) {
+	p, err := keys.Verify(token)
+	if err != nil {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return nil, NewProblem(http.StatusUnauthorized, "invalid or expired token")
+	}
+
+	if users != nil && p.TokenID != "" {
+		revoked, err := users.TokenRevoked(c.Request().Context(), p.TokenID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return nil, NewProblem(http.StatusUnauthorized, "token has been signed out")
+		}
+	}
+
+	if users != nil {
+		user, err := users.GetUser(c.Request().Context(), p.ID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return nil, err
+		}
+		if err != nil || user.TokenVersion != p.TokenVersion {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return nil, NewProblem(http.StatusUnauthorized, "token was issued before the account changed")
+		}
+	}
+	return p, nil
+}
+
+// verifyAPIKey looks up the key and the user it acts for. The user's current
+// roles apply, so taking a role away from a user takes it from their keys too.
+func verifyAPIKey(c echo.Context, users database.UserRepository, secret string) (*auth.Principal, error) {
+	ctx := c.Request().Context()
+
+	key, err := users.GetAPIKeyByHash(ctx, auth.HashAPIKey(secret))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, unauthorized(c, "invalid API key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current := time.Now()
+	if !key.Active(current) {
+		return nil, unauthorized(c, "API key has expired or been revoked")
+	}
+
+	user, err := users.GetUser(ctx, key.UserID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, unauthorized(c, "invalid API key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if key.LastUsedAt == nil || current.Sub(*key.LastUsedAt) >= lastUsedGranularity {
+		if err := users.TouchAPIKey(ctx, key.ID.Hex(), current); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	p := user.Principal()
+	p.ExpiresAt = key.ExpiresAt
+	p.KeyID = key.ID.Hex()
+	p.Scopes = key.Scopes
+	return &p, nil
+}
+
+// principal is the authenticated caller of the request, or nil.
+func principal(c echo.Context) *auth.Principal {
+	p, _ := c.Get(principalKey).(*auth.Principal)
+	return p
+}
+
+func unauthorized(c echo.Context, detail string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return NewProblem(http.StatusUnauthorized, detail)
+}
+
+// requireUser returns the caller, or 401 when the request is anonymous. It
+// guards the caller's own account, which no API key scope covers, so API keys
+// get 403.
+func requireUser(c echo.Context) (*auth.Principal, error) {
+	p := principal(c)
+	if p == nil {
+		return nil, unauthorized(c, "authentication required")
+	}
+	if p.KeyID != "" {
+		return nil, NewProblem(http.StatusForbidden, "API keys cannot manage accounts")
+	}
+	return p, nil
+}
+
+// requireAdmin returns the caller when they are an admin who logged in.
+func requireAdmin(c echo.Context) (*auth.Principal, error) {
+	p, err := requireUser(c)
+	if err != nil {
+		return nil, err
+	}
+	if !p.HasRole(auth.RoleAdmin) {
+		return nil, NewProblem(http.StatusForbidden, "insufficient role")
+	}
+	return p, nil
+}
+
+// requireRole returns the caller when they hold one of roles and, when they
+// came with an API key, the key has scope. Anonymous callers get 401 and
+// callers without the role or scope 403.
+func requireRole(c echo.Context, scope auth.Scope, roles ...auth.Role) (*auth.Principal, error) {
+	p := principal(c)
+	if p == nil {
+		return nil, unauthorized(c, "authentication required")
+	}
+	if !p.Allows(scope) {
+		return nil, NewProblem(http.StatusForbidden, fmt.Sprintf("API key lacks the %s scope", scope))
+	}
+	if !p.HasRole(roles...) {
+		return nil, NewProblem(http.StatusForbidden, "insufficient role")
+	}
+	return p, nil
+}
+
+// requireEditable checks that the caller may work on the blog id within
+// scope: editors and admins may work on any blog, authors only their own.
+func (s *Server) requireEditable(ctx context.Context, c echo.Context, id string, scope auth.Scope) error {
+	p, err := requireRole(c, scope, auth.Writers...)
+	if err != nil {
+		return err
+	}
+	if p.HasRole(auth.Editors...) {
+		return nil
+	}
+
+	blog, err := s.DB.GetBlog(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !p.CanEdit(blog.Authors()...) {
+		return NewProblem(http.StatusForbidden, "only the author or an editor can change this post")
+	}
+	return nil
+}
+
+// author names whoever made a change, as revisions record it.
+func author(c echo.Context) string {
+	if p := principal(c); p != nil {
+		return p.ID
+	}
+	return ""
+}
+
+// newKeys builds the token keys from the environment: JWT_SECRET for HS256,
+// or JWT_PUBLIC_KEY_FILE (and JWT_PRIVATE_KEY_FILE to issue tokens) for RS256.
+// JWT_ISSUER, when set, is required in every token.
+func newKeys() (*auth.Keys, error) {
+	issuer := os.Getenv("JWT_ISSUER")
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return auth.NewHMACKeys([]byte(secret), issuer)
+	}
+
+	publicFile := os.Getenv("JWT_PUBLIC_KEY_FILE")
+	if publicFile == "" {
+		return nil, errors.New("either JWT_SECRET or JWT_PUBLIC_KEY_FILE must be set")
+	}
+	pem, err := os.ReadFile(publicFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT_PUBLIC_KEY_FILE - %w", err)
+	}
+	public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT_PUBLIC_KEY_FILE - %w", err)
+	}
+
+	privateFile := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if privateFile == "" {
+		return auth.NewRSAKeys(public, nil, issuer), nil
+	}
+	pem, err = os.ReadFile(privateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT_PRIVATE_KEY_FILE - %w", err)
+	}
+	private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT_PRIVATE_KEY_FILE - %w", err)
+	}
+	return auth.NewRSAKeys(public, private, issuer), nil
+}
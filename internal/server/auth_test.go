@@ -0,0 +1,204 @@
+package server_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticate(t *testing.T) {
+	e, mockDB, _ := setupTest()
+	id := "123"
+	mockDB.On("CreateBlog", mock.Anything, mock.Anything).Return(&id, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+	payload := `{"title": "T", "category": "C", "content": "C", "tags": []}`
+
+	create := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		serve(s.CreateBlogHandler, c)
+		return rec
+	}
+
+	t.Run("Writes need a token", func(t *testing.T) {
+		rec := create("")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+	})
+
+	t.Run("Rejects tokens that fail verification", func(t *testing.T) {
+		expired, err := keys.Sign(auth.Principal{ID: "u1", Roles: []auth.Role{auth.RoleAdmin}}, -time.Hour)
+		require.NoError(t, err)
+		foreign, err := newTestKeys().Sign(auth.Principal{ID: "u1", Roles: []auth.Role{auth.RoleAdmin}}, time.Hour)
+		require.NoError(t, err)
+
+		for _, authorization := range []string{"Bearer " + expired, "Bearer " + foreign, "Bearer nonsense", "Basic dTE6cGFzcw=="} {
+			rec := create(authorization)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+			assert.Contains(t, rec.Header().Get(echo.HeaderWWWAuthenticate), "Bearer")
+		}
+	})
+
+	t.Run("Readers cannot write", func(t *testing.T) {
+		token, err := keys.Sign(auth.Principal{ID: "r1", Roles: []auth.Role{auth.RoleReader}}, time.Hour)
+		require.NoError(t, err)
+
+		rec := create("Bearer " + token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Accepts RSA signed tokens", func(t *testing.T) {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		signer := auth.NewRSAKeys(&rsaKey.PublicKey, rsaKey, "")
+		token, err := signer.Sign(auth.Principal{ID: "u1", Roles: []auth.Role{auth.RoleAuthor}}, time.Hour)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		verifier := auth.NewRSAKeys(&rsaKey.PublicKey, nil, "")
+		err = server.Authenticate(verifier, nil)(s.CreateBlogHandler)(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+	mockDB.AssertNumberOfCalls(t, "CreateBlog", 1)
+}
+
+func TestOwnership(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	own := database.Blog{Title: "Own", AuthorID: "u1", Status: database.StatusDraft, CreatedAt: mockDate, UpdatedAt: mockDate}
+	other := database.Blog{Title: "Other", AuthorID: "u2", Status: database.StatusDraft, CreatedAt: mockDate, UpdatedAt: mockDate}
+	joint := database.Blog{Title: "Joint", AuthorID: "u2", CoAuthors: []string{"u1"}, Status: database.StatusDraft, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDB.On("GetBlog", mock.Anything, "own").Return(&own, nil)
+	mockDB.On("GetBlog", mock.Anything, "other").Return(&other, nil)
+	mockDB.On("GetBlog", mock.Anything, "joint").Return(&joint, nil)
+	mockDB.On("UpdateBlog", mock.Anything, mock.Anything).Return(&own, nil)
+	mockDB.On("DeleteBlog", mock.Anything, mock.Anything).Return(&own, nil)
+	mockDB.On("SetBlogStatus", mock.Anything, mock.Anything).Return(&own, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	request := func(method string, handler echo.HandlerFunc, id string, user string, roles ...auth.Role) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/posts/"+id, strings.NewReader(`{"title": "New"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if user != "" {
+			as(req, user, roles...)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		serve(handler, c)
+		return rec
+	}
+
+	writes := map[string]echo.HandlerFunc{
+		"update":  s.UpdateBlogHandler,
+		"delete":  s.DeleteBlogHandler,
+		"publish": s.PublishBlogHandler,
+	}
+
+	t.Run("Authors change only their own posts", func(t *testing.T) {
+		for name, handler := range writes {
+			rec := request(http.MethodPut, handler, "own", "u1", auth.RoleAuthor)
+			assert.Equal(t, http.StatusOK, rec.Code, name)
+
+			rec = request(http.MethodPut, handler, "other", "u1", auth.RoleAuthor)
+			assert.Equal(t, http.StatusForbidden, rec.Code, name)
+		}
+	})
+
+	t.Run("Co-authors change the posts they co-wrote", func(t *testing.T) {
+		for name, handler := range writes {
+			rec := request(http.MethodPut, handler, "joint", "u1", auth.RoleAuthor)
+			assert.Equal(t, http.StatusOK, rec.Code, name)
+		}
+	})
+
+	t.Run("Editors and admins change any post", func(t *testing.T) {
+		for name, handler := range writes {
+			for _, role := range []auth.Role{auth.RoleEditor, auth.RoleAdmin} {
+				rec := request(http.MethodPut, handler, "other", "e1", role)
+				assert.Equal(t, http.StatusOK, rec.Code, name)
+			}
+		}
+	})
+
+	t.Run("Readers and anonymous callers change nothing", func(t *testing.T) {
+		for name, handler := range writes {
+			rec := request(http.MethodPut, handler, "own", "u1", auth.RoleReader)
+			assert.Equal(t, http.StatusForbidden, rec.Code, name)
+
+			rec = request(http.MethodPut, handler, "own", "")
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, name)
+		}
+	})
+
+	t.Run("The path names the post, never the body", func(t *testing.T) {
+		for _, id := range []string{"other", "joint"} {
+			req := as(httptest.NewRequest(http.MethodPut, "/posts/own", strings.NewReader(`{"id": "`+id+`", "title": "pwned"}`)), "u1", auth.RoleAuthor)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("own")
+
+			serve(s.UpdateBlogHandler, c)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+		pwned := func(id string) any {
+			return mock.MatchedBy(func(update dto.BlogUpdateDTO) bool {
+				return update.Id == id && update.Title != nil && *update.Title == "pwned"
+			})
+		}
+		mockDB.AssertCalled(t, "UpdateBlog", mock.Anything, pwned("own"))
+		mockDB.AssertNotCalled(t, "UpdateBlog", mock.Anything, pwned("other"))
+		mockDB.AssertNotCalled(t, "UpdateBlog", mock.Anything, pwned("joint"))
+	})
+
+	t.Run("Only editors manage the trash", func(t *testing.T) {
+		for _, handler := range []echo.HandlerFunc{s.TrashHandler, s.RestoreBlogHandler, s.PurgeBlogHandler} {
+			rec := request(http.MethodPost, handler, "own", "u1", auth.RoleAuthor)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("Drafts are visible to those who can edit them", func(t *testing.T) {
+		rec := request(http.MethodGet, s.GetBlogHandler, "own", "u1", auth.RoleAuthor)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = request(http.MethodGet, s.GetBlogHandler, "other", "u1", auth.RoleAuthor)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = request(http.MethodGet, s.GetBlogHandler, "other", "e1", auth.RoleEditor)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuthorProfile is what anyone may see of a user who writes.
+type AuthorProfile struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Bio       string `json:"bio"`
+	AvatarURL string `json:"avatarUrl"`
+	PostCount int64  `json:"postCount"`
+}
+
+// AuthorPage is an author's profile with a page of their published posts.
+type AuthorPage struct {
+	Author AuthorProfile      `json:"author"`
+	Posts  *database.BlogPage `json:"posts"`
+}
+
+// GetAuthorHandler serves the public page of an author. Users who neither
+// may write nor have published anything are not authors and are not found.
+func (s *Server) GetAuthorHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	var query dto.AuthorPostsQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	user, err := s.Users.GetUser(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	posts, err := s.DB.ListBlogs(ctx, database.ListOptions{
+		Limit:    query.Limit,
+		Cursor:   query.Cursor,
+		Statuses: []database.Status{database.StatusPublished},
+		Author:   user.ID.Hex(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.render(posts.Items...); err != nil {
+		return err
+	}
+
+	p := user.Principal()
+	if posts.Total == 0 && !p.HasRole(auth.Writers...) {
+		return database.ErrNotFound
+	}
+
+	return c.JSON(http.StatusOK, AuthorPage{
+		Author: AuthorProfile{
+			ID:        user.ID.Hex(),
+			Name:      user.Name,
+			Bio:       user.Bio,
+			AvatarURL: user.AvatarURL,
+			PostCount: posts.Total,
+		},
+		Posts: posts,
+	})
+}
@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorHandlers(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	users := database.NewMemoryUsers()
+	s := &server.Server{
+		DB:    mockDB,
+		Users: users,
+	}
+
+	ctx := context.Background()
+	ada, err := users.CreateUser(ctx, dto.UserCreateDTO{Email: "ada@example.com", Name: "Ada", Roles: []auth.Role{auth.RoleAuthor}})
+	require.NoError(t, err)
+	bio, avatar := "Wrote the first program.", "https://example.com/ada.png"
+	_, err = users.UpdateUser(ctx, dto.UserUpdateDTO{Id: ada.ID.Hex(), Bio: &bio, AvatarURL: &avatar})
+	require.NoError(t, err)
+	reader, err := users.CreateUser(ctx, dto.UserCreateDTO{Email: "reader@example.com", Name: "Reader", Roles: []auth.Role{auth.RoleReader}})
+	require.NoError(t, err)
+
+	getAuthor := func(id, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/authors/"+id+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		serve(s.GetAuthorHandler, c)
+		return rec
+	}
+
+	t.Run("Shows the profile and published posts", func(t *testing.T) {
+		mockDB.On("ListBlogs", mock.Anything, database.ListOptions{Limit: 1, Cursor: "abc", Statuses: published, Author: ada.ID.Hex()}).Return(
+			&database.BlogPage{Items: []*database.Blog{{Title: "Notes", AuthorID: ada.ID.Hex(), CreatedAt: mockDate, UpdatedAt: mockDate}}, NextCursor: "def", Total: 3}, nil)
+
+		rec := getAuthor(ada.ID.Hex(), "?limit=1&cursor=abc")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "ada@example.com")
+
+		var res server.AuthorPage
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		assert.Equal(t, server.AuthorProfile{ID: ada.ID.Hex(), Name: "Ada", Bio: bio, AvatarURL: avatar, PostCount: 3}, res.Author)
+		assert.Equal(t, "Notes", res.Posts.Items[0].Title)
+		assert.Equal(t, "def", res.Posts.NextCursor)
+	})
+
+	t.Run("Readers without posts are not authors", func(t *testing.T) {
+		mockDB.On("ListBlogs", mock.Anything, database.ListOptions{Statuses: published, Author: reader.ID.Hex()}).Return(
+			&database.BlogPage{Items: []*database.Blog{}}, nil)
+
+		rec := getAuthor(reader.ID.Hex(), "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = getAuthor("000000000000000000000000", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Create records co-authors who exist", func(t *testing.T) {
+		id := "123"
+		mockDB.On("CreateBlog", mock.Anything, mock.Anything).Return(&id, nil)
+
+		create := func(coAuthor string) *httptest.ResponseRecorder {
+			payload := fmt.Sprintf(`{"title": "T", "category": "C", "content": "C", "tags": [], "coAuthors": [%q]}`, coAuthor)
+			req := as(httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload)), "u1", auth.RoleAuthor)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			serve(s.CreateBlogHandler, c)
+			return rec
+		}
+
+		rec := create(ada.ID.Hex())
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		mockDB.AssertCalled(t, "CreateBlog", mock.Anything, mock.MatchedBy(func(create dto.BlogCreateDto) bool {
+			return create.Author == "u1" && len(create.CoAuthors) == 1 && create.CoAuthors[0] == ada.ID.Hex()
+		}))
+
+		rec = create("000000000000000000000000")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "does not exist")
+
+		rec = create("ada")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		mockDB.AssertNumberOfCalls(t, "CreateBlog", 1)
+	})
+}
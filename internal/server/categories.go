@@ -0,0 +1,126 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListCategoriesHandler lists every category by name. Each names its parent,
+// for clients to build the tree from.
+func (s *Server) ListCategoriesHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	categories, err := s.Categories.ListCategories(ctx)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, categories)
+}
+
+func (s *Server) GetCategoryHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	category, err := s.Categories.GetCategory(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, category)
+}
+
+func (s *Server) CreateCategoryHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.Editors...); err != nil {
+		return err
+	}
+
+	var create dto.CategoryCreateDTO
+	if err := c.Bind(&create); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(create); err != nil {
+		return err
+	}
+
+	category, err := s.Categories.CreateCategory(ctx, create)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategoryHandler renames or moves a category. A new slug moves the
+// posts filed under the old one along with it.
+func (s *Server) UpdateCategoryHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.Editors...); err != nil {
+		return err
+	}
+
+	var update dto.CategoryUpdateDTO
+	if err := c.Bind(&update); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+	update.Id = c.Param("id")
+
+	if err := validate.Struct(update); err != nil {
+		return err
+	}
+
+	category, err := s.Categories.UpdateCategory(ctx, update)
+	if err != nil {
+		return err
+	}
+	s.Sitemaps.Invalidate()
+	s.Related.Invalidate()
+	return c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategoryHandler deletes a category that no post or other category is
+// filed under, those in the trash included.
+func (s *Server) DeleteCategoryHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.Editors...); err != nil {
+		return err
+	}
+
+	if _, err := s.Categories.DeleteCategory(ctx, c.Param("id")); err != nil {
+		return err
+	}
+	s.Sitemaps.Invalidate()
+	s.Related.Invalidate()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// resolveCategory returns the slug of the category a post names by slug or
+// name, which must exist. Without a category repository any name will do.
+func (s *Server) resolveCategory(ctx context.Context, ref string) (string, error) {
+	if s.Categories == nil {
+		return ref, nil
+	}
+
+	categories, err := s.Categories.ListCategories(ctx)
+	if err != nil {
+		return "", err
+	}
+	category := database.FindCategory(categories, ref)
+	if category == nil {
+		return "", NewProblem(http.StatusBadRequest, fmt.Sprintf("category %s does not exist", ref))
+	}
+	return category.Slug, nil
+}
@@ -0,0 +1,151 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategoryHandlers(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	s := &server.Server{
+		DB:         repository,
+		Categories: repository,
+		Users:      database.NewMemoryUsers(),
+	}
+
+	decode := func(rec *httptest.ResponseRecorder) *database.Category {
+		t.Helper()
+		var category database.Category
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&category))
+		return &category
+	}
+
+	var programming, golang *database.Category
+
+	t.Run("Editors create categories", func(t *testing.T) {
+		rec := call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories", `{"name": "Programming"}`, auth.RoleEditor)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		programming = decode(rec)
+		assert.Equal(t, "programming", programming.Slug)
+
+		rec = call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories",
+			`{"name": "Go", "description": "The Go language", "parentId": "`+programming.ID.Hex()+`"}`, auth.RoleAdmin)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		golang = decode(rec)
+		assert.Equal(t, programming.ID, *golang.ParentID)
+	})
+
+	t.Run("Others may not", func(t *testing.T) {
+		rec := call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories", `{"name": "Mine"}`, auth.RoleAuthor)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories", `{"name": "Mine"}`, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Create checks the category", func(t *testing.T) {
+		rec := call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories", `{"name": "golang", "slug": "go"}`, auth.RoleEditor)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories", `{"name": "Bad", "slug": "Not A Slug"}`, auth.RoleEditor)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = call(t, e, s.CreateCategoryHandler, http.MethodPost, "/categories", `{"name": "Orphan", "parentId": "000000000000000000000000"}`, auth.RoleEditor)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Anyone lists and reads them", func(t *testing.T) {
+		rec := call(t, e, s.ListCategoriesHandler, http.MethodGet, "/categories", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var categories []database.Category
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&categories))
+		require.Len(t, categories, 2)
+		assert.Equal(t, "Go", categories[0].Name)
+
+		rec = call(t, e, s.GetCategoryHandler, http.MethodGet, "/categories/"+golang.ID.Hex(), "", "", "id", golang.ID.Hex())
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "The Go language", decode(rec).Description)
+	})
+
+	var post string
+
+	t.Run("Posts are filed under a category that exists", func(t *testing.T) {
+		rec := call(t, e, s.CreateBlogHandler, http.MethodPost, "/posts", `{"title": "Goroutines", "content": "Content", "category": "GO", "tags": [], "status": "published"}`, auth.RoleAuthor)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var res map[string]string
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		post = res["data"]
+
+		blog, err := repository.GetBlog(context.Background(), post)
+		require.NoError(t, err)
+		assert.Equal(t, "go", blog.Category, "the category is stored by its slug")
+
+		rec = call(t, e, s.CreateBlogHandler, http.MethodPost, "/posts", `{"title": "Stray", "content": "Content", "category": "GoLang", "tags": []}`, auth.RoleAuthor)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "category GoLang does not exist")
+
+		rec = call(t, e, s.UpdateBlogHandler, http.MethodPut, "/posts/"+post, `{"category": "nowhere"}`, auth.RoleEditor, "id", post)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = call(t, e, s.UpdateBlogHandler, http.MethodPut, "/posts/"+post, `{"category": "Programming"}`, auth.RoleEditor, "id", post)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"category":"programming"`)
+	})
+
+	t.Run("Listing a category includes those below it", func(t *testing.T) {
+		createPost(t, repository, dto.BlogCreateDto{Title: "Channels", Content: "Content", Category: "go", Status: string(database.StatusPublished)})
+
+		rec := call(t, e, s.GetBlogsHandler, http.MethodGet, "/posts?category=programming", "", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var page database.BlogPage
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+		assert.EqualValues(t, 2, page.Total)
+	})
+
+	t.Run("Editors update categories", func(t *testing.T) {
+		rec := call(t, e, s.UpdateCategoryHandler, http.MethodPut, "/categories/"+golang.ID.Hex(), `{"slug": "golang", "parentId": ""}`, auth.RoleEditor, "id", golang.ID.Hex())
+		require.Equal(t, http.StatusOK, rec.Code)
+		updated := decode(rec)
+		assert.Equal(t, "golang", updated.Slug)
+		assert.Nil(t, updated.ParentID)
+
+		rec = call(t, e, s.UpdateCategoryHandler, http.MethodPut, "/categories/"+programming.ID.Hex(), `{"parentId": "`+programming.ID.Hex()+`"}`, auth.RoleEditor, "id", programming.ID.Hex())
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = call(t, e, s.UpdateCategoryHandler, http.MethodPut, "/categories/"+golang.ID.Hex(), `{"name": "Golang"}`, auth.RoleAuthor, "id", golang.ID.Hex())
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Categories in use are not deleted", func(t *testing.T) {
+		rec := call(t, e, s.DeleteCategoryHandler, http.MethodDelete, "/categories/"+programming.ID.Hex(), "", auth.RoleEditor, "id", programming.ID.Hex())
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		_, err := repository.DeleteBlog(context.Background(), dto.BlogDeleteDTO{Id: post})
+		require.NoError(t, err)
+		_, err = repository.PurgeBlog(context.Background(), post)
+		require.NoError(t, err)
+
+		rec = call(t, e, s.DeleteCategoryHandler, http.MethodDelete, "/categories/"+programming.ID.Hex(), "", auth.RoleEditor, "id", programming.ID.Hex())
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		rec = call(t, e, s.GetCategoryHandler, http.MethodGet, "/categories/"+programming.ID.Hex(), "", "", "id", programming.ID.Hex())
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Without categories any name will do", func(t *testing.T) {
+		free := &server.Server{DB: repository, Users: s.Users}
+		rec := call(t, e, free.CreateBlogHandler, http.MethodPost, "/posts", `{"title": "Free", "content": "Content", "category": "Anything At All", "tags": []}`, auth.RoleAuthor)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ListCommentsHandler lists the threads on a post the caller may read.
+func (s *Server) ListCommentsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	var query dto.CommentListQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	if err := s.requireReadable(ctx, c, c.Param("id")); err != nil {
+		return err
+	}
+
+	data, err := s.Comments.ListComments(ctx, database.CommentListOptions{
+		BlogID: c.Param("id"),
+		Sort:   database.CommentSort(query.Sort),
+		Limit:  query.Limit,
+		Cursor: query.Cursor,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+// CreateCommentHandler comments on a post, or with a parentId, replies to a
+// comment on it. Anyone with an account may comment on a post they can read.
+// Comments the spam filter doubts wait for a moderator before they are shown.
+func (s *Server) CreateCommentHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	p, err := requireRole(c, auth.ScopeCommentsWrite, auth.Members...)
+	if err != nil {
+		return err
+	}
+
+	var create dto.CommentCreateDTO
+	if err := c.Bind(&create); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+	create.BlogID = c.Param("id")
+	create.AuthorID = p.ID
+	create.AuthorName = p.Name
+
+	if err := validate.Struct(create); err != nil {
+		return err
+	}
+
+	if err := s.requireReadable(ctx, c, create.BlogID); err != nil {
+		return err
+	}
+
+	if create.ParentID != "" {
+		parent, err := s.Comments.GetComment(ctx, create.ParentID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		if parent == nil || parent.BlogID.Hex() != create.BlogID || !parent.Public() {
+			return NewProblem(http.StatusBadRequest, "parent comment does not exist")
+		}
+	}
+
+	status, score := s.screen(ctx, c, create.Body)
+	create.Status = string(status)
+	create.SpamScore = score
+
+	comment, err := s.Comments.CreateComment(ctx, create)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateCommentHandler edits a comment. Only its author may. The new text is
+// screened again, so an approved comment can go back to waiting for a
+// moderator, but an edit never undoes a moderator's decision.
+func (s *Server) UpdateCommentHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	p, err := requireRole(c, auth.ScopeCommentsWrite, auth.Members...)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.postComment(ctx, c)
+	if err != nil {
+		return err
+	}
+	if comment.AuthorID != p.ID {
+		return NewProblem(http.StatusForbidden, "only the author can edit this comment")
+	}
+
+	var update dto.CommentUpdateDTO
+	if err := c.Bind(&update); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+	update.Id = comment.ID.Hex()
+
+	if err := validate.Struct(update); err != nil {
+		return err
+	}
+
+	if comment.Public() {
+		if status, score := s.screen(ctx, c, update.Body); status != database.CommentApproved {
+			update.Status = string(status)
+			update.SpamScore = score
+		}
+	}
+
+	data, err := s.Comments.UpdateComment(ctx, update)
+	if err != nil {
+		return err
+	}
+	s.relearn(ctx, c, comment, data)
+	return c.JSON(http.StatusOK, data)
+}
+
+// DeleteCommentHandler deletes a comment. Its author may delete it, and so
+// may editors and admins, who moderate every comment.
+func (s *Server) DeleteCommentHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	p := principal(c)
+	if p == nil {
+		return unauthorized(c, "authentication required")
+	}
+
+	comment, err := s.postComment(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	own := comment.AuthorID == p.ID && p.Allows(auth.ScopeCommentsWrite)
+	moderator := p.HasRole(auth.Editors...) && p.Allows(auth.ScopeCommentsModerate)
+	if !own && !moderator {
+		return NewProblem(http.StatusForbidden, "only the author or a moderator can delete this comment")
+	}
+
+	deleted, err := s.Comments.DeleteComment(ctx, comment.ID.Hex())
+	if err != nil {
+		return err
+	}
+	s.relearn(ctx, c, comment, deleted)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// requireReadable fails with ErrNotFound unless the caller may read the blog
+// id.
+func (s *Server) requireReadable(ctx context.Context, c echo.Context, id string) error {
+	blog, err := s.DB.GetBlog(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !visible(c, blog) {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+// postComment returns the comment the request names, unless it is deleted or
+// belongs to another post than the one in the path.
+func (s *Server) postComment(ctx context.Context, c echo.Context) (*database.Comment, error) {
+	if err := s.requireReadable(ctx, c, c.Param("id")); err != nil {
+		return nil, err
+	}
+
+	comment, err := s.Comments.GetComment(ctx, c.Param("comment"))
+	if err != nil {
+		return nil, err
+	}
+	if comment.BlogID.Hex() != c.Param("id") || comment.DeletedAt != nil {
+		return nil, database.ErrNotFound
+	}
+	return comment, nil
+}
@@ -0,0 +1,139 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commentRequest serves a JSON request to handler as user with roles, or
+// anonymously when user is empty. params are the id of the post and, for
+// requests about one comment, the id of that comment.
+func commentRequest(e *echo.Echo, method string, handler echo.HandlerFunc, target, payload string, params []string, user string, roles ...auth.Role) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if user != "" {
+		as(req, user, roles...)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames([]string{"id", "comment"}[:len(params)]...)
+	c.SetParamValues(params...)
+
+	serve(handler, c)
+	return rec
+}
+
+func TestCommentHandlers(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	s := &server.Server{
+		DB:       repository,
+		Comments: repository,
+	}
+
+	post := createPost(t, repository, dto.BlogCreateDto{Title: "Post", Author: "u1", Status: string(database.StatusPublished)})
+	draft := createPost(t, repository, dto.BlogCreateDto{Title: "Draft", Author: "u1"})
+
+	create := func(blog, payload, user string) *httptest.ResponseRecorder {
+		return commentRequest(e, http.MethodPost, s.CreateCommentHandler, "/", payload, []string{blog}, user, auth.RoleReader)
+	}
+	created := func(rec *httptest.ResponseRecorder) *database.Comment {
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var comment database.Comment
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&comment))
+		return &comment
+	}
+	list := func(blog, query string, user string, roles ...auth.Role) *httptest.ResponseRecorder {
+		return commentRequest(e, http.MethodGet, s.ListCommentsHandler, "/"+query, "", []string{blog}, user, roles...)
+	}
+
+	first := created(create(post, `{"body": "First!"}`, "r1"))
+	reply := created(create(post, `{"body": "Welcome", "parentId": "`+first.ID.Hex()+`"}`, "r2"))
+
+	t.Run("Anyone signed in comments on posts they can read", func(t *testing.T) {
+		assert.Equal(t, "r1", first.AuthorID)
+		assert.Equal(t, first.ID, *reply.ParentID)
+
+		rec := commentRequest(e, http.MethodPost, s.CreateCommentHandler, "/", `{"body": "Hi"}`, []string{post}, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		assert.Equal(t, http.StatusNotFound, create(draft, `{"body": "Hi"}`, "r1").Code)
+		rec = commentRequest(e, http.MethodPost, s.CreateCommentHandler, "/", `{"body": "Needs work"}`, []string{draft}, "u1", auth.RoleAuthor)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+
+	t.Run("Validates comments", func(t *testing.T) {
+		for _, payload := range []string{
+			`{"body": ""}`,
+			`{"body": "` + strings.Repeat("a", 5001) + `"}`,
+			`{"body": "Hi", "parentId": "nope"}`,
+			`{"body": "Hi", "parentId": "000000000000000000000000"}`,
+		} {
+			assert.Equal(t, http.StatusBadRequest, create(post, payload, "r1").Code, payload)
+		}
+	})
+
+	t.Run("Lists threads to anyone who can read the post", func(t *testing.T) {
+		created(create(post, `{"body": "Second"}`, "r2"))
+
+		rec := list(post, "?sort=top", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var page database.CommentPage
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+		assert.EqualValues(t, 2, page.Total)
+		require.Len(t, page.Items, 2)
+		assert.Equal(t, "First!", page.Items[0].Body)
+		assert.Equal(t, "Welcome", page.Items[0].Replies[0].Body)
+
+		assert.Equal(t, http.StatusBadRequest, list(post, "?sort=best", "").Code)
+		assert.Equal(t, http.StatusNotFound, list(draft, "", "").Code)
+		assert.Equal(t, http.StatusOK, list(draft, "", "u1", auth.RoleAuthor).Code)
+	})
+
+	t.Run("Only authors edit their comments", func(t *testing.T) {
+		params := []string{post, first.ID.Hex()}
+		rec := commentRequest(e, http.MethodPut, s.UpdateCommentHandler, "/", `{"body": "Edited"}`, params, "r2", auth.RoleReader)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = commentRequest(e, http.MethodPut, s.UpdateCommentHandler, "/", `{"body": "Edited"}`, params, "e1", auth.RoleEditor)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = commentRequest(e, http.MethodPut, s.UpdateCommentHandler, "/", `{"body": "Edited"}`, params, "r1", auth.RoleReader)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"body":"Edited"`)
+
+		rec = commentRequest(e, http.MethodPut, s.UpdateCommentHandler, "/", `{"body": "Edited"}`, []string{draft, first.ID.Hex()}, "u1", auth.RoleAuthor)
+		assert.Equal(t, http.StatusNotFound, rec.Code, "the comment is on another post")
+	})
+
+	t.Run("Authors and moderators delete comments", func(t *testing.T) {
+		params := []string{post, reply.ID.Hex()}
+		rec := commentRequest(e, http.MethodDelete, s.DeleteCommentHandler, "/", "", params, "r1", auth.RoleReader)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = commentRequest(e, http.MethodDelete, s.DeleteCommentHandler, "/", "", params, "r2", auth.RoleReader)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		rec = commentRequest(e, http.MethodDelete, s.DeleteCommentHandler, "/", "", params, "r2", auth.RoleReader)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = commentRequest(e, http.MethodDelete, s.DeleteCommentHandler, "/", "", []string{post, first.ID.Hex()}, "e1", auth.RoleEditor)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = list(post, "", "")
+		assert.NotContains(t, rec.Body.String(), "Edited")
+		assert.NotContains(t, rec.Body.String(), "Welcome")
+		assert.Contains(t, rec.Body.String(), "Second")
+	})
+}
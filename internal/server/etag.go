@@ -0,0 +1,62 @@
+package server
+
+import (
+	"blog-platform/internal/database"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// etag is the entity tag of a blog at version.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatchVersion reads the version an If-Match header asks a write to
+// require. It returns nil without the header or for "*", which any existing
+// blog matches. A tag that names no version can never match, so the write
+// fails with 412.
+func ifMatchVersion(c echo.Context) (*int, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, NewProblem(http.StatusPreconditionFailed, "If-Match must be a single entity tag from this API")
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return nil, NewProblem(http.StatusPreconditionFailed, "If-Match must be a single entity tag from this API")
+	}
+	return &version, nil
+}
+
+// noneMatch reports whether an If-None-Match header lists tag, compared
+// weakly as RFC 9110 asks for.
+func noneMatch(c echo.Context, tag string) bool {
+	for _, candidate := range strings.Split(c.Request().Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// blogResponse writes blog, rendered, with its ETag, or just 304 when the
+// client already has this version.
+func (s *Server) blogResponse(c echo.Context, blog *database.Blog) error {
+	tag := etag(blog.Version)
+	c.Response().Header().Set("ETag", tag)
+	if noneMatch(c, tag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if err := s.render(blog); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, blog)
+}
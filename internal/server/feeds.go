@@ -0,0 +1,179 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"blog-platform/internal/database"
+	"blog-platform/internal/feed"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultSiteTitle names the blog in its feeds when the server is given no
+// title.
+const DefaultSiteTitle = "Blog"
+
+// DefaultFeedItems is how many posts a feed holds when the server is not told.
+const DefaultFeedItems = 20
+
+const (
+	rssType      = "application/rss+xml; charset=UTF-8"
+	atomType     = "application/atom+xml; charset=UTF-8"
+	jsonFeedType = "application/feed+json; charset=UTF-8"
+)
+
+// RSSHandler serves the newest published posts as RSS 2.0.
+func (s *Server) RSSHandler(c echo.Context) error {
+	return s.feed(c, database.ListOptions{}, "", feed.RSS, rssType)
+}
+
+// AtomHandler serves the newest published posts as Atom 1.0.
+func (s *Server) AtomHandler(c echo.Context) error {
+	return s.feed(c, database.ListOptions{}, "", feed.Atom, atomType)
+}
+
+// JSONFeedHandler serves the newest published posts as JSON Feed 1.1.
+func (s *Server) JSONFeedHandler(c echo.Context) error {
+	return s.feed(c, database.ListOptions{}, "", feed.JSON, jsonFeedType)
+}
+
+// CategoryFeedHandler serves the newest published posts in a category as
+// RSS 2.0.
+func (s *Server) CategoryFeedHandler(c echo.Context) error {
+	category := c.Param("category")
+	return s.feed(c, database.ListOptions{Category: category}, category, feed.RSS, rssType)
+}
+
+// TagFeedHandler serves the newest published posts with a tag as RSS 2.0.
+func (s *Server) TagFeedHandler(c echo.Context) error {
+	tag := database.NormalizeTag(c.Param("tag"))
+	return s.feed(c, database.ListOptions{Tags: []string{tag}}, tag, feed.RSS, rssType)
+}
+
+// feed serves the newest published posts opts selects, written by write. A
+// feed is as new as the last change to a post in it, which is sent as
+// Last-Modified so feed readers polling with If-Modified-Since get 304 until
+// something changes.
+func (s *Server) feed(c echo.Context, opts database.ListOptions, topic string, write func(feed.Feed) ([]byte, error), contentType string) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	opts.Limit = cmp.Or(s.FeedItems, DefaultFeedItems)
+	opts.Statuses = []database.Status{database.StatusPublished}
+	page, err := s.DB.ListBlogs(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// HTTP dates have no fractions of a second, so neither may the time they
+	// are compared with.
+	var lastModified time.Time
+	for _, blog := range page.Items {
+		if blog.UpdatedAt.After(lastModified) {
+			lastModified = blog.UpdatedAt
+		}
+	}
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		c.Response().Header().Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		if since, err := http.ParseTime(c.Request().Header.Get(echo.HeaderIfModifiedSince)); err == nil && !lastModified.After(since) {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+
+	if err := s.render(page.Items...); err != nil {
+		return err
+	}
+
+	base := s.baseURL(c)
+	title := cmp.Or(s.SiteTitle, DefaultSiteTitle)
+	f := feed.Feed{
+		Title:       title,
+		Description: fmt.Sprintf("The newest posts on %s", title),
+		Link:        base + "/",
+		URL:         base + c.Request().URL.EscapedPath(),
+		Updated:     lastModified,
+		Items:       make([]feed.Item, 0, len(page.Items)),
+	}
+	if topic != "" {
+		f.Title = fmt.Sprintf("%s - %s", title, topic)
+		f.Description = fmt.Sprintf("The newest posts on %s about %s", title, topic)
+	}
+
+	names := map[string]string{}
+	for _, blog := range page.Items {
+		authors, err := s.authorNames(ctx, names, append([]string{blog.AuthorID}, blog.CoAuthors...))
+		if err != nil {
+			return err
+		}
+
+		published := blog.CreatedAt
+		if blog.PublishedAt != nil {
+			published = *blog.PublishedAt
+		}
+		f.Items = append(f.Items, feed.Item{
+			ID:         fmt.Sprintf("%s/posts/%s", base, blog.ID.Hex()),
+			Title:      blog.Title,
+			Link:       fmt.Sprintf("%s/posts/by-slug/%s", base, url.PathEscape(blog.Slug)),
+			Summary:    blog.Excerpt,
+			HTML:       blog.ContentHTML,
+			Authors:    authors,
+			Categories: append([]string{blog.Category}, blog.Tags...),
+			Published:  published,
+			Updated:    blog.UpdatedAt,
+		})
+	}
+
+	body, err := write(f)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, contentType, body)
+}
+
+// baseURL is where the blog is served, without a trailing slash. Without a
+// configured BaseURL it is the host the request came to.
+func (s *Server) baseURL(c echo.Context) string {
+	if s.BaseURL != "" {
+		return strings.TrimSuffix(s.BaseURL, "/")
+	}
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+// authorNames returns the names of the users ids, remembering them in names
+// for the next post. Users who no longer exist are left out.
+func (s *Server) authorNames(ctx context.Context, names map[string]string, ids []string) ([]string, error) {
+	if s.Users == nil {
+		return nil, nil
+	}
+
+	var authors []string
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		name, ok := names[id]
+		if !ok {
+			user, err := s.Users.GetUser(ctx, id)
+			switch {
+			case errors.Is(err, database.ErrNotFound), errors.Is(err, database.ErrInvalidID):
+			case err != nil:
+				return nil, err
+			default:
+				name = user.Name
+			}
+			names[id] = name
+		}
+		if name != "" {
+			authors = append(authors, name)
+		}
+	}
+	return authors, nil
+}
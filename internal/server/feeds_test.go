@@ -0,0 +1,193 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeds(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	users := database.NewMemoryUsers()
+	s := &server.Server{
+		DB:        repository,
+		Users:     users,
+		SiteTitle: "Notes",
+		BaseURL:   "https://blog.example/",
+		FeedItems: 2,
+	}
+
+	ctx := context.Background()
+	ada, err := users.CreateUser(ctx, dto.UserCreateDTO{Email: "ada@example.com", Name: "Ada", Roles: []auth.Role{auth.RoleAuthor}})
+	require.NoError(t, err)
+
+	content, published := "Some *words* here.", string(database.StatusPublished)
+	createPost(t, repository, dto.BlogCreateDto{Title: "Oldest", Category: "Tech", Content: content, Tags: []string{"go"}, Author: ada.ID.Hex(), Status: published})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Draft", Category: "Tech", Content: content, Tags: []string{"go"}, Author: ada.ID.Hex(), Status: string(database.StatusDraft)})
+	middle := createPost(t, repository, dto.BlogCreateDto{Title: "Middle", Category: "Life", Content: content, Author: ada.ID.Hex(), Status: published})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Newest", Category: "Tech", Content: content, Tags: []string{"go", "web"}, Author: ada.ID.Hex(), Status: published})
+
+	get := func(handler echo.HandlerFunc, path string, header http.Header, params ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if len(params) == 2 {
+			c.SetParamNames(params[0])
+			c.SetParamValues(params[1])
+		}
+
+		serve(handler, c)
+		return rec
+	}
+
+	type rss struct {
+		Channel struct {
+			Title string `xml:"title"`
+			// Links holds the channel's link and, after it, the atom:link
+			// to the feed itself.
+			Links []string `xml:"link"`
+			Items []struct {
+				Title   string `xml:"title"`
+				Link    string `xml:"link"`
+				Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+				Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	titles := func(doc rss) []string {
+		var titles []string
+		for _, item := range doc.Channel.Items {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+
+	t.Run("RSS holds the newest published posts", func(t *testing.T) {
+		rec := get(s.RSSHandler, "/feed.xml", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/rss+xml; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+
+		var doc rss
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, "Notes", doc.Channel.Title)
+		assert.Equal(t, "https://blog.example/", doc.Channel.Links[0])
+		assert.Equal(t, []string{"Newest", "Middle"}, titles(doc))
+		assert.Equal(t, "https://blog.example/posts/by-slug/newest", doc.Channel.Items[0].Link)
+		assert.Equal(t, "<p>Some <em>words</em> here.</p>\n", doc.Channel.Items[0].Content)
+		assert.Equal(t, "Ada", doc.Channel.Items[0].Creator)
+	})
+
+	t.Run("Atom", func(t *testing.T) {
+		rec := get(s.AtomHandler, "/atom.xml", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/atom+xml; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+
+		var doc struct {
+			ID      string   `xml:"id"`
+			Entries []string `xml:"entry>title"`
+		}
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, "https://blog.example/atom.xml", doc.ID)
+		assert.Equal(t, []string{"Newest", "Middle"}, doc.Entries)
+	})
+
+	t.Run("JSON Feed", func(t *testing.T) {
+		rec := get(s.JSONFeedHandler, "/feed.json", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "application/feed+json; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+
+		var doc struct {
+			Version string `json:"version"`
+			Items   []struct {
+				ID      string `json:"id"`
+				Title   string `json:"title"`
+				Summary string `json:"summary"`
+			} `json:"items"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&doc))
+		assert.Equal(t, "https://jsonfeed.org/version/1.1", doc.Version)
+		require.Len(t, doc.Items, 2)
+		assert.Equal(t, "https://blog.example/posts/"+middle, doc.Items[1].ID)
+		assert.Equal(t, "Some words here.", doc.Items[1].Summary)
+	})
+
+	t.Run("Per category and tag", func(t *testing.T) {
+		rec := get(s.CategoryFeedHandler, "/categories/Tech/feed.xml", nil, "category", "Tech")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var doc rss
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, "Notes - Tech", doc.Channel.Title)
+		assert.Equal(t, []string{"Newest", "Oldest"}, titles(doc))
+
+		rec = get(s.TagFeedHandler, "/tags/web/feed.xml", nil, "tag", "web")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		doc = rss{}
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, []string{"Newest"}, titles(doc))
+
+		rec = get(s.TagFeedHandler, "/tags/none/feed.xml", nil, "tag", "none")
+		require.Equal(t, http.StatusOK, rec.Code, "an empty feed is still a feed")
+		assert.Empty(t, rec.Header().Get(echo.HeaderLastModified))
+	})
+
+	t.Run("Conditional GET", func(t *testing.T) {
+		rec := get(s.RSSHandler, "/feed.xml", nil)
+		lastModified := rec.Header().Get(echo.HeaderLastModified)
+		require.NotEmpty(t, lastModified)
+		modified, err := http.ParseTime(lastModified)
+		require.NoError(t, err)
+
+		rec = get(s.RSSHandler, "/feed.xml", http.Header{"If-Modified-Since": {lastModified}})
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Empty(t, rec.Body.String())
+
+		earlier := modified.Add(-time.Second).Format(http.TimeFormat)
+		rec = get(s.RSSHandler, "/feed.xml", http.Header{"If-Modified-Since": {earlier}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = get(s.RSSHandler, "/feed.xml", http.Header{"If-Modified-Since": {"yesterday"}})
+		assert.Equal(t, http.StatusOK, rec.Code, "a date that cannot be read is ignored")
+	})
+
+	t.Run("Links to the host without a base URL", func(t *testing.T) {
+		bare := &server.Server{DB: repository}
+		rec := get(bare.RSSHandler, "/feed.xml", nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var doc rss
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		assert.Equal(t, server.DefaultSiteTitle, doc.Channel.Title)
+		assert.Equal(t, "http://example.com/", doc.Channel.Links[0])
+		assert.Equal(t, []string{"Newest", "Middle", "Oldest"}, titles(doc))
+		assert.Empty(t, doc.Channel.Items[0].Creator)
+	})
+
+	t.Run("Escapes slugs in links", func(t *testing.T) {
+		createPost(t, repository, dto.BlogCreateDto{Title: "東京", Category: "Travel", Content: content, Tags: []string{"tokyo"}, Author: ada.ID.Hex(), Status: published})
+		rec := get(s.TagFeedHandler, "/tags/tokyo/feed.xml", nil, "tag", "tokyo")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var doc rss
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		require.Len(t, doc.Channel.Items, 1)
+		assert.Equal(t, "https://blog.example/posts/by-slug/%E6%9D%B1%E4%BA%AC", doc.Channel.Items[0].Link)
+	})
+}
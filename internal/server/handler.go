@@ -1,12 +1,18 @@
 package server
 
 import (
+	"blog-platform/internal/auth"
 	"blog-platform/internal/database"
+	"blog-platform/internal/diff"
 	"blog-platform/internal/dto"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"github.com/go-playground/validator/v10"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -17,7 +23,8 @@ func (s *Server) HealthHandler(c echo.Context) error {
 	defer cancel()
 	err := s.DB.Health(ctx)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"status": fmt.Sprintf("unhealthy %s", err)})
+		c.Logger().Error(err)
+		return NewProblem(http.StatusServiceUnavailable, "database is unavailable")
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"status": "healthy"})
@@ -27,22 +34,44 @@ func (s *Server) CreateBlogHandler(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
 	defer cancel()
 
+	p, err := requireRole(c, auth.ScopePostsWrite, auth.Writers...)
+	if err != nil {
+		return err
+	}
+
 	blog := new(dto.BlogCreateDto)
 
 	if err := c.Bind(blog); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return NewProblem(http.StatusBadRequest, "invalid request body")
 	}
 
-	validate := validator.New()
 	if err := validate.Struct(blog); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return err
+	}
+
+	if blog.Status == string(database.StatusScheduled) && !blog.PublishAt.After(time.Now()) {
+		return NewProblem(http.StatusBadRequest, "publishAt must be in the future")
+	}
+	blog.Author = p.ID
+
+	if blog.Category, err = s.resolveCategory(ctx, blog.Category); err != nil {
+		return err
+	}
+
+	for _, id := range blog.CoAuthors {
+		if _, err := s.Users.GetUser(ctx, id); errors.Is(err, database.ErrNotFound) {
+			return NewProblem(http.StatusBadRequest, fmt.Sprintf("co-author %s does not exist", id))
+		} else if err != nil {
+			return err
+		}
 	}
 
 	createdId, err := s.DB.CreateBlog(ctx, *blog)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("failed to create blog %s", err)})
+		return err
 	}
+	s.changed(*createdId)
 
 	response := map[string]string{"data": *createdId}
 	return c.JSON(http.StatusCreated, response)
@@ -56,28 +85,186 @@ func (s *Server) GetBlogHandler(c echo.Context) error {
 	data, err := s.DB.GetBlog(ctx, id)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "internal server error"})
+		return err
 	}
-	return c.JSON(http.StatusOK, data)
+	if !visible(c, data) {
+		return database.ErrNotFound
+	}
+	return s.blogResponse(c, data)
+}
+
+// visible reports whether the caller may read blog: anyone may read published
+// blogs, only those who can edit a blog may read it before then, and API
+// keys only with the posts:read scope.
+func visible(c echo.Context, blog *database.Blog) bool {
+	p := principal(c)
+	return blog.Status == database.StatusPublished || p.Allows(auth.ScopePostsRead) && p.CanEdit(blog.Authors()...)
+}
+
+// render fills in the HTML of blogs, from the cache when it can.
+func (s *Server) render(blogs ...*database.Blog) error {
+	for _, blog := range blogs {
+		html, err := s.Rendered.Render(blog.ID.Hex(), blog.Version, blog.ContentFormat, blog.Content)
+		if err != nil {
+			return err
+		}
+		blog.ContentHTML = html
+	}
+	return nil
+}
+
+// changed drops what the server has cached about the blog id, and the
+// sitemap and related posts it may appear in, for them to be worked out
+// again.
+func (s *Server) changed(id string) {
+	s.Rendered.Forget(id)
+	s.Sitemaps.Invalidate()
+	s.Related.Invalidate()
+}
+
+// GetBlogBySlugHandler serves a blog by its slug. A slug the blog has since
+// replaced redirects permanently to the current one.
+func (s *Server) GetBlogBySlugHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	slug := c.Param("slug")
+	data, err := s.DB.GetBlogBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if !visible(c, data) {
+		return database.ErrNotFound
+	}
+	if data.Slug != slug {
+		return c.Redirect(http.StatusMovedPermanently, "/posts/by-slug/"+url.PathEscape(data.Slug))
+	}
+	return s.blogResponse(c, data)
 }
 
 func (s *Server) GetBlogsHandler(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
 	defer cancel()
-	term := c.QueryParam("term")
-	var data []*database.Blog
-	var err error
-	if term != "" {
-		data, err = s.DB.GetBlogsByTerm(ctx, term)
-	} else {
-		data, err = s.DB.GetBlogs(ctx)
+
+	var query dto.BlogListQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
 	}
 
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	if query.Term != "" {
+		// A search ranks its hits and pages by offset, so none of the list
+		// filters or its cursor would mean anything to it.
+		if query.Cursor != "" || query.Sort != "" || query.Order != "" || query.Category != "" || query.Author != "" ||
+			len(query.Tags) > 0 || query.TagMatch != "" || query.CreatedBefore != nil || query.CreatedAfter != nil {
+			return NewProblem(http.StatusBadRequest, "term can only be combined with limit and view; page through searches at /search")
+		}
+
+		result, err := s.DB.SearchBlogs(ctx, database.SearchOptions{
+			Query:    query.Term,
+			Statuses: []database.Status{database.StatusPublished},
+			Limit:    query.Limit,
+		})
+		if err != nil {
+			return err
+		}
+		page := database.BlogPage{Items: []*database.Blog{}, Total: result.Total, Truncated: result.Truncated}
+		for _, hit := range result.Items {
+			page.Items = append(page.Items, hit.Blog)
+		}
+		return s.blogPage(c, &page, query.View)
+	}
+
+	return s.listPublished(ctx, c, query)
+}
+
+// listPublished writes the page of published blogs query filters for.
+func (s *Server) listPublished(ctx context.Context, c echo.Context, query dto.BlogListQuery) error {
+	data, err := s.DB.ListBlogs(ctx, database.ListOptions{
+		Limit:         query.Limit,
+		Cursor:        query.Cursor,
+		Sort:          database.SortField(query.Sort),
+		Order:         database.SortOrder(query.Order),
+		Statuses:      []database.Status{database.StatusPublished},
+		Category:      query.Category,
+		Author:        query.Author,
+		Tags:          database.NormalizeTags(query.Tags),
+		TagMatch:      database.TagMatch(query.TagMatch),
+		CreatedBefore: query.CreatedBefore,
+		CreatedAfter:  query.CreatedAfter,
+		OmitContent:   query.View == "summary",
+	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "internal server error"})
+		return err
 	}
 
-	return c.JSON(http.StatusOK, data)
+	return s.blogPage(c, data, query.View)
+}
+
+// BlogSummary is a blog without its content, for lists that only show what
+// each post is about.
+type BlogSummary struct {
+	*database.Blog
+	Content     string `json:"content,omitempty"`
+	ContentHTML string `json:"contentHtml,omitempty"`
+}
+
+type BlogSummaryPage struct {
+	Items      []BlogSummary `json:"items"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	Total      int64         `json:"total"`
+	Truncated  bool          `json:"truncated,omitempty"`
+}
+
+// blogPage writes page in view: the blogs rendered in full, or just their
+// summaries.
+func (s *Server) blogPage(c echo.Context, page *database.BlogPage, view string) error {
+	if view != "summary" {
+		if err := s.render(page.Items...); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, page)
+	}
+
+	summaries := BlogSummaryPage{Items: []BlogSummary{}, NextCursor: page.NextCursor, Total: page.Total, Truncated: page.Truncated}
+	for _, blog := range page.Items {
+		summaries.Items = append(summaries.Items, BlogSummary{Blog: blog})
+	}
+	return c.JSON(http.StatusOK, summaries)
+}
+
+func (s *Server) SearchHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	var query dto.SearchQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	result, err := s.DB.SearchBlogs(ctx, database.SearchOptions{
+		Query:    query.Q,
+		Statuses: []database.Status{database.StatusPublished},
+		Limit:    query.Limit,
+		Offset:   query.Offset,
+	})
+	if err != nil {
+		return err
+	}
+	for _, hit := range result.Items {
+		if err := s.render(hit.Blog); err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, result)
 }
 
 func (s *Server) UpdateBlogHandler(c echo.Context) error {
@@ -85,32 +272,346 @@ func (s *Server) UpdateBlogHandler(c echo.Context) error {
 	defer cancel()
 
 	var updateBlog dto.BlogUpdateDTO
+	if err := c.Bind(&updateBlog); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
 	updateBlog.Id = c.Param("id")
 
-	if err := c.Bind(&updateBlog); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	if err := s.requireEditable(ctx, c, updateBlog.Id, auth.ScopePostsWrite); err != nil {
+		return err
 	}
 
-	validate := validator.New()
 	if err := validate.Struct(updateBlog); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return err
 	}
+	updateBlog.Author = author(c)
+
+	if updateBlog.Category != nil {
+		category, err := s.resolveCategory(ctx, *updateBlog.Category)
+		if err != nil {
+			return err
+		}
+		updateBlog.Category = &category
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	updateBlog.Version = version
 
 	data, err := s.DB.UpdateBlog(ctx, updateBlog)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": "internal server error"})
+		return err
+	}
+	s.changed(data.ID.Hex())
+	if err := s.render(data); err != nil {
+		return err
 	}
+	c.Response().Header().Set("ETag", etag(data.Version))
 	return c.JSON(http.StatusOK, data)
 }
 
 func (s *Server) DeleteBlogHandler(c echo.Context) error {
 	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
 	defer cancel()
+
+	if err := s.requireEditable(ctx, c, c.Param("id"), auth.ScopePostsWrite); err != nil {
+		return err
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.DB.DeleteBlog(ctx, dto.BlogDeleteDTO{Id: c.Param("id"), Version: version})
+	if err != nil {
+		return err
+	}
+	s.changed(data.ID.Hex())
+	if err := s.render(data); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+// TrashHandler lists the blogs in the trash, most recently created first.
+func (s *Server) TrashHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsRead, auth.Editors...); err != nil {
+		return err
+	}
+
+	var query dto.TrashQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	data, err := s.DB.ListBlogs(ctx, database.ListOptions{
+		Limit:  query.Limit,
+		Cursor: query.Cursor,
+		Trash:  true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.render(data.Items...); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *Server) RestoreBlogHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.Editors...); err != nil {
+		return err
+	}
+
+	data, err := s.DB.RestoreBlog(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	s.changed(data.ID.Hex())
+	if err := s.render(data); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+// PurgeBlogHandler deletes a blog in the trash for good. Blogs have to be
+// deleted into the trash first.
+func (s *Server) PurgeBlogHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.Editors...); err != nil {
+		return err
+	}
+
+	if _, err := s.DB.PurgeBlog(ctx, c.Param("id")); err != nil {
+		return err
+	}
+	s.changed(c.Param("id"))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) PublishBlogHandler(c echo.Context) error {
+	return s.changeStatus(c, database.StatusPublished)
+}
+
+func (s *Server) UnpublishBlogHandler(c echo.Context) error {
+	return s.changeStatus(c, database.StatusDraft)
+}
+
+func (s *Server) ArchiveBlogHandler(c echo.Context) error {
+	return s.changeStatus(c, database.StatusArchived)
+}
+
+func (s *Server) ScheduleBlogHandler(c echo.Context) error {
+	return s.changeStatus(c, database.StatusScheduled)
+}
+
+// changeStatus moves the blog named in the path to status. Scheduling reads
+// the publish time from the request body.
+func (s *Server) changeStatus(c echo.Context, status database.Status) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if err := s.requireEditable(ctx, c, c.Param("id"), auth.ScopePostsWrite); err != nil {
+		return err
+	}
+
+	var change dto.BlogStatusDTO
+	if status == database.StatusScheduled {
+		if err := c.Bind(&change); err != nil {
+			return NewProblem(http.StatusBadRequest, "invalid request body")
+		}
+	}
+	change.Id = c.Param("id")
+	change.Status = string(status)
+
+	if err := validate.Struct(change); err != nil {
+		return err
+	}
+
+	if status == database.StatusScheduled && !change.PublishAt.After(time.Now()) {
+		return NewProblem(http.StatusBadRequest, "publishAt must be in the future")
+	}
+
+	data, err := s.DB.SetBlogStatus(ctx, change)
+	if err != nil {
+		return err
+	}
+	s.changed(data.ID.Hex())
+	if err := s.render(data); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+// revisionNumber reads the revision number in the path parameter name.
+func revisionNumber(c echo.Context, name string) (int, error) {
+	number, err := strconv.Atoi(c.Param(name))
+	if err != nil || number < 1 {
+		return 0, NewProblem(http.StatusBadRequest, "revision must be a positive number")
+	}
+	return number, nil
+}
+
+func (s *Server) ListRevisionsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if err := s.requireEditable(ctx, c, c.Param("id"), auth.ScopePostsRead); err != nil {
+		return err
+	}
+
+	revisions, err := s.DB.ListRevisions(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string][]*database.RevisionSummary{"items": revisions})
+}
+
+func (s *Server) GetRevisionHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if err := s.requireEditable(ctx, c, c.Param("id"), auth.ScopePostsRead); err != nil {
+		return err
+	}
+
+	number, err := revisionNumber(c, "rev")
+	if err != nil {
+		return err
+	}
+
+	revision, err := s.DB.GetRevision(ctx, c.Param("id"), number)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, revision)
+}
+
+// RevisionDiff holds the edits that turn revision From of a blog into
+// revision To, for every field that differs between them.
+type RevisionDiff struct {
+	From   int                    `json:"from"`
+	To     int                    `json:"to"`
+	Mode   string                 `json:"mode"`
+	Fields map[string][]diff.Edit `json:"fields"`
+}
+
+func (s *Server) DiffRevisionsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	if err := s.requireEditable(ctx, c, c.Param("id"), auth.ScopePostsRead); err != nil {
+		return err
+	}
+
+	var query dto.RevisionDiffQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
 	id := c.Param("id")
+	from, err := s.DB.GetRevision(ctx, id, query.From)
+	if err != nil {
+		return err
+	}
+	to, err := s.DB.GetRevision(ctx, id, query.To)
+	if err != nil {
+		return err
+	}
+
+	compare := diff.Lines
+	if query.Mode == "word" {
+		compare = diff.Words
+	}
+
+	result := RevisionDiff{From: from.Number, To: to.Number, Mode: cmp.Or(query.Mode, "line"), Fields: map[string][]diff.Edit{}}
+	fields := map[string][2]string{
+		"title":         {from.Title, to.Title},
+		"category":      {from.Category, to.Category},
+		"content":       {from.Content, to.Content},
+		"contentFormat": {string(from.ContentFormat), string(to.ContentFormat)},
+		"tags":          {strings.Join(from.Tags, "\n"), strings.Join(to.Tags, "\n")},
+	}
+	for field, versions := range fields {
+		if versions[0] == versions[1] {
+			continue
+		}
+		edits, err := compare(ctx, versions[0], versions[1])
+		if errors.Is(err, context.DeadlineExceeded) {
+			return NewProblem(http.StatusServiceUnavailable, "the revisions took too long to compare")
+		}
+		if err != nil {
+			return err
+		}
+		result.Fields[field] = edits
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// RestoreRevisionHandler brings back the content of an earlier revision. The
+// restore is an update like any other and is stored as a new revision.
+func (s *Server) RestoreRevisionHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if err := s.requireEditable(ctx, c, c.Param("id"), auth.ScopePostsWrite); err != nil {
+		return err
+	}
 
-	data, err := s.DB.DeleteBlog(ctx, id)
+	number, err := revisionNumber(c, "rev")
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"message": fmt.Sprintf("internal server error - %v", err)})
+		return err
+	}
+
+	id := c.Param("id")
+	revision, err := s.DB.GetRevision(ctx, id, number)
+	if err != nil {
+		return err
+	}
+
+	restore := dto.BlogUpdateDTO{
+		Id:      id,
+		Title:   &revision.Title,
+		Content: &revision.Content,
+		Tags:    &revision.Tags,
+		Author:  author(c),
+	}
+	if revision.ContentFormat != "" {
+		restore.ContentFormat = (*string)(&revision.ContentFormat)
+	}
+	// A category deleted since leaves the blog in the one it is in now.
+	if category, err := s.resolveCategory(ctx, revision.Category); err == nil {
+		restore.Category = &category
+	} else if !errors.As(err, new(*Problem)) {
+		return err
+	}
+
+	data, err := s.DB.UpdateBlog(ctx, restore)
+	if err != nil {
+		return err
+	}
+	s.changed(data.ID.Hex())
+	if err := s.render(data); err != nil {
+		return err
 	}
 	return c.JSON(http.StatusOK, data)
 }
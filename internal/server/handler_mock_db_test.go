@@ -4,6 +4,7 @@ import (
 	"blog-platform/internal/database"
 	"blog-platform/internal/dto"
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -17,9 +18,9 @@ func (m *mockDB) CreateBlog(ctx context.Context, create dto.BlogCreateDto) (*str
 	return args.Get(0).(*string), args.Error(1)
 }
 
-func (m *mockDB) GetBlogs(ctx context.Context) ([]*database.Blog, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*database.Blog), args.Error(1)
+func (m *mockDB) ListBlogs(ctx context.Context, opts database.ListOptions) (*database.BlogPage, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).(*database.BlogPage), args.Error(1)
 }
 
 func (m *mockDB) GetBlog(ctx context.Context, id string) (*database.Blog, error) {
@@ -32,14 +33,54 @@ func (m *mockDB) UpdateBlog(ctx context.Context, update dto.BlogUpdateDTO) (*dat
 	return args.Get(0).(*database.Blog), args.Error(1)
 }
 
-func (m *mockDB) DeleteBlog(ctx context.Context, id string) (*database.Blog, error) {
+func (m *mockDB) DeleteBlog(ctx context.Context, del dto.BlogDeleteDTO) (*database.Blog, error) {
+	args := m.Called(ctx, del)
+	return args.Get(0).(*database.Blog), args.Error(1)
+}
+
+func (m *mockDB) SearchBlogs(ctx context.Context, opts database.SearchOptions) (*database.SearchResult, error) {
+	args := m.Called(ctx, opts)
+	return args.Get(0).(*database.SearchResult), args.Error(1)
+}
+
+func (m *mockDB) GetBlogBySlug(ctx context.Context, slug string) (*database.Blog, error) {
+	args := m.Called(ctx, slug)
+	return args.Get(0).(*database.Blog), args.Error(1)
+}
+
+func (m *mockDB) ListRevisions(ctx context.Context, id string) ([]*database.RevisionSummary, error) {
 	args := m.Called(ctx, id)
+	return args.Get(0).([]*database.RevisionSummary), args.Error(1)
+}
+
+func (m *mockDB) GetRevision(ctx context.Context, id string, number int) (*database.Revision, error) {
+	args := m.Called(ctx, id, number)
+	return args.Get(0).(*database.Revision), args.Error(1)
+}
+
+func (m *mockDB) RestoreBlog(ctx context.Context, id string) (*database.Blog, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*database.Blog), args.Error(1)
+}
+
+func (m *mockDB) PurgeBlog(ctx context.Context, id string) (*database.Blog, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*database.Blog), args.Error(1)
+}
+
+func (m *mockDB) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *mockDB) SetBlogStatus(ctx context.Context, change dto.BlogStatusDTO) (*database.Blog, error) {
+	args := m.Called(ctx, change)
 	return args.Get(0).(*database.Blog), args.Error(1)
 }
 
-func (m *mockDB) GetBlogsByTerm(ctx context.Context, term string) ([]*database.Blog, error) {
-	args := m.Called(ctx, term)
-	return args.Get(0).([]*database.Blog), args.Error(1)
+func (m *mockDB) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).(int64), args.Error(1)
 }
 
 func (m *mockDB) Health(ctx context.Context) error {
@@ -1,21 +1,33 @@
 package server_test
 
 import (
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"blog-platform/internal/auth"
 	"blog-platform/internal/database"
+	"blog-platform/internal/diff"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/render"
 	"blog-platform/internal/server"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+// published is the status filter every public read passes to the repository.
+var published = []database.Status{database.StatusPublished}
+
 func setupTest() (*echo.Echo, *mockDB, time.Time) {
 	e := echo.New()
 	mockDB := new(mockDB)
@@ -24,6 +36,68 @@ func setupTest() (*echo.Echo, *mockDB, time.Time) {
 	return e, mockDB, mockDate
 }
 
+// keys signs the tokens tests authenticate with, under a secret generated
+// for this run.
+var keys = newTestKeys()
+
+func newTestKeys() *auth.Keys {
+	secret := make([]byte, auth.MinSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	k, err := auth.NewHMACKeys(secret, "")
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// as authenticates req as the user id with roles.
+func as(req *http.Request, id string, roles ...auth.Role) *http.Request {
+	token, err := keys.Sign(auth.Principal{ID: id, Roles: roles}, time.Minute)
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	return req
+}
+
+// serve runs handler the way echo does, behind the authentication middleware,
+// passing a returned error to the server's error handler so the recorder
+// holds the final response.
+func serve(handler echo.HandlerFunc, c echo.Context) {
+	if err := server.Authenticate(keys, nil)(handler)(c); err != nil {
+		server.HTTPErrorHandler(err, c)
+	}
+}
+
+// call serves a JSON request to handler as the user "a1" with role, or
+// anonymously when role is empty. params holds the names of the path
+// parameters followed by their values.
+func call(t *testing.T, e *echo.Echo, handler echo.HandlerFunc, method, path, body string, role auth.Role, params ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if role != "" {
+		req = as(req, "a1", role)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames(params[:len(params)/2]...)
+	c.SetParamValues(params[len(params)/2:]...)
+
+	serve(handler, c)
+	return rec
+}
+
+// createPost stores create in repo and returns the id of the new post.
+func createPost(t *testing.T, repo database.BlogRepository, create dto.BlogCreateDto) string {
+	t.Helper()
+	id, err := repo.CreateBlog(context.Background(), create)
+	require.NoError(t, err)
+	return *id
+}
+
 func TestHealthHandler(t *testing.T) {
 	e, mockDB, _ := setupTest()
 	mockDB.On("Health", mock.Anything).Return(nil)
@@ -61,47 +135,96 @@ func TestCreateBlogHandler(t *testing.T) {
 			"tags": ["go", "echo"]
 		}`
 
-		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload))
+		req := as(httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload)), "u1", auth.RoleAuthor)
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		err := s.CreateBlogHandler(c)
-		assert.NoError(t, err)
+		serve(s.CreateBlogHandler, c)
 		assert.Equal(t, http.StatusCreated, rec.Code)
 
 		var res map[string]interface{}
-		err = json.NewDecoder(rec.Body).Decode(&res)
+		err := json.NewDecoder(rec.Body).Decode(&res)
 		if err != nil {
 			t.Fatalf("error decoding response: %s", err)
 		}
 		assert.Equal(t, "123", res["data"])
+		mockDB.AssertCalled(t, "CreateBlog", mock.Anything, mock.MatchedBy(func(create dto.BlogCreateDto) bool {
+			return create.Author == "u1"
+		}))
 	})
 
 	t.Run("Invalid Payload", func(t *testing.T) {
 		payload := `{ "title": 123 }`
-		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload))
+		req := as(httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload)), "u1", auth.RoleAuthor)
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		err := s.CreateBlogHandler(c)
-		assert.NoError(t, err)
+		serve(s.CreateBlogHandler, c)
 		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, server.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
 
 		var res map[string]interface{}
-		err = json.NewDecoder(rec.Body).Decode(&res)
+		err := json.NewDecoder(rec.Body).Decode(&res)
 		if err != nil {
 			t.Fatalf("error decoding response: %s", err)
 		}
-		assert.Equal(t, res["error"], "invalid request body")
+		assert.Equal(t, "invalid request body", res["detail"])
+	})
+
+	t.Run("Missing Fields", func(t *testing.T) {
+		payload := `{ "title": "My Test Blog" }`
+		req := as(httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload)), "u1", auth.RoleAuthor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		serve(s.CreateBlogHandler, c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var res server.Problem
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.Len(t, res.Errors, 3)
+		assert.Equal(t, server.FieldError{Field: "category", Rule: "required", Message: "is required"}, res.Errors[0])
 	})
 }
 
+func TestCreateBlogHandlerRejectsInvalidSlugs(t *testing.T) {
+	e, mockDB, _ := setupTest()
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	for _, customSlug := range []string{"Hello World", "hello--world", "-hello", "../admin"} {
+		payload := fmt.Sprintf(`{"title": "T", "category": "C", "content": "C", "tags": [], "slug": %q}`, customSlug)
+		req := as(httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload)), "u1", auth.RoleAuthor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		serve(s.CreateBlogHandler, c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, customSlug)
+
+		var res server.Problem
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.Equal(t, []server.FieldError{{Field: "slug", Rule: "slug", Message: "must be lowercase words joined by single hyphens"}}, res.Errors)
+	}
+	mockDB.AssertNotCalled(t, "CreateBlog", mock.Anything, mock.Anything)
+}
+
 func TestGetBlogHandler(t *testing.T) {
 	e, mockDB, mockDate := setupTest()
-	mockGetResponse := database.Blog{Title: "Blog Title", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate}
-	mockDB.On("GetBlog", mock.Anything, mock.Anything).Return(&mockGetResponse, nil)
+	mockGetResponse := database.Blog{Title: "Blog Title", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, Status: database.StatusPublished, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDraft := database.Blog{Title: "Draft", Status: database.StatusDraft, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDB.On("GetBlog", mock.Anything, "1234").Return(&mockGetResponse, nil)
+	mockDB.On("GetBlog", mock.Anything, "5678").Return(&mockDraft, nil)
 	s := &server.Server{
 		DB: mockDB,
 	}
@@ -110,6 +233,8 @@ func TestGetBlogHandler(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/posts/1234", nil)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1234")
 
 		err := s.GetBlogHandler(c)
 		assert.NoError(t, err)
@@ -122,19 +247,85 @@ func TestGetBlogHandler(t *testing.T) {
 		}
 		assert.Equal(t, "Blog Title", res["title"])
 	})
+
+	t.Run("Hides unpublished blogs", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/posts/5678", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("5678")
+
+		serve(s.GetBlogHandler, c)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "Draft")
+	})
+}
+
+func TestGetBlogBySlugHandler(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	mockBlog := database.Blog{Title: "Hello World", Slug: "hello-world", Status: database.StatusPublished, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDraft := database.Blog{Title: "Draft", Slug: "draft", Status: database.StatusDraft, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDB.On("GetBlogBySlug", mock.Anything, "hello-world").Return(&mockBlog, nil)
+	mockDB.On("GetBlogBySlug", mock.Anything, "hello").Return(&mockBlog, nil)
+	mockDB.On("GetBlogBySlug", mock.Anything, "draft").Return(&mockDraft, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	get := func(slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/posts/by-slug/"+slug, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+
+		serve(s.GetBlogBySlugHandler, c)
+		return rec
+	}
+
+	t.Run("Returns the blog for its current slug", func(t *testing.T) {
+		rec := get("hello-world")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res database.Blog
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.Equal(t, "Hello World", res.Title)
+		assert.Equal(t, "hello-world", res.Slug)
+	})
+
+	t.Run("Redirects an old slug to the current one", func(t *testing.T) {
+		rec := get("hello")
+		assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+		assert.Equal(t, "/posts/by-slug/hello-world", rec.Header().Get(echo.HeaderLocation))
+	})
+
+	t.Run("Hides unpublished blogs", func(t *testing.T) {
+		rec := get("draft")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
 }
 
 func TestGetBlogsHandler(t *testing.T) {
 	e, mockDB, mockDate := setupTest()
-	mockDB.On("GetBlogs", mock.Anything).Return(
-		[]*database.Blog{
-			{Title: "Blog Title 3", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate},
-			{Title: "Blog Title 4", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate},
+	mockDB.On("ListBlogs", mock.Anything, database.ListOptions{Statuses: published}).Return(
+		&database.BlogPage{
+			Items: []*database.Blog{
+				{Title: "Blog Title 3", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate},
+				{Title: "Blog Title 4", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate},
+			},
+			NextCursor: "next",
+			Total:      3,
 		}, nil)
-	mockDB.On("GetBlogsByTerm", mock.Anything, mock.Anything).Return(
-		[]*database.Blog{
-			{Title: "Blog Title 1", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate},
-			{Title: "Blog Title 2", Content: "My Second Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate},
+	mockDB.On("SearchBlogs", mock.Anything, database.SearchOptions{Query: "example", Statuses: published}).Return(
+		&database.SearchResult{
+			Items: []*database.SearchHit{
+				{Blog: &database.Blog{Title: "Blog Title 1", Content: "My First Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate}, Score: 2},
+				{Blog: &database.Blog{Title: "Blog Title 2", Content: "My Second Blog", Category: "Example", Tags: []string{"example"}, CreatedAt: mockDate, UpdatedAt: mockDate}, Score: 1},
+			},
+			Total: 2,
 		}, nil)
 	s := &server.Server{
 		DB: mockDB,
@@ -148,14 +339,64 @@ func TestGetBlogsHandler(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var res []map[string]interface{}
+		var res database.BlogPage
 		err = json.NewDecoder(rec.Body).Decode(&res)
 		if err != nil {
 			t.Fatalf("error decoding response: %s", err)
 		}
 
-		assert.Equal(t, "Blog Title 3", res[0]["title"])
-		assert.Equal(t, "Blog Title 4", res[1]["title"])
+		assert.Equal(t, "Blog Title 3", res.Items[0].Title)
+		assert.Equal(t, "Blog Title 4", res.Items[1].Title)
+		assert.Equal(t, "next", res.NextCursor)
+		assert.EqualValues(t, 3, res.Total)
+	})
+
+	t.Run("Passes paging, sorting and filters to the repository", func(t *testing.T) {
+		before := time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC)
+		opts := database.ListOptions{
+			Limit:         5,
+			Cursor:        "abc",
+			Sort:          database.SortTitle,
+			Order:         database.SortAsc,
+			Statuses:      published,
+			Category:      "Tech",
+			Tags:          []string{"go", "echo"},
+			TagMatch:      database.TagMatchAll,
+			CreatedBefore: &before,
+			Author:        "0123456789abcdef01234567",
+		}
+		mockDB.On("ListBlogs", mock.Anything, opts).Return(&database.BlogPage{Items: []*database.Blog{}}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/posts?limit=5&cursor=abc&sort=title&order=asc&category=Tech&tag=go&tag=echo&tagMatch=all&createdBefore=2025-05-01T00:00:00Z&author=0123456789abcdef01234567", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		serve(s.GetBlogsHandler, c)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mockDB.AssertCalled(t, "ListBlogs", mock.Anything, opts)
+	})
+
+	t.Run("Rejects invalid query parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=-1", "limit=500", "limit=ten", "sort=author", "order=up", "tagMatch=some", "createdAfter=yesterday", "author=ada"} {
+			req := httptest.NewRequest(http.MethodGet, "/posts?"+query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			serve(s.GetBlogsHandler, c)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+	})
+
+	t.Run("Rejects a term with list filters", func(t *testing.T) {
+		for _, query := range []string{"cursor=abc", "sort=title", "order=asc", "category=Tech", "author=0123456789abcdef01234567", "tag=go", "tagMatch=all", "createdAfter=2025-05-01T00:00:00Z"} {
+			req := httptest.NewRequest(http.MethodGet, "/posts?term=example&"+query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			serve(s.GetBlogsHandler, c)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+		mockDB.AssertNotCalled(t, "SearchBlogs", mock.Anything, mock.Anything)
 	})
 
 	t.Run("Search by Query is called when using a query parameter", func(t *testing.T) {
@@ -167,14 +408,61 @@ func TestGetBlogsHandler(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var res []map[string]interface{}
+		var res database.BlogPage
 		err = json.NewDecoder(rec.Body).Decode(&res)
 		if err != nil {
 			t.Fatalf("error decoding response: %s", err)
 		}
 
-		assert.Equal(t, "Blog Title 1", res[0]["title"])
-		assert.Equal(t, "Blog Title 2", res[1]["title"])
+		assert.Equal(t, "Blog Title 1", res.Items[0].Title)
+		assert.Equal(t, "Blog Title 2", res.Items[1].Title)
+		assert.EqualValues(t, 2, res.Total)
+	})
+}
+
+func TestSearchHandler(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	mockDB.On("SearchBlogs", mock.Anything, database.SearchOptions{Query: `"blog title" ex*`, Statuses: published, Limit: 5, Offset: 10}).Return(
+		&database.SearchResult{
+			Items: []*database.SearchHit{
+				{
+					Blog:       &database.Blog{Title: "Blog Title", CreatedAt: mockDate, UpdatedAt: mockDate},
+					Score:      3.5,
+					Highlights: map[string]string{"title": "<mark>Blog</mark> <mark>Title</mark>", "content": ""},
+				},
+			},
+			Total: 11,
+		}, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	t.Run("Returns ranked hits with highlights", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=%22blog+title%22+ex*&limit=5&offset=10", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		serve(s.SearchHandler, c)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res database.SearchResult
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.EqualValues(t, 11, res.Total)
+		assert.Equal(t, "Blog Title", res.Items[0].Blog.Title)
+		assert.Equal(t, 3.5, res.Items[0].Score)
+		assert.Equal(t, "<mark>Blog</mark> <mark>Title</mark>", res.Items[0].Highlights["title"])
+	})
+
+	t.Run("Requires a query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		serve(s.SearchHandler, c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
 	})
 }
 
@@ -187,16 +475,15 @@ func TestDeleteBlogHandler(t *testing.T) {
 	}
 
 	t.Run("Deletes blog", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodDelete, "/posts/1234", nil)
+		req := as(httptest.NewRequest(http.MethodDelete, "/posts/1234", nil), "e1", auth.RoleEditor)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
-		err := s.DeleteBlogHandler(c)
-		assert.NoError(t, err)
+		serve(s.DeleteBlogHandler, c)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
 		var res map[string]interface{}
-		err = json.NewDecoder(rec.Body).Decode(&res)
+		err := json.NewDecoder(rec.Body).Decode(&res)
 		if err != nil {
 			t.Fatalf("error decoding response: %s", err)
 		}
@@ -213,21 +500,449 @@ func TestUpdateBlogHandler(t *testing.T) {
 	}
 
 	t.Run("Updates blog", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodPut, "/posts/:id", nil)
+		req := as(httptest.NewRequest(http.MethodPut, "/posts/:id", nil), "e1", auth.RoleEditor)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames("id")
 		c.SetParamValues("1234")
 
-		err := s.UpdateBlogHandler(c)
-		assert.NoError(t, err)
+		serve(s.UpdateBlogHandler, c)
 		assert.Equal(t, http.StatusOK, rec.Code)
 
 		var res map[string]interface{}
-		err = json.NewDecoder(rec.Body).Decode(&res)
+		err := json.NewDecoder(rec.Body).Decode(&res)
 		if err != nil {
 			t.Fatalf("error decoding response: %s", err)
 		}
 		assert.Equal(t, "Blog Title", res["title"])
 	})
 }
+
+func TestRenderedContent(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	s := &server.Server{
+		DB:       repository,
+		Rendered: render.NewCache(10),
+	}
+
+	id := createPost(t, repository, dto.BlogCreateDto{
+		Title:   "Rendered",
+		Content: "# Hello\n\n<script>alert(1)</script>",
+		Author:  "a1",
+		Status:  string(database.StatusPublished),
+	})
+
+	request := func(method string, handler echo.HandlerFunc, payload string) map[string]interface{} {
+		t.Helper()
+		req := as(httptest.NewRequest(method, "/posts/"+id, strings.NewReader(payload)), "a1", auth.RoleAuthor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		serve(handler, c)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res map[string]interface{}
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		return res
+	}
+
+	t.Run("Returns the source and sanitised HTML", func(t *testing.T) {
+		res := request(http.MethodGet, s.GetBlogHandler, "")
+		assert.Equal(t, "markdown", res["contentFormat"])
+		assert.Equal(t, "# Hello\n\n<script>alert(1)</script>", res["content"])
+		assert.Equal(t, "<h1 id=\"hello\">Hello</h1>\n", res["contentHtml"])
+		assert.Equal(t, 1, s.Rendered.Len())
+	})
+
+	t.Run("Renders updates afresh", func(t *testing.T) {
+		res := request(http.MethodPut, s.UpdateBlogHandler, `{"content": "Just *text*", "contentFormat": "plain"}`)
+		assert.Equal(t, "<p>Just *text*</p>\n", res["contentHtml"])
+
+		res = request(http.MethodGet, s.GetBlogHandler, "")
+		assert.Equal(t, "plain", res["contentFormat"])
+		assert.Equal(t, "<p>Just *text*</p>\n", res["contentHtml"])
+	})
+
+	t.Run("Lists summaries on request", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		serve(s.GetBlogsHandler, e.NewContext(httptest.NewRequest(http.MethodGet, "/posts?view=summary", nil), rec))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res struct{ Items []map[string]interface{} }
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		if !assert.Len(t, res.Items, 1) {
+			return
+		}
+		item := res.Items[0]
+		assert.Equal(t, "Rendered", item["title"])
+		assert.Equal(t, "Just *text*", item["excerpt"])
+		assert.EqualValues(t, 2, item["wordCount"])
+		assert.EqualValues(t, 1, item["readingTimeMinutes"])
+		assert.NotContains(t, item, "content")
+		assert.NotContains(t, item, "contentHtml")
+
+		rec = httptest.NewRecorder()
+		serve(s.GetBlogsHandler, e.NewContext(httptest.NewRequest(http.MethodGet, "/posts?view=tiny", nil), rec))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Rejects unknown formats", func(t *testing.T) {
+		req := as(httptest.NewRequest(http.MethodPut, "/posts/"+id, strings.NewReader(`{"contentFormat": "rst"}`)), "a1", auth.RoleAuthor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		serve(s.UpdateBlogHandler, c)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestStatusHandlers(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	mockDB.On("SetBlogStatus", mock.Anything, mock.Anything).Return(&database.Blog{Title: "Blog Title", CreatedAt: mockDate, UpdatedAt: mockDate}, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	t.Run("Moves blogs between statuses", func(t *testing.T) {
+		tests := map[database.Status]echo.HandlerFunc{
+			database.StatusPublished: s.PublishBlogHandler,
+			database.StatusDraft:     s.UnpublishBlogHandler,
+			database.StatusArchived:  s.ArchiveBlogHandler,
+		}
+		for status, handler := range tests {
+			req := as(httptest.NewRequest(http.MethodPost, "/posts/1234/"+string(status), nil), "e1", auth.RoleEditor)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("1234")
+
+			serve(handler, c)
+			assert.Equal(t, http.StatusOK, rec.Code, status)
+			mockDB.AssertCalled(t, "SetBlogStatus", mock.Anything, dto.BlogStatusDTO{Id: "1234", Status: string(status)})
+		}
+	})
+
+	t.Run("Schedules a blog", func(t *testing.T) {
+		publishAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		payload := fmt.Sprintf(`{"publishAt": %q}`, publishAt.Format(time.RFC3339))
+		req := as(httptest.NewRequest(http.MethodPost, "/posts/1234/schedule", strings.NewReader(payload)), "e1", auth.RoleEditor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1234")
+
+		serve(s.ScheduleBlogHandler, c)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mockDB.AssertCalled(t, "SetBlogStatus", mock.Anything, mock.MatchedBy(func(change dto.BlogStatusDTO) bool {
+			return change.Status == "scheduled" && change.PublishAt != nil && change.PublishAt.Equal(publishAt)
+		}))
+	})
+
+	t.Run("Rejects schedules without a future publishAt", func(t *testing.T) {
+		for _, payload := range []string{`{}`, `{"publishAt": "2020-01-01T00:00:00Z"}`} {
+			req := as(httptest.NewRequest(http.MethodPost, "/posts/1234/schedule", strings.NewReader(payload)), "e1", auth.RoleEditor)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("1234")
+
+			serve(s.ScheduleBlogHandler, c)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		}
+	})
+}
+
+func TestRevisionHandlers(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	first := &database.Revision{
+		RevisionSummary: database.RevisionSummary{Number: 1, CreatedAt: mockDate, Author: "ada", Changed: []string{"title", "category", "content", "tags"}},
+		Title:           "Title",
+		Category:        "Tech",
+		Content:         "one\ntwo\n",
+		Tags:            []string{"go"},
+	}
+	second := &database.Revision{
+		RevisionSummary: database.RevisionSummary{Number: 2, CreatedAt: mockDate, Author: "grace", Changed: []string{"content"}},
+		Title:           "Title",
+		Category:        "Tech",
+		Content:         "one\nthree\n",
+		Tags:            []string{"go"},
+	}
+	mockDB.On("ListRevisions", mock.Anything, "1234").Return([]*database.RevisionSummary{&second.RevisionSummary, &first.RevisionSummary}, nil)
+	mockDB.On("GetRevision", mock.Anything, "1234", 1).Return(first, nil)
+	mockDB.On("GetRevision", mock.Anything, "1234", 2).Return(second, nil)
+	mockDB.On("UpdateBlog", mock.Anything, mock.Anything).Return(&database.Blog{Title: "Title", Content: "one\ntwo\n", Revision: 3}, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	request := func(method, target string, handler echo.HandlerFunc, names []string, values []string) *httptest.ResponseRecorder {
+		req := as(httptest.NewRequest(method, target, nil), "linus", auth.RoleEditor)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames(names...)
+		c.SetParamValues(values...)
+
+		serve(handler, c)
+		return rec
+	}
+
+	t.Run("Lists revisions", func(t *testing.T) {
+		rec := request(http.MethodGet, "/posts/1234/revisions", s.ListRevisionsHandler, []string{"id"}, []string{"1234"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res struct{ Items []database.RevisionSummary }
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.Len(t, res.Items, 2)
+		assert.Equal(t, "grace", res.Items[0].Author)
+		assert.Equal(t, []string{"content"}, res.Items[0].Changed)
+	})
+
+	t.Run("Gets a revision", func(t *testing.T) {
+		rec := request(http.MethodGet, "/posts/1234/revisions/1", s.GetRevisionHandler, []string{"id", "rev"}, []string{"1234", "1"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"content":"one\ntwo\n"`)
+
+		rec = request(http.MethodGet, "/posts/1234/revisions/first", s.GetRevisionHandler, []string{"id", "rev"}, []string{"1234", "first"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Diffs two revisions", func(t *testing.T) {
+		rec := request(http.MethodGet, "/posts/1234/diff?from=1&to=2", s.DiffRevisionsHandler, []string{"id"}, []string{"1234"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res server.RevisionDiff
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.Equal(t, "line", res.Mode)
+		assert.Equal(t, map[string][]diff.Edit{
+			"content": {{Op: diff.Equal, Text: "one\n"}, {Op: diff.Delete, Text: "two\n"}, {Op: diff.Insert, Text: "three\n"}},
+		}, res.Fields)
+
+		rec = request(http.MethodGet, "/posts/1234/diff?from=1&to=2&mode=word", s.DiffRevisionsHandler, []string{"id"}, []string{"1234"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"mode":"word"`)
+
+		for _, query := range []string{"from=1", "from=0&to=2", "from=1&to=2&mode=char"} {
+			rec = request(http.MethodGet, "/posts/1234/diff?"+query, s.DiffRevisionsHandler, []string{"id"}, []string{"1234"})
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		}
+	})
+
+	t.Run("Restores a revision as a new update", func(t *testing.T) {
+		rec := request(http.MethodPost, "/posts/1234/revisions/1/restore", s.RestoreRevisionHandler, []string{"id", "rev"}, []string{"1234", "1"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		mockDB.AssertCalled(t, "UpdateBlog", mock.Anything, dto.BlogUpdateDTO{
+			Id:       "1234",
+			Title:    &first.Title,
+			Category: &first.Category,
+			Content:  &first.Content,
+			Tags:     &first.Tags,
+			Author:   "linus",
+		})
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	mockBlog := database.Blog{Title: "Blog Title", Status: database.StatusPublished, Version: 3, CreatedAt: mockDate, UpdatedAt: mockDate}
+	mockDB.On("GetBlog", mock.Anything, "1234").Return(&mockBlog, nil)
+	s := &server.Server{
+		DB: mockDB,
+	}
+	version := func(v int) *int { return &v }
+
+	request := func(method string, handler echo.HandlerFunc, header, value string) *httptest.ResponseRecorder {
+		req := as(httptest.NewRequest(method, "/posts/1234", strings.NewReader(`{"title": "New"}`)), "e1", auth.RoleEditor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1234")
+
+		serve(handler, c)
+		return rec
+	}
+
+	t.Run("GET returns an ETag", func(t *testing.T) {
+		rec := request(http.MethodGet, s.GetBlogHandler, "", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("GET honours If-None-Match", func(t *testing.T) {
+		for _, value := range []string{`"3"`, `W/"3"`, `"1", "3"`, "*"} {
+			rec := request(http.MethodGet, s.GetBlogHandler, "If-None-Match", value)
+			assert.Equal(t, http.StatusNotModified, rec.Code, value)
+			assert.Empty(t, rec.Body.String())
+			assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+		}
+
+		rec := request(http.MethodGet, s.GetBlogHandler, "If-None-Match", `"2"`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("PUT passes If-Match to the repository", func(t *testing.T) {
+		title := "New"
+		mockDB.On("UpdateBlog", mock.Anything, dto.BlogUpdateDTO{Id: "1234", Title: &title, Author: "e1", Version: version(3)}).
+			Return(&database.Blog{Title: "New", Version: 4}, nil).Once()
+
+		rec := request(http.MethodPut, s.UpdateBlogHandler, "If-Match", `"3"`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("PUT fails with 412 on a version mismatch", func(t *testing.T) {
+		mockDB.On("UpdateBlog", mock.Anything, mock.Anything).
+			Return((*database.Blog)(nil), fmt.Errorf("id 1234 has another version - %w", database.ErrVersionMismatch)).Once()
+
+		rec := request(http.MethodPut, s.UpdateBlogHandler, "If-Match", `"2"`)
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("Writes fail with 412 for a foreign entity tag", func(t *testing.T) {
+		for _, handler := range []echo.HandlerFunc{s.UpdateBlogHandler, s.DeleteBlogHandler} {
+			rec := request(http.MethodPut, handler, "If-Match", `"abc"`)
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		}
+	})
+
+	t.Run("DELETE passes If-Match to the repository", func(t *testing.T) {
+		mockDB.On("DeleteBlog", mock.Anything, dto.BlogDeleteDTO{Id: "1234", Version: version(3)}).
+			Return(&database.Blog{Title: "Blog Title", Version: 3}, nil).Once()
+
+		rec := request(http.MethodDelete, s.DeleteBlogHandler, "If-Match", `"3"`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestTrashHandlers(t *testing.T) {
+	e, mockDB, mockDate := setupTest()
+	s := &server.Server{
+		DB: mockDB,
+	}
+
+	request := func(method, target string, handler echo.HandlerFunc, id string) *httptest.ResponseRecorder {
+		req := as(httptest.NewRequest(method, target, nil), "e1", auth.RoleEditor)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if id != "" {
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+		}
+
+		serve(handler, c)
+		return rec
+	}
+
+	t.Run("Lists the trash", func(t *testing.T) {
+		mockDB.On("ListBlogs", mock.Anything, database.ListOptions{Limit: 5, Cursor: "abc", Trash: true}).Return(
+			&database.BlogPage{Items: []*database.Blog{{Title: "Trashed", DeletedAt: &mockDate}}, Total: 1}, nil)
+
+		rec := request(http.MethodGet, "/trash?limit=5&cursor=abc", s.TrashHandler, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var res database.BlogPage
+		err := json.NewDecoder(rec.Body).Decode(&res)
+		if err != nil {
+			t.Fatalf("error decoding response: %s", err)
+		}
+		assert.Equal(t, "Trashed", res.Items[0].Title)
+		assert.Equal(t, mockDate, *res.Items[0].DeletedAt)
+
+		rec = request(http.MethodGet, "/trash?limit=-1", s.TrashHandler, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Restores a blog", func(t *testing.T) {
+		mockDB.On("RestoreBlog", mock.Anything, "1234").Return(&database.Blog{Title: "Restored"}, nil)
+
+		rec := request(http.MethodPost, "/posts/1234/restore", s.RestoreBlogHandler, "1234")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"title":"Restored"`)
+	})
+
+	t.Run("Purges a blog", func(t *testing.T) {
+		mockDB.On("PurgeBlog", mock.Anything, "1234").Return(&database.Blog{Title: "Purged"}, nil)
+		mockDB.On("PurgeBlog", mock.Anything, "5678").Return((*database.Blog)(nil), fmt.Errorf("cannot find id 5678 - %w", database.ErrNotFound))
+
+		rec := request(http.MethodDelete, "/trash/1234", s.PurgeBlogHandler, "1234")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = request(http.MethodDelete, "/trash/5678", s.PurgeBlogHandler, "5678")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestRepositoryErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"Missing blog is 404", fmt.Errorf("cannot find id 1234 - %w", database.ErrNotFound), http.StatusNotFound},
+		{"Malformed id is 400", fmt.Errorf("cannot parse id 1234 - %w", database.ErrInvalidID), http.StatusBadRequest},
+		{"Empty update is 400", fmt.Errorf("cannot update id 1234 - %w", database.ErrNoFieldsToUpdate), http.StatusBadRequest},
+		{"Conflict is 409", fmt.Errorf("failed to insert blog entry - %w", database.ErrConflict), http.StatusConflict},
+		{"Stale version is 412", fmt.Errorf("id 1234 has another version - %w", database.ErrVersionMismatch), http.StatusPreconditionFailed},
+		{"Anything else is 500", errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, mockDB, _ := setupTest()
+			mockDB.On("GetBlog", mock.Anything, mock.Anything).Return((*database.Blog)(nil), tt.err)
+			mockDB.On("UpdateBlog", mock.Anything, mock.Anything).Return((*database.Blog)(nil), tt.err)
+			mockDB.On("DeleteBlog", mock.Anything, mock.Anything).Return((*database.Blog)(nil), tt.err)
+			s := &server.Server{
+				DB: mockDB,
+			}
+
+			for _, handler := range []echo.HandlerFunc{s.GetBlogHandler, s.UpdateBlogHandler, s.DeleteBlogHandler} {
+				req := as(httptest.NewRequest(http.MethodGet, "/posts/:id", strings.NewReader(`{"title": "Blog Title"}`)), "e1", auth.RoleEditor)
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				rec := httptest.NewRecorder()
+				c := e.NewContext(req, rec)
+				c.SetParamNames("id")
+				c.SetParamValues("1234")
+
+				serve(handler, c)
+				assert.Equal(t, tt.status, rec.Code)
+				assert.NotContains(t, rec.Body.String(), tt.err.Error())
+			}
+		})
+	}
+
+	t.Run("Empty collection is an empty list", func(t *testing.T) {
+		e, mockDB, _ := setupTest()
+		mockDB.On("ListBlogs", mock.Anything, mock.Anything).Return(&database.BlogPage{Items: []*database.Blog{}}, nil)
+		s := &server.Server{
+			DB: mockDB,
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/posts", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		err := s.GetBlogsHandler(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"items": [], "total": 0}`, rec.Body.String())
+	})
+}
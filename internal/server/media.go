@@ -0,0 +1,315 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/media"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultMaxUploadBytes is how big an uploaded image may be unless
+// MaxUploadBytes says otherwise.
+const DefaultMaxUploadBytes = 10 << 20
+
+// formOverhead is what a multipart form may add to the file it carries, for
+// the boundaries and headers of its parts.
+const formOverhead = 64 << 10
+
+// UploadMediaHandler stores an image sent as the "file" field of a multipart
+// form, with the thumbnail and resized variants made of it. What kind of
+// image it is comes from its content, not from what the client says. An
+// image that was uploaded before is not stored again: the media already made
+// of it is answered with 200 instead of 201.
+func (s *Server) UploadMediaHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 20*time.Second)
+	defer cancel()
+
+	p, err := requireRole(c, auth.ScopePostsWrite, auth.Writers...)
+	if err != nil {
+		return err
+	}
+	if s.MediaStore == nil {
+		return NewProblem(http.StatusServiceUnavailable, "media uploads are disabled")
+	}
+
+	limit := s.maxUploadBytes()
+	tooLarge := NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("images may be at most %d bytes", limit))
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, limit+formOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		if errors.As(err, new(*http.MaxBytesError)) {
+			return tooLarge
+		}
+		return NewProblem(http.StatusBadRequest, `the image must be sent as the "file" field of a multipart form`)
+	}
+	if header.Size > limit {
+		return tooLarge
+	}
+	file, err := header.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open upload - %w", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read upload - %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	existing, err := s.Media.GetMediaByHash(ctx, hash)
+	if err == nil {
+		return c.JSON(http.StatusOK, s.withURLs(c, existing))
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+
+	files, err := media.Process(data)
+	switch {
+	case errors.Is(err, media.ErrUnsupported):
+		return NewProblem(http.StatusUnsupportedMediaType, "only JPEG, PNG, GIF and WebP images can be uploaded")
+	case errors.Is(err, media.ErrTooLarge):
+		return NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("images may have at most %d pixels", media.MaxPixels))
+	case err != nil:
+		return err
+	}
+
+	original := files[0]
+	create := dto.MediaCreateDTO{
+		Hash:        hash,
+		Filename:    header.Filename,
+		ContentType: original.ContentType,
+		Size:        int64(len(data)),
+		Width:       original.Width,
+		Height:      original.Height,
+		Uploader:    p.ID,
+	}
+	for _, f := range files {
+		// Keys follow from the content, so uploads of the same image racing
+		// each other write the same files.
+		key := hash + "/" + f.Name + media.Extensions[f.ContentType]
+		if err := s.MediaStore.Put(ctx, key, f.Data, f.ContentType); err != nil {
+			return err
+		}
+		create.Variants = append(create.Variants, dto.MediaVariantDTO{
+			Name:        f.Name,
+			Key:         key,
+			ContentType: f.ContentType,
+			Width:       f.Width,
+			Height:      f.Height,
+			Size:        int64(len(f.Data)),
+		})
+	}
+
+	created, err := s.Media.CreateMedia(ctx, create)
+	if errors.Is(err, database.ErrConflict) {
+		existing, err := s.Media.GetMediaByHash(ctx, hash)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, s.withURLs(c, existing))
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, s.withURLs(c, created))
+}
+
+// ListMediaHandler lists the media library, most recent uploads first.
+func (s *Server) ListMediaHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsRead, auth.Writers...); err != nil {
+		return err
+	}
+
+	var query dto.MediaListQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	list, err := s.Media.ListMedia(ctx, query.Limit)
+	if err != nil {
+		return err
+	}
+	for _, m := range list {
+		s.withURLs(c, m)
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (s *Server) GetMediaHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	m, err := s.Media.GetMedia(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, s.withURLs(c, m))
+}
+
+// MediaFileHandler serves the file of a variant of an image, such as its
+// "thumbnail" or its "original". The files of a media never change, so they
+// may be cached for good.
+func (s *Server) MediaFileHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+
+	if s.MediaStore == nil {
+		return database.ErrNotFound
+	}
+
+	m, err := s.Media.GetMedia(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	variant := m.Variant(c.Param("variant"))
+	if variant == nil {
+		return database.ErrNotFound
+	}
+
+	file, err := s.MediaStore.Get(ctx, variant.Key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return database.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	header.Set("Content-Length", strconv.FormatInt(variant.Size, 10))
+	// Uploads are served as the image they sniffed as, never as anything a
+	// browser could be talked into running.
+	header.Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, variant.ContentType, file)
+}
+
+// SetCoverHandler makes an image from the media library the cover of a post.
+func (s *Server) SetCoverHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	var change dto.BlogCoverDTO
+	change.Id = c.Param("id")
+
+	if err := s.requireEditable(ctx, c, change.Id, auth.ScopePostsWrite); err != nil {
+		return err
+	}
+
+	if err := c.Bind(&change); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(change); err != nil {
+		return err
+	}
+
+	if _, err := s.Media.GetMedia(ctx, change.MediaID); errors.Is(err, database.ErrNotFound) {
+		return NewProblem(http.StatusBadRequest, fmt.Sprintf("media %s does not exist", change.MediaID))
+	} else if err != nil {
+		return err
+	}
+
+	return s.setCover(ctx, c, change)
+}
+
+// RemoveCoverHandler takes the cover away from a post. The image stays in
+// the media library.
+func (s *Server) RemoveCoverHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if err := s.requireEditable(ctx, c, id, auth.ScopePostsWrite); err != nil {
+		return err
+	}
+
+	return s.setCover(ctx, c, dto.BlogCoverDTO{Id: id})
+}
+
+func (s *Server) setCover(ctx context.Context, c echo.Context, change dto.BlogCoverDTO) error {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	change.Version = version
+
+	data, err := s.Media.SetBlogCover(ctx, change)
+	if err != nil {
+		return err
+	}
+	s.changed(data.ID.Hex())
+	if err := s.render(data); err != nil {
+		return err
+	}
+	c.Response().Header().Set("ETag", etag(data.Version))
+	return c.JSON(http.StatusOK, data)
+}
+
+// withURLs fills in where each variant of m is served.
+func (s *Server) withURLs(c echo.Context, m *database.Media) *database.Media {
+	base := s.baseURL(c) + "/media/" + m.ID.Hex() + "/"
+	for i := range m.Variants {
+		m.Variants[i].URL = base + url.PathEscape(m.Variants[i].Name)
+	}
+	return m
+}
+
+func (s *Server) maxUploadBytes() int64 {
+	if s.MaxUploadBytes > 0 {
+		return s.MaxUploadBytes
+	}
+	return DefaultMaxUploadBytes
+}
+
+// newMediaStore picks where uploads are kept by MEDIA_DRIVER: below
+// MEDIA_DIR on the local filesystem when the variable is unset, or "s3" for
+// a bucket of any S3-compatible service.
+func newMediaStore() (media.MediaStore, error) {
+	switch driver := os.Getenv("MEDIA_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		return media.NewLocalStore(dir)
+	case "s3":
+		pathStyle, _ := strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
+		return media.NewS3Store(media.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       pathStyle,
+		}, &http.Client{Timeout: time.Minute})
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_DRIVER %q", driver)
+	}
+}
@@ -0,0 +1,156 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/media"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestMediaHandlers(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	store, err := media.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	s := &server.Server{
+		DB:             repository,
+		Media:          repository,
+		MediaStore:     store,
+		MaxUploadBytes: 64 << 10,
+		Users:          database.NewMemoryUsers(),
+		BaseURL:        "https://blog.example.com",
+	}
+
+	ctx := context.Background()
+	postID := createPost(t, repository, dto.BlogCreateDto{Title: "Covered", Category: "Tech", Content: "Content", Tags: []string{"go"}, Author: "a1"})
+
+	upload := func(field, filename string, data []byte, role auth.Role) *httptest.ResponseRecorder {
+		t.Helper()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile(field, filename)
+		require.NoError(t, err)
+		part.Write(data)
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/media", &body)
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		if role != "" {
+			req = as(req, "a1", role)
+		}
+		rec := httptest.NewRecorder()
+		serve(s.UploadMediaHandler, e.NewContext(req, rec))
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) database.Media {
+		t.Helper()
+		var m database.Media
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+		return m
+	}
+
+	var uploaded database.Media
+	t.Run("Uploads make variants", func(t *testing.T) {
+		rec := upload("file", "photo.jpg", pngImage(t, 600, 300), auth.RoleAuthor)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		uploaded = decode(rec)
+
+		assert.Equal(t, "image/png", uploaded.ContentType, "the type comes from the content")
+		assert.Equal(t, "photo.jpg", uploaded.Filename)
+		assert.Equal(t, [2]int{600, 300}, [2]int{uploaded.Width, uploaded.Height})
+		assert.Equal(t, "a1", uploaded.UploaderID)
+		assert.Len(t, uploaded.Hash, 64)
+
+		var names []string
+		for _, v := range uploaded.Variants {
+			names = append(names, v.Name)
+		}
+		assert.Equal(t, []string{"original", "thumbnail", "small"}, names)
+		assert.Equal(t, "https://blog.example.com/media/"+uploaded.ID.Hex()+"/thumbnail", uploaded.Variant("thumbnail").URL)
+		assert.NotContains(t, rec.Body.String(), `"key"`)
+	})
+
+	t.Run("The same image is stored once", func(t *testing.T) {
+		rec := upload("file", "again.png", pngImage(t, 600, 300), auth.RoleEditor)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, uploaded.ID, decode(rec).ID)
+	})
+
+	t.Run("Refused uploads", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, upload("file", "a.png", pngImage(t, 10, 10), "").Code)
+		assert.Equal(t, http.StatusForbidden, upload("file", "a.png", pngImage(t, 10, 10), auth.RoleReader).Code)
+		assert.Equal(t, http.StatusBadRequest, upload("image", "a.png", pngImage(t, 10, 10), auth.RoleAuthor).Code)
+		assert.Equal(t, http.StatusUnsupportedMediaType, upload("file", "a.png", []byte("<svg><script>alert(1)</script></svg>"), auth.RoleAuthor).Code)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, upload("file", "a.png", bytes.Repeat([]byte{0}, 128<<10), auth.RoleAuthor).Code)
+	})
+
+	t.Run("Serves the files", func(t *testing.T) {
+		rec := call(t, e, s.MediaFileHandler, http.MethodGet, "/", "", "", "id", "variant", uploaded.ID.Hex(), "thumbnail")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+		config, err := png.DecodeConfig(rec.Body)
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{200, 200}, [2]int{config.Width, config.Height})
+
+		rec = call(t, e, s.MediaFileHandler, http.MethodGet, "/", "", "", "id", "variant", uploaded.ID.Hex(), "large")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Lists the library", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, call(t, e, s.ListMediaHandler, http.MethodGet, "/media", "", "").Code)
+
+		rec := call(t, e, s.ListMediaHandler, http.MethodGet, "/media", "", auth.RoleAuthor)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var list []database.Media
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		require.Len(t, list, 1)
+		assert.Equal(t, uploaded.ID, list[0].ID)
+
+		rec = call(t, e, s.GetMediaHandler, http.MethodGet, "/", "", "", "id", uploaded.ID.Hex())
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, uploaded.Variants, decode(rec).Variants)
+	})
+
+	t.Run("Covers", func(t *testing.T) {
+		body := `{"mediaId":"` + uploaded.ID.Hex() + `"}`
+		assert.Equal(t, http.StatusForbidden, call(t, e, s.SetCoverHandler, http.MethodPut, "/", body, auth.RoleReader, "id", postID).Code)
+		assert.Equal(t, http.StatusBadRequest, call(t, e, s.SetCoverHandler, http.MethodPut, "/", `{"mediaId":"000000000000000000000000"}`, auth.RoleAuthor, "id", postID).Code)
+		assert.Equal(t, http.StatusBadRequest, call(t, e, s.SetCoverHandler, http.MethodPut, "/", `{}`, auth.RoleAuthor, "id", postID).Code)
+
+		rec := call(t, e, s.SetCoverHandler, http.MethodPut, "/", body, auth.RoleAuthor, "id", postID)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var blog database.Blog
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &blog))
+		assert.Equal(t, uploaded.ID.Hex(), blog.CoverMediaID)
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+
+		rec = call(t, e, s.RemoveCoverHandler, http.MethodDelete, "/", "", auth.RoleAuthor, "id", postID)
+		require.Equal(t, http.StatusOK, rec.Code)
+		stored, err := repository.GetBlog(ctx, postID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.CoverMediaID)
+	})
+}
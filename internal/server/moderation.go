@@ -0,0 +1,180 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/moderation"
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ModeratedComments is the answer to a bulk moderation: the comments that now
+// have the status asked for, and the ids that named no comment to moderate.
+type ModeratedComments struct {
+	Items    []*database.Comment `json:"items"`
+	NotFound []string            `json:"notFound"`
+}
+
+// ModerationQueueHandler lists the comments waiting for a moderator, oldest
+// first.
+func (s *Server) ModerationQueueHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopeCommentsModerate, auth.Editors...); err != nil {
+		return err
+	}
+
+	var query dto.ModerationQueueQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	data, err := s.Comments.ModerationQueue(ctx, query.Limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+func (s *Server) ApproveCommentsHandler(c echo.Context) error {
+	return s.moderate(c, database.CommentApproved)
+}
+
+func (s *Server) RejectCommentsHandler(c echo.Context) error {
+	return s.moderate(c, database.CommentRejected)
+}
+
+func (s *Server) MarkSpamHandler(c echo.Context) error {
+	return s.moderate(c, database.CommentSpam)
+}
+
+// moderate gives the comments whose ids the request body lists status, and
+// teaches the spam filter what the moderator decided.
+func (s *Server) moderate(c echo.Context, status database.CommentStatus) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	p, err := requireRole(c, auth.ScopeCommentsModerate, auth.Editors...)
+	if err != nil {
+		return err
+	}
+
+	var decision dto.CommentModerationDTO
+	if err := c.Bind(&decision); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+	decision.Status = string(status)
+	decision.Moderator = p.ID
+
+	if err := validate.Struct(decision); err != nil {
+		return err
+	}
+
+	moderated, err := s.Comments.ModerateComments(ctx, decision)
+	if err != nil {
+		return err
+	}
+
+	data := ModeratedComments{Items: make([]*database.Comment, len(moderated)), NotFound: []string{}}
+	for i, comment := range moderated {
+		data.Items[i] = comment.After
+		s.relearn(ctx, c, comment.Before, comment.After)
+	}
+	for _, id := range decision.Ids {
+		if !slices.ContainsFunc(data.Items, func(comment *database.Comment) bool { return comment.ID.Hex() == id }) {
+			data.NotFound = append(data.NotFound, id)
+		}
+	}
+	return c.JSON(http.StatusOK, data)
+}
+
+// lesson is what the spam filter learns from c: whether it is spam, and
+// whether there is anything to learn from it at all. Only what moderators
+// approved or marked as spam teaches it; a rejected comment may be neither,
+// just off topic or rude.
+func lesson(c *database.Comment) (spam, ok bool) {
+	if c.ModeratedAt == nil || c.DeletedAt != nil {
+		return false, false
+	}
+	switch c.Status {
+	case database.CommentApproved:
+		return false, true
+	case database.CommentSpam:
+		return true, true
+	}
+	return false, false
+}
+
+// relearn brings the spam filter up to date with a comment that changed from
+// before to after. What it learned from before is taken back before it
+// learns from after, so it ends up knowing what train would teach it.
+func (s *Server) relearn(ctx context.Context, c echo.Context, before, after *database.Comment) {
+	if s.Spam == nil {
+		return
+	}
+
+	wasSpam, learned := lesson(before)
+	isSpam, learns := lesson(after)
+	if learned == learns && wasSpam == isSpam && before.Body == after.Body {
+		return
+	}
+	if learned {
+		if err := s.Spam.Unlearn(ctx, before.Body, wasSpam); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+	if learns {
+		if err := s.Spam.Learn(ctx, after.Body, isSpam); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
+
+// screen decides whether text is shown straight away or waits for a
+// moderator. When the scorer fails the comment waits, so an outage cannot let
+// spam through.
+func (s *Server) screen(ctx context.Context, c echo.Context, text string) (database.CommentStatus, float64) {
+	if s.Spam == nil {
+		return database.CommentApproved, 0
+	}
+
+	score, err := s.Spam.Score(ctx, text)
+	if err != nil {
+		c.Logger().Error(err)
+		return database.CommentPending, 0
+	}
+	if score >= cmp.Or(s.SpamThreshold, moderation.DefaultThreshold) {
+		return database.CommentPending, score
+	}
+	return database.CommentApproved, score
+}
+
+// train teaches spam every decision moderators have made so far, since the
+// built-in filter forgets them when the server stops.
+func train(ctx context.Context, comments database.CommentRepository, spam moderation.SpamScorer) error {
+	moderated, err := comments.ListModeratedComments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, comment := range moderated {
+		isSpam, ok := lesson(comment)
+		if !ok {
+			continue
+		}
+		if err := spam.Learn(ctx, comment.Body, isSpam); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scorer rates comments containing "spam" as spam and remembers what it is
+// taught, and how many times.
+type scorer struct {
+	err     error
+	learned map[string]bool
+	lessons int
+}
+
+func (s *scorer) Score(ctx context.Context, text string) (float64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if strings.Contains(text, "spam") {
+		return 0.9, nil
+	}
+	return 0.1, nil
+}
+
+func (s *scorer) Learn(ctx context.Context, text string, spam bool) error {
+	s.learned[text] = spam
+	s.lessons++
+	return nil
+}
+
+func (s *scorer) Unlearn(ctx context.Context, text string, spam bool) error {
+	if learned, ok := s.learned[text]; !ok || learned != spam {
+		return fmt.Errorf("%q was never learned", text)
+	}
+	delete(s.learned, text)
+	return nil
+}
+
+func TestModeration(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	spam := &scorer{learned: make(map[string]bool)}
+	s := &server.Server{
+		DB:       repository,
+		Comments: repository,
+		Spam:     spam,
+	}
+
+	post := createPost(t, repository, dto.BlogCreateDto{Title: "Post", Author: "u1", Status: string(database.StatusPublished)})
+
+	create := func(body string) *database.Comment {
+		t.Helper()
+		rec := commentRequest(e, http.MethodPost, s.CreateCommentHandler, "/", `{"body": "`+body+`"}`, []string{post}, "r1", auth.RoleReader)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var comment database.Comment
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&comment))
+		return &comment
+	}
+	queue := func() []string {
+		t.Helper()
+		rec := commentRequest(e, http.MethodGet, s.ModerationQueueHandler, "/", "", nil, "e1", auth.RoleEditor)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page database.CommentPage
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+		var bodies []string
+		for _, c := range page.Items {
+			bodies = append(bodies, c.Body)
+		}
+		return bodies
+	}
+
+	ham := create("Nice post")
+	held := create("Buy spam")
+	other := create("More spam")
+
+	t.Run("Doubtful comments wait for a moderator", func(t *testing.T) {
+		assert.Equal(t, database.CommentApproved, ham.Status)
+		assert.Equal(t, database.CommentPending, held.Status)
+		assert.Equal(t, 0.9, held.SpamScore)
+		assert.Equal(t, []string{"Buy spam", "More spam"}, queue())
+
+		rec := commentRequest(e, http.MethodGet, s.ListCommentsHandler, "/", "", []string{post}, "")
+		assert.Contains(t, rec.Body.String(), "Nice post")
+		assert.NotContains(t, rec.Body.String(), "Buy spam")
+	})
+
+	t.Run("Comments wait when the scorer fails", func(t *testing.T) {
+		spam.err = errors.New("scorer is down")
+		defer func() { spam.err = nil }()
+		assert.Equal(t, database.CommentPending, create("Hello").Status)
+	})
+
+	t.Run("Only moderators moderate", func(t *testing.T) {
+		rec := commentRequest(e, http.MethodGet, s.ModerationQueueHandler, "/", "", nil, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = commentRequest(e, http.MethodGet, s.ModerationQueueHandler, "/", "", nil, "a1", auth.RoleAuthor)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		rec = commentRequest(e, http.MethodPost, s.ApproveCommentsHandler, "/", `{"ids": ["`+held.ID.Hex()+`"]}`, nil, "r1", auth.RoleReader)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Validates decisions", func(t *testing.T) {
+		for _, payload := range []string{`{}`, `{"ids": []}`, `{"ids": ["nope"]}`} {
+			rec := commentRequest(e, http.MethodPost, s.RejectCommentsHandler, "/", payload, nil, "e1", auth.RoleEditor)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		}
+	})
+
+	t.Run("Moderates in bulk and teaches the scorer", func(t *testing.T) {
+		missing := "000000000000000000000000"
+		payload := `{"ids": ["` + held.ID.Hex() + `", "` + other.ID.Hex() + `", "` + missing + `"]}`
+		rec := commentRequest(e, http.MethodPost, s.MarkSpamHandler, "/", payload, nil, "e1", auth.RoleEditor)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var moderated server.ModeratedComments
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&moderated))
+		require.Len(t, moderated.Items, 2)
+		assert.Equal(t, database.CommentSpam, moderated.Items[0].Status)
+		assert.Equal(t, "e1", moderated.Items[0].ModeratedBy)
+		assert.Equal(t, []string{missing}, moderated.NotFound)
+		assert.Equal(t, map[string]bool{"Buy spam": true, "More spam": true}, spam.learned)
+
+		rec = commentRequest(e, http.MethodPost, s.ApproveCommentsHandler, "/", `{"ids": ["`+ham.ID.Hex()+`"]}`, nil, "e1", auth.RoleEditor)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, spam.learned, "Nice post")
+		assert.False(t, spam.learned["Nice post"])
+		assert.Equal(t, []string{"Hello"}, queue())
+	})
+
+	t.Run("Edits are screened again", func(t *testing.T) {
+		rec := commentRequest(e, http.MethodPut, s.UpdateCommentHandler, "/", `{"body": "Now spam"}`, []string{post, ham.ID.Hex()}, "r1", auth.RoleReader)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"pending"`)
+
+		rec = commentRequest(e, http.MethodPut, s.UpdateCommentHandler, "/", `{"body": "Sorry"}`, []string{post, held.ID.Hex()}, "r1", auth.RoleReader)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"spam"`, "an edit does not undo a decision")
+		assert.Equal(t, map[string]bool{"Sorry": true, "More spam": true}, spam.learned, "edits take back what the old text taught")
+	})
+
+	t.Run("Only changed decisions teach the scorer", func(t *testing.T) {
+		lessons := spam.lessons
+		rec := commentRequest(e, http.MethodPost, s.MarkSpamHandler, "/", `{"ids": ["`+held.ID.Hex()+`"]}`, nil, "e1", auth.RoleEditor)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, lessons, spam.lessons, "the comment was spam already")
+
+		rec = commentRequest(e, http.MethodPost, s.RejectCommentsHandler, "/", `{"ids": ["`+other.ID.Hex()+`"]}`, nil, "e1", auth.RoleEditor)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, map[string]bool{"Sorry": true}, spam.learned, "rejected comments are neither spam nor not")
+
+		rec = commentRequest(e, http.MethodDelete, s.DeleteCommentHandler, "/", "", []string{post, held.ID.Hex()}, "e1", auth.RoleEditor)
+		require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+		assert.Empty(t, spam.learned, "deleted comments are forgotten")
+	})
+}
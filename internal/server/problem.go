@@ -0,0 +1,161 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/slug"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem is an RFC 7807 error response. Handlers return a *Problem, a
+// database error or a validator.ValidationErrors and HTTPErrorHandler turns
+// it into the response body.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes one field of the request that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// validate checks request DTOs and reports fields by their JSON names.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "query"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return ""
+	})
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slug.Valid(fl.Field().String())
+	})
+	// max counts characters, while bcrypt's limit is in bytes.
+	v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return len(fl.Field().String()) <= auth.MaxPasswordBytes
+	})
+	return v
+}
+
+// HTTPErrorHandler writes every error returned by a handler as
+// application/problem+json. Only 5xx errors are logged, and their text never
+// reaches the client.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := toProblem(err)
+	if problem.Status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+	problem.Instance = c.Request().URL.Path
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func toProblem(err error) *Problem {
+	var problem *Problem
+	var validationErrors validator.ValidationErrors
+	var httpError *echo.HTTPError
+
+	switch {
+	case errors.As(err, &problem):
+		p := *problem
+		return &p
+	case errors.As(err, &validationErrors):
+		p := NewProblem(http.StatusBadRequest, "request failed validation")
+		for _, fieldError := range validationErrors {
+			p.Errors = append(p.Errors, FieldError{
+				Field:   fieldError.Field(),
+				Rule:    fieldError.Tag(),
+				Message: validationMessage(fieldError),
+			})
+		}
+		return p
+	case errors.Is(err, database.ErrNotFound):
+		return NewProblem(http.StatusNotFound, "resource not found")
+	case errors.Is(err, database.ErrInvalidID):
+		return NewProblem(http.StatusBadRequest, "malformed id")
+	case errors.Is(err, database.ErrNoFieldsToUpdate):
+		return NewProblem(http.StatusBadRequest, "no fields to update")
+	case errors.Is(err, database.ErrInvalidCursor):
+		return NewProblem(http.StatusBadRequest, "invalid or expired cursor")
+	case errors.Is(err, database.ErrVersionMismatch):
+		return NewProblem(http.StatusPreconditionFailed, "resource was changed by another request")
+	case errors.Is(err, database.ErrConflict):
+		return NewProblem(http.StatusConflict, "resource conflicts with an existing one")
+	case errors.As(err, &httpError):
+		p := NewProblem(httpError.Code, "")
+		if message, ok := httpError.Message.(string); ok && httpError.Code < http.StatusInternalServerError {
+			p.Detail = message
+		}
+		return p
+	default:
+		return NewProblem(http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func validationMessage(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fieldError.Param())
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldError.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldError.Param())
+	case "slug":
+		return "must be lowercase words joined by single hyphens"
+	case "email":
+		return "must be an email address"
+	case "password":
+		return fmt.Sprintf("must be at most %d bytes long", auth.MaxPasswordBytes)
+	default:
+		return fmt.Sprintf("failed the %q rule", fieldError.Tag())
+	}
+}
@@ -0,0 +1,69 @@
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	handle := func(method string, err error) (*httptest.ResponseRecorder, server.Problem) {
+		e := echo.New()
+		req := httptest.NewRequest(method, "/posts/1234", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		server.HTTPErrorHandler(err, c)
+
+		var problem server.Problem
+		if rec.Body.Len() > 0 {
+			if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+				t.Fatalf("error decoding response: %s", err)
+			}
+		}
+		return rec, problem
+	}
+
+	t.Run("Writes a problem returned by a handler", func(t *testing.T) {
+		rec, problem := handle(http.MethodGet, server.NewProblem(http.StatusConflict, "slug is taken"))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, server.MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, server.Problem{
+			Type:     "about:blank",
+			Title:    "Conflict",
+			Status:   http.StatusConflict,
+			Detail:   "slug is taken",
+			Instance: "/posts/1234",
+		}, problem)
+	})
+
+	t.Run("Keeps echo client errors", func(t *testing.T) {
+		rec, problem := handle(http.MethodGet, echo.ErrNotFound)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "Not Found", problem.Detail)
+	})
+
+	t.Run("Hides internal error text", func(t *testing.T) {
+		rec, problem := handle(http.MethodGet, errors.New("mongo: connection refused on 10.0.0.4"))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "internal server error", problem.Detail)
+		assert.NotContains(t, rec.Body.String(), "10.0.0.4")
+
+		rec, _ = handle(http.MethodGet, echo.NewHTTPError(http.StatusBadGateway, "upstream 10.0.0.4 failed"))
+		assert.Equal(t, http.StatusBadGateway, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "10.0.0.4")
+	})
+
+	t.Run("Sends no body for HEAD requests", func(t *testing.T) {
+		rec, _ := handle(http.MethodHead, echo.ErrNotFound)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Zero(t, rec.Body.Len())
+	})
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"time"
+
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/related"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultRelated is how many related posts are listed unless asked for more
+// or fewer.
+const defaultRelated = 5
+
+// RelatedPost is a published post related to another, without its content,
+// and how closely, between 0 and 1.
+type RelatedPost struct {
+	BlogSummary
+	Score float64 `json:"score"`
+}
+
+// RelatedPostsHandler lists the published posts most like a post, by the
+// tags and category they share and how alike their content is.
+func (s *Server) RelatedPostsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	var query dto.RelatedQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	blog, err := s.DB.GetBlog(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	if !visible(c, blog) {
+		return database.ErrNotFound
+	}
+
+	index, err := s.relatedIndex(c)
+	if err != nil {
+		return err
+	}
+
+	matches := index.Related(relatedDocument(blog), cmp.Or(query.Limit, defaultRelated))
+	posts := []RelatedPost{}
+	if len(matches) == 0 {
+		return c.JSON(http.StatusOK, posts)
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	page, err := s.DB.ListBlogs(ctx, database.ListOptions{
+		IDs:         ids,
+		Statuses:    []database.Status{database.StatusPublished},
+		Limit:       len(ids),
+		OmitContent: true,
+	})
+	if err != nil {
+		return err
+	}
+	found := make(map[string]*database.Blog, len(page.Items))
+	for _, post := range page.Items {
+		found[post.ID.Hex()] = post
+	}
+
+	for _, match := range matches {
+		// The index may be a moment behind a post that has just gone.
+		post, ok := found[match.ID]
+		if !ok {
+			continue
+		}
+		posts = append(posts, RelatedPost{BlogSummary: BlogSummary{Blog: post}, Score: match.Score})
+	}
+	return c.JSON(http.StatusOK, posts)
+}
+
+func relatedDocument(blog *database.Blog) related.Document {
+	return related.Document{
+		ID:       blog.ID.Hex(),
+		Category: blog.Category,
+		Tags:     blog.Tags,
+		Content:  blog.Content,
+	}
+}
+
+// relatedIndex returns the index of every published post, built afresh only
+// when a post has changed since it was last.
+func (s *Server) relatedIndex(c echo.Context) (*related.Index, error) {
+	return s.Related.Get("", func() (*related.Index, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request().Context()), 30*time.Second)
+		defer cancel()
+
+		var docs []related.Document
+		opts := database.ListOptions{
+			Limit:    database.MaxLimit,
+			Statuses: []database.Status{database.StatusPublished},
+		}
+		for {
+			page, err := s.DB.ListBlogs(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, blog := range page.Items {
+				docs = append(docs, relatedDocument(blog))
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		return related.NewIndex(docs), nil
+	})
+}
@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/related"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countedGets counts the blogs fetched one at a time.
+type countedGets struct {
+	database.BlogRepository
+	gets int
+}
+
+func (r *countedGets) GetBlog(ctx context.Context, id string) (*database.Blog, error) {
+	r.gets++
+	return r.BlogRepository.GetBlog(ctx, id)
+}
+
+func TestRelatedPosts(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	counted := &countedGets{BlogRepository: repository}
+	s := &server.Server{
+		DB:      counted,
+		Users:   database.NewMemoryUsers(),
+		Related: related.NewCache(time.Hour),
+	}
+
+	published := string(database.StatusPublished)
+	channels := createPost(t, repository, dto.BlogCreateDto{Title: "Channels", Category: "go", Content: "Channels connect goroutines.", Status: published, Tags: []string{"concurrency", "go"}, Author: "a1"})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Goroutines", Category: "go", Content: "Goroutines talk over channels.", Status: published, Tags: []string{"concurrency", "go"}, Author: "a1"})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Generics", Category: "go", Content: "Type parameters and constraints.", Status: published, Tags: []string{"go"}, Author: "a1"})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Bread", Category: "cooking", Content: "Flour, water, salt.", Status: published, Tags: []string{"baking"}, Author: "a1"})
+	draft := createPost(t, repository, dto.BlogCreateDto{Title: "Select", Category: "go", Content: "Select waits on channels.", Tags: []string{"concurrency", "go"}, Author: "a1"})
+
+	get := func(id, query string, role auth.Role) *httptest.ResponseRecorder {
+		t.Helper()
+		return call(t, e, s.RelatedPostsHandler, http.MethodGet, "/posts/"+id+"/related"+query, "", role, "id", id)
+	}
+	titles := func(rec *httptest.ResponseRecorder) []string {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code)
+		var posts []server.RelatedPost
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &posts))
+		result := []string{}
+		for _, post := range posts {
+			assert.Greater(t, post.Score, 0.0)
+			result = append(result, post.Title)
+		}
+		return result
+	}
+
+	t.Run("Ranks published posts", func(t *testing.T) {
+		rec := get(channels, "", "")
+		assert.Equal(t, []string{"Goroutines", "Generics"}, titles(rec), "drafts and unrelated posts are left out")
+		assert.NotContains(t, rec.Body.String(), `"content"`)
+
+		assert.Equal(t, []string{"Goroutines"}, titles(get(channels, "?limit=1", "")))
+		assert.Equal(t, http.StatusBadRequest, get(channels, "?limit=100", "").Code)
+	})
+
+	t.Run("Fetches the related posts at once", func(t *testing.T) {
+		counted.gets = 0
+		assert.Len(t, titles(get(channels, "", "")), 2)
+		assert.Equal(t, 1, counted.gets, "only the post itself is fetched alone")
+	})
+
+	t.Run("Drafts only for those who may read them", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(draft, "", "").Code)
+		assert.Equal(t, []string{"Channels", "Goroutines", "Generics"}, titles(get(draft, "", auth.RoleAuthor)))
+	})
+
+	t.Run("Posts show up once they change", func(t *testing.T) {
+		assert.Equal(t, []string{"Goroutines", "Generics"}, titles(get(channels, "", "")))
+
+		req := as(httptest.NewRequest(http.MethodPost, "/posts/"+draft+"/publish", strings.NewReader(`{}`)), "a1", auth.RoleAuthor)
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(draft)
+		serve(s.PublishBlogHandler, c)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Contains(t, titles(get(channels, "", "")), "Select")
+	})
+
+	t.Run("Missing posts", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("000000000000000000000000", "", "").Code)
+	})
+}
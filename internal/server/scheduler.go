@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runEvery calls job every interval until ctx is done. A run that is still
+// going when the next tick arrives delays that tick rather than overlapping.
+func runEvery(ctx context.Context, interval time.Duration, job func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+// PublishDue publishes every scheduled blog whose publish time has passed.
+func (s *Server) PublishDue(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	published, err := s.DB.PublishDue(ctx, time.Now())
+	if err != nil {
+		log.Printf("failed to publish scheduled blogs: %v", err)
+		return
+	}
+	if published > 0 {
+		s.Sitemaps.Invalidate()
+		s.Related.Invalidate()
+		log.Printf("published %d scheduled blogs", published)
+	}
+}
+
+// PurgeTrash deletes for good every blog that has been in the trash for
+// longer than the retention period.
+func (s *Server) PurgeTrash(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	purged, err := s.DB.PurgeTrash(ctx, time.Now().Add(-s.TrashRetention))
+	if err != nil {
+		log.Printf("failed to purge the trash: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("purged %d blogs from the trash", purged)
+	}
+}
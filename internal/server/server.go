@@ -1,35 +1,152 @@
 package server
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
+	"blog-platform/internal/auth"
 	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/media"
+	"blog-platform/internal/moderation"
+	"blog-platform/internal/related"
+	"blog-platform/internal/render"
+	"blog-platform/internal/sitemap"
 )
 
 type Server struct {
-	Port int
-	DB   database.BlogRepository
+	Port     int
+	DB       database.BlogRepository
+	Comments database.CommentRepository
+	Users    database.UserRepository
+
+	// Categories are the categories posts are filed under. Without one posts
+	// may name any category.
+	Categories database.CategoryRepository
+
+	// Tags counts and manages the tags on posts.
+	Tags database.TagRepository
+
+	// Media records uploaded images and the covers of posts, and MediaStore
+	// keeps their files. Without a MediaStore uploads are refused.
+	Media      database.MediaRepository
+	MediaStore media.MediaStore
+
+	// MaxUploadBytes is how big an uploaded image may be,
+	// DefaultMaxUploadBytes when zero.
+	MaxUploadBytes int64
+
+	// Auth signs and verifies the bearer tokens requests authenticate with.
+	Auth *auth.Keys
+
+	// TokenTTL is how long a token issued at login stays valid.
+	TokenTTL time.Duration
+
+	// TrashRetention is how long a deleted blog stays in the trash before
+	// PurgeTrash deletes it for good.
+	TrashRetention time.Duration
+
+	// Spam screens new comments. Without one every comment is approved.
+	Spam moderation.SpamScorer
+
+	// SpamThreshold is the score from which comments wait for a moderator,
+	// moderation.DefaultThreshold when zero.
+	SpamThreshold float64
+
+	// Rendered caches the HTML of blogs. Without one every response renders
+	// it afresh.
+	Rendered *render.Cache
+
+	// SiteTitle names the blog in its feeds, DefaultSiteTitle when empty.
+	SiteTitle string
+
+	// BaseURL is where the blog is served, for the links in feeds. Without
+	// one links point at the host a request came to.
+	BaseURL string
+
+	// FeedItems is how many posts a feed holds, DefaultFeedItems when zero.
+	FeedItems int
+
+	// Sitemaps caches the sitemap and robots.txt until a post changes.
+	// Without one every request builds them afresh.
+	Sitemaps *sitemap.Cache
+
+	// Related caches what related posts are picked from until a post
+	// changes. Without one every request works it out afresh.
+	Related *related.Cache
+
+	// Robots are the rules robots.txt gives crawlers before pointing them to
+	// the sitemap.
+	Robots string
 }
 
-func NewServer() *http.Server {
-	dbSettings := database.Settings{
-		HostName:   os.Getenv("DB_HOST"),
-		Port:       os.Getenv("DB_PORT"),
-		Username:   os.Getenv("DB_USERNAME"),
-		Password:   os.Getenv("DB_PASSWORD"),
-		DbName:     os.Getenv("DB_NAME"),
-		AuthSource: os.Getenv("DB_AUTHSOURCE"),
+// purgeInterval is how often the trash is checked for expired blogs.
+const purgeInterval = time.Hour
+
+// renderCacheSize is how many blogs the HTML is cached for.
+const renderCacheSize = 1000
+
+// sitemapMaxAge is how long the sitemap is kept when no post changes on this
+// server, for changes made on others to show.
+const sitemapMaxAge = time.Hour
+
+// relatedMaxAge is how long the related posts index is kept when no post
+// changes on this server.
+const relatedMaxAge = time.Hour
+
+// blogStore is a backend for blogs, which keeps the comments, categories and
+// media of them too and manages their tags.
+type blogStore interface {
+	database.BlogRepository
+	database.CommentRepository
+	database.CategoryRepository
+	database.TagRepository
+	database.MediaRepository
+}
+
+// newRepository picks the backend named by DB_DRIVER for blogs and users.
+// MongoDB is used when the variable is unset; "memory" needs no database at
+// all.
+func newRepository() (blogStore, database.UserRepository, error) {
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "mongo":
+		dbSettings := database.Settings{
+			HostName:   os.Getenv("DB_HOST"),
+			Port:       os.Getenv("DB_PORT"),
+			Username:   os.Getenv("DB_USERNAME"),
+			Password:   os.Getenv("DB_PASSWORD"),
+			DbName:     os.Getenv("DB_NAME"),
+			AuthSource: os.Getenv("DB_AUTHSOURCE"),
+		}
+		db, err := database.New(dbSettings)
+		if err != nil {
+			return nil, nil, err
+		}
+		users, err := database.NewUsers(db)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, users, nil
+	case "memory":
+		return database.NewMemory(), database.NewMemoryUsers(), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown DB_DRIVER %q", driver)
 	}
-	db, err := database.New(dbSettings)
+}
+
+func NewServer() *http.Server {
+	db, users, err := newRepository()
 
 	if err != nil {
 		log.Fatal(err)
@@ -41,9 +158,82 @@ func NewServer() *http.Server {
 		log.Fatal(err)
 	}
 
+	publishInterval, err := durationFromEnv("PUBLISH_INTERVAL", 30*time.Second)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trashRetention, err := durationFromEnv("TRASH_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keys, err := newKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tokenTTL, err := durationFromEnv("TOKEN_TTL", defaultTokenTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	feedItems, err := intFromEnv("FEED_ITEMS", DefaultFeedItems)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	robots, err := robotsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mediaStore, err := newMediaStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	maxUploadBytes, err := intFromEnv("MEDIA_MAX_BYTES", DefaultMaxUploadBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = BootstrapAdmin(context.Background(), users, dto.RegisterDTO{
+		Email:    os.Getenv("ADMIN_EMAIL"),
+		Name:     cmp.Or(os.Getenv("ADMIN_NAME"), "Admin"),
+		Password: os.Getenv("ADMIN_PASSWORD"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	spam := moderation.NewFilter(splitList(os.Getenv("SPAM_BLOCKLIST")))
+	if err := train(context.Background(), db, spam); err != nil {
+		log.Printf("cannot train the spam filter - %v", err)
+	}
+
 	NewServer := &Server{
-		Port: port,
-		DB:   db,
+		Port:           port,
+		DB:             db,
+		Comments:       db,
+		Categories:     db,
+		Tags:           db,
+		Media:          db,
+		MediaStore:     mediaStore,
+		MaxUploadBytes: int64(maxUploadBytes),
+		Users:          users,
+		Auth:           keys,
+		TokenTTL:       tokenTTL,
+		TrashRetention: trashRetention,
+		Spam:           spam,
+		SpamThreshold:  moderation.DefaultThreshold,
+		Rendered:       render.NewCache(renderCacheSize),
+		SiteTitle:      os.Getenv("SITE_TITLE"),
+		BaseURL:        os.Getenv("BASE_URL"),
+		FeedItems:      feedItems,
+		Sitemaps:       sitemap.NewCache(sitemapMaxAge),
+		Related:        related.NewCache(relatedMaxAge),
+		Robots:         robots,
 	}
 
 	server := &http.Server{
@@ -54,28 +244,167 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	jobs, stopJobs := context.WithCancel(context.Background())
+	server.RegisterOnShutdown(stopJobs)
+	go runEvery(jobs, publishInterval, NewServer.PublishDue)
+	go runEvery(jobs, purgeInterval, NewServer.PurgeTrash)
+
 	return server
 }
 
+// durationFromEnv reads a time.Duration such as "30s" from the environment,
+// falling back to def when the variable is unset.
+func durationFromEnv(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s - %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s - must be positive", key)
+	}
+	return d, nil
+}
+
+// intFromEnv reads a positive number from the environment, falling back to
+// def when the variable is unset.
+func intFromEnv(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s - %w", key, err)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid %s - must be positive", key)
+	}
+	return n, nil
+}
+
+// robotsFromEnv reads the robots.txt rules from the file named by
+// ROBOTS_FILE, falling back to DefaultRobots when the variable is unset.
+func robotsFromEnv() (string, error) {
+	path := os.Getenv("ROBOTS_FILE")
+	if path == "" {
+		return DefaultRobots, nil
+	}
+
+	rules, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid ROBOTS_FILE - %w", err)
+	}
+	return string(rules), nil
+}
+
+// splitList splits a comma separated environment variable, dropping blank
+// entries.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// corsOrigins reads the comma separated origins in CORS_ORIGINS, allowing
+// every origin when it is unset.
+func corsOrigins() []string {
+	origins := splitList(os.Getenv("CORS_ORIGINS"))
+	if len(origins) == 0 {
+		return []string{"*"}
+	}
+	return origins
+}
+
 func (s *Server) RegisterRoutes() http.Handler {
 	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
+	// Tokens travel in the Authorization header rather than cookies, so no
+	// origin needs credentials.
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"https://*", "http://*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		AllowCredentials: true,
-		MaxAge:           300,
+		AllowOrigins:  corsOrigins(),
+		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE"},
+		AllowHeaders:  []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-Modified-Since", "If-None-Match"},
+		ExposeHeaders: []string{"ETag", "Last-Modified"},
+		MaxAge:        300,
 	}))
+	e.Use(Authenticate(s.Auth, s.Users))
 
 	e.GET("/health", s.HealthHandler)
+	e.GET("/search", s.SearchHandler)
 	e.GET("/posts", s.GetBlogsHandler)
 	e.GET("/posts/:id", s.GetBlogHandler)
+	e.GET("/posts/by-slug/:slug", s.GetBlogBySlugHandler)
 	e.POST("/posts", s.CreateBlogHandler)
 	e.PUT("/posts/:id", s.UpdateBlogHandler)
 	e.DELETE("/posts/:id", s.DeleteBlogHandler)
+	e.POST("/posts/:id/publish", s.PublishBlogHandler)
+	e.POST("/posts/:id/unpublish", s.UnpublishBlogHandler)
+	e.POST("/posts/:id/archive", s.ArchiveBlogHandler)
+	e.POST("/posts/:id/schedule", s.ScheduleBlogHandler)
+	e.GET("/posts/:id/revisions", s.ListRevisionsHandler)
+	e.GET("/posts/:id/revisions/:rev", s.GetRevisionHandler)
+	e.POST("/posts/:id/revisions/:rev/restore", s.RestoreRevisionHandler)
+	e.GET("/posts/:id/diff", s.DiffRevisionsHandler)
+	e.POST("/posts/:id/restore", s.RestoreBlogHandler)
+	e.GET("/posts/:id/related", s.RelatedPostsHandler)
+	e.PUT("/posts/:id/cover", s.SetCoverHandler)
+	e.DELETE("/posts/:id/cover", s.RemoveCoverHandler)
+	e.GET("/posts/:id/comments", s.ListCommentsHandler)
+	e.POST("/posts/:id/comments", s.CreateCommentHandler)
+	e.PUT("/posts/:id/comments/:comment", s.UpdateCommentHandler)
+	e.DELETE("/posts/:id/comments/:comment", s.DeleteCommentHandler)
+	e.GET("/moderation/comments", s.ModerationQueueHandler)
+	e.POST("/moderation/comments/approve", s.ApproveCommentsHandler)
+	e.POST("/moderation/comments/reject", s.RejectCommentsHandler)
+	e.POST("/moderation/comments/spam", s.MarkSpamHandler)
+	e.GET("/trash", s.TrashHandler)
+	e.DELETE("/trash/:id", s.PurgeBlogHandler)
+	e.POST("/auth/register", s.RegisterHandler)
+	e.POST("/auth/login", s.LoginHandler)
+	e.POST("/auth/logout", s.LogoutHandler)
+	e.GET("/users/me", s.GetProfileHandler)
+	e.PUT("/users/me", s.UpdateProfileHandler)
+	e.PUT("/users/me/password", s.ChangePasswordHandler)
+	e.PUT("/users/:id/roles", s.SetRolesHandler)
+	e.POST("/api-keys", s.CreateAPIKeyHandler)
+	e.GET("/api-keys", s.ListAPIKeysHandler)
+	e.DELETE("/api-keys/:id", s.RevokeAPIKeyHandler)
+	e.GET("/authors/:id", s.GetAuthorHandler)
+	e.GET("/feed.xml", s.RSSHandler)
+	e.GET("/atom.xml", s.AtomHandler)
+	e.GET("/feed.json", s.JSONFeedHandler)
+	e.GET("/categories", s.ListCategoriesHandler)
+	e.POST("/categories", s.CreateCategoryHandler)
+	e.GET("/categories/:id", s.GetCategoryHandler)
+	e.PUT("/categories/:id", s.UpdateCategoryHandler)
+	e.DELETE("/categories/:id", s.DeleteCategoryHandler)
+	e.GET("/categories/:category/feed.xml", s.CategoryFeedHandler)
+	e.GET("/tags", s.ListTagsHandler)
+	e.GET("/tags/autocomplete", s.AutocompleteTagsHandler)
+	e.POST("/tags/merge", s.MergeTagsHandler)
+	e.PUT("/tags/:tag", s.RenameTagHandler)
+	e.GET("/tags/:tag/posts", s.TagPostsHandler)
+	e.GET("/tags/:tag/feed.xml", s.TagFeedHandler)
+	e.POST("/media", s.UploadMediaHandler)
+	e.GET("/media", s.ListMediaHandler)
+	e.GET("/media/:id", s.GetMediaHandler)
+	e.GET("/media/:id/:variant", s.MediaFileHandler)
+	e.GET("/sitemap.xml", s.SitemapHandler)
+	e.GET("/sitemaps/:part", s.SitemapPartHandler)
+	e.GET("/robots.txt", s.RobotsHandler)
 
 	return e
 }
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"blog-platform/internal/database"
+	"blog-platform/internal/sitemap"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultRobots keeps crawlers out of the parts of the API that are only
+// for signed in users.
+const DefaultRobots = `User-agent: *
+Disallow: /api-keys
+Disallow: /auth/
+Disallow: /moderation/
+Disallow: /trash
+Disallow: /users/`
+
+// SitemapHandler serves the sitemap of every published post, with the
+// listings of each category and tag, or an index of its parts once there are
+// too many URLs for one file.
+func (s *Server) SitemapHandler(c echo.Context) error {
+	files, err := s.crawlFiles(c)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationXMLCharsetUTF8, files.Sitemap)
+}
+
+// SitemapPartHandler serves a part of a sitemap too big for one file, named
+// like "2.xml".
+func (s *Server) SitemapPartHandler(c echo.Context) error {
+	files, err := s.crawlFiles(c)
+	if err != nil {
+		return err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSuffix(c.Param("part"), ".xml"))
+	if err != nil || n < 1 || n > len(files.Parts) {
+		return database.ErrNotFound
+	}
+	return c.Blob(http.StatusOK, echo.MIMEApplicationXMLCharsetUTF8, files.Parts[n-1])
+}
+
+// RobotsHandler serves robots.txt, pointing crawlers to the sitemap.
+func (s *Server) RobotsHandler(c echo.Context) error {
+	files, err := s.crawlFiles(c)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, files.Robots)
+}
+
+// crawlFiles returns the sitemap and robots.txt, built afresh only when a
+// post has changed since they were last.
+func (s *Server) crawlFiles(c echo.Context) (*sitemap.Files, error) {
+	base := s.baseURL(c)
+	return s.Sitemaps.Get(base, func() (*sitemap.Files, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request().Context()), 30*time.Second)
+		defer cancel()
+
+		urls, err := s.sitemapURLs(ctx, base)
+		if err != nil {
+			return nil, err
+		}
+		return sitemap.Build(base, urls, s.Robots)
+	})
+}
+
+// sitemapURLs lists every published post, then the listings of all posts and
+// of each category and tag, each last modified when the newest post in it
+// was.
+func (s *Server) sitemapURLs(ctx context.Context, base string) ([]sitemap.URL, error) {
+	var urls []sitemap.URL
+	var newest time.Time
+	categories := map[string]time.Time{}
+	tags := map[string]time.Time{}
+	seen := func(listing map[string]time.Time, key string, t time.Time) {
+		if key != "" && t.After(listing[key]) {
+			listing[key] = t
+		}
+	}
+
+	opts := database.ListOptions{
+		Limit:       database.MaxLimit,
+		Statuses:    []database.Status{database.StatusPublished},
+		OmitContent: true,
+	}
+	for {
+		page, err := s.DB.ListBlogs(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blog := range page.Items {
+			urls = append(urls, sitemap.URL{Loc: base + "/posts/by-slug/" + url.PathEscape(blog.Slug), LastMod: blog.UpdatedAt})
+			if blog.UpdatedAt.After(newest) {
+				newest = blog.UpdatedAt
+			}
+			seen(categories, blog.Category, blog.UpdatedAt)
+			for _, tag := range blog.Tags {
+				seen(tags, tag, blog.UpdatedAt)
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+
+	urls = append(urls, sitemap.URL{Loc: base + "/posts", LastMod: newest})
+	for _, category := range slices.Sorted(maps.Keys(categories)) {
+		urls = append(urls, sitemap.URL{
+			Loc:     base + "/posts?" + url.Values{"category": {category}}.Encode(),
+			LastMod: categories[category],
+		})
+	}
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		urls = append(urls, sitemap.URL{
+			Loc:     base + "/posts?" + url.Values{"tag": {tag}}.Encode(),
+			LastMod: tags[tag],
+		})
+	}
+	return urls, nil
+}
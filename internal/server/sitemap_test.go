@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+	"blog-platform/internal/sitemap"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSitemap(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	s := &server.Server{
+		DB:       repository,
+		BaseURL:  "https://blog.example",
+		Sitemaps: sitemap.NewCache(time.Hour),
+		Robots:   server.DefaultRobots,
+	}
+
+	published := string(database.StatusPublished)
+	createPost(t, repository, dto.BlogCreateDto{Title: "First", Category: "Tech", Content: "Content", Tags: []string{"go"}, Author: "a1", Status: published})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Second", Category: "Life & Times", Content: "Content", Tags: []string{"go", "travel"}, Author: "a1", Status: published})
+	draft := createPost(t, repository, dto.BlogCreateDto{Title: "Draft", Category: "Secret", Content: "Content", Tags: []string{"hidden"}, Author: "a1", Status: string(database.StatusDraft)})
+
+	get := func(handler echo.HandlerFunc, path string, params ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		return call(t, e, handler, http.MethodGet, path, "", auth.RoleAuthor, params...)
+	}
+
+	locations := func() []string {
+		t.Helper()
+		rec := get(s.SitemapHandler, "/sitemap.xml")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, echo.MIMEApplicationXMLCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+
+		var doc struct {
+			URLs []struct {
+				Loc     string `xml:"loc"`
+				LastMod string `xml:"lastmod"`
+			} `xml:"url"`
+		}
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+		var locs []string
+		for _, u := range doc.URLs {
+			assert.NotEmpty(t, u.LastMod, u.Loc)
+			locs = append(locs, u.Loc)
+		}
+		return locs
+	}
+
+	t.Run("Lists published posts and their listings", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"https://blog.example/posts/by-slug/second",
+			"https://blog.example/posts/by-slug/first",
+			"https://blog.example/posts",
+			"https://blog.example/posts?category=Life+%26+Times",
+			"https://blog.example/posts?category=Tech",
+			"https://blog.example/posts?tag=go",
+			"https://blog.example/posts?tag=travel",
+		}, locations())
+	})
+
+	t.Run("Is rebuilt when a post changes", func(t *testing.T) {
+		createPost(t, repository, dto.BlogCreateDto{Title: "Behind the cache", Category: "Tech", Content: "Content", Author: "a1", Status: published})
+		assert.NotContains(t, locations(), "https://blog.example/posts/by-slug/behind-the-cache")
+
+		req := as(httptest.NewRequest(http.MethodPost, "/posts/"+draft+"/publish", nil), "a1", auth.RoleAuthor)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(draft)
+		serve(s.PublishBlogHandler, c)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		locs := locations()
+		assert.Contains(t, locs, "https://blog.example/posts/by-slug/draft")
+		assert.Contains(t, locs, "https://blog.example/posts/by-slug/behind-the-cache")
+		assert.Contains(t, locs, "https://blog.example/posts?tag=hidden")
+	})
+
+	t.Run("Escapes slugs", func(t *testing.T) {
+		createPost(t, repository, dto.BlogCreateDto{Title: "東京", Category: "Travel", Content: "Content", Author: "a1", Status: published})
+		s.Sitemaps.Invalidate()
+		assert.Contains(t, locations(), "https://blog.example/posts/by-slug/%E6%9D%B1%E4%BA%AC")
+	})
+
+	t.Run("Has no parts while it fits in one file", func(t *testing.T) {
+		rec := get(s.SitemapPartHandler, "/sitemaps/1.xml", "part", "1.xml")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("robots.txt points to the sitemap", func(t *testing.T) {
+		rec := get(s.RobotsHandler, "/robots.txt")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, echo.MIMETextPlainCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, server.DefaultRobots+"\n\nSitemap: https://blog.example/sitemap.xml\n", rec.Body.String())
+	})
+}
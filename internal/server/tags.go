@@ -0,0 +1,149 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"cmp"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultSuggestions is how many tags autocomplete suggests unless asked for
+// more or fewer.
+const defaultSuggestions = 10
+
+// TagChange is the response to renaming or merging tags.
+type TagChange struct {
+	Tag   string `json:"tag"`
+	Posts int64  `json:"posts"`
+}
+
+// ListTagsHandler lists the tags of published posts, the most used first,
+// with how many posts carry each.
+func (s *Server) ListTagsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 2*time.Second)
+	defer cancel()
+
+	tags, err := s.Tags.ListTags(ctx, database.TagOptions{Statuses: []database.Status{database.StatusPublished}})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+// TagPostsHandler lists the published posts with a tag, taking the same
+// query parameters as GetBlogsHandler bar the search term and other tags.
+func (s *Server) TagPostsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	var query dto.BlogListQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+	query.Term = ""
+	query.Tags = []string{c.Param("tag")}
+	query.TagMatch = ""
+
+	return s.listPublished(ctx, c, query)
+}
+
+// AutocompleteTagsHandler suggests the tags starting with a prefix to
+// editors, drafts included, the most used first.
+func (s *Server) AutocompleteTagsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.Editors...); err != nil {
+		return err
+	}
+
+	var query dto.TagAutocompleteQuery
+	if err := c.Bind(&query); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid query parameters")
+	}
+
+	if err := validate.Struct(query); err != nil {
+		return err
+	}
+
+	tags, err := s.Tags.ListTags(ctx, database.TagOptions{
+		Prefix: database.NormalizeTag(query.Prefix),
+		Limit:  cmp.Or(query.Limit, defaultSuggestions),
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+// RenameTagHandler renames a tag on every post carrying it. Renaming it to a
+// tag that is in use merges the two.
+func (s *Server) RenameTagHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	var rename dto.TagRenameDTO
+	if err := c.Bind(&rename); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(rename); err != nil {
+		return err
+	}
+
+	return s.mergeTags(ctx, c, []string{c.Param("tag")}, rename.Name)
+}
+
+// MergeTagsHandler replaces several tags with one on every post carrying
+// any of them.
+func (s *Server) MergeTagsHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := requireRole(c, auth.ScopePostsWrite, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	var merge dto.TagMergeDTO
+	if err := c.Bind(&merge); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(merge); err != nil {
+		return err
+	}
+
+	return s.mergeTags(ctx, c, merge.Tags, merge.Into)
+}
+
+func (s *Server) mergeTags(ctx context.Context, c echo.Context, from []string, into string) error {
+	into = database.NormalizeTag(into)
+	if into == "" {
+		return NewProblem(http.StatusBadRequest, "tags cannot be blank")
+	}
+
+	posts, err := s.Tags.MergeTags(ctx, from, into)
+	if err != nil {
+		return err
+	}
+	if posts == 0 {
+		return NewProblem(http.StatusNotFound, "no post carries the tags")
+	}
+	s.Sitemaps.Invalidate()
+	s.Related.Invalidate()
+
+	return c.JSON(http.StatusOK, TagChange{Tag: into, Posts: posts})
+}
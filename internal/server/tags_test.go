@@ -0,0 +1,123 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagHandlers(t *testing.T) {
+	e := echo.New()
+	repository := database.NewMemory()
+	s := &server.Server{
+		DB:    repository,
+		Tags:  repository,
+		Users: database.NewMemoryUsers(),
+	}
+
+	ctx := context.Background()
+	published := string(database.StatusPublished)
+	createPost(t, repository, dto.BlogCreateDto{Title: "Goroutines", Category: "Tech", Content: "Content", Status: published, Tags: []string{"go", "concurrency"}})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Generics", Category: "Tech", Content: "Content", Status: published, Tags: []string{"Go", "golang"}})
+	createPost(t, repository, dto.BlogCreateDto{Title: "Draft", Category: "Tech", Content: "Content", Tags: []string{"go", "gophers"}})
+
+	counts := func(rec *httptest.ResponseRecorder) []database.TagCount {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code)
+		var tags []database.TagCount
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&tags))
+		return tags
+	}
+
+	t.Run("Lists the tags of published posts", func(t *testing.T) {
+		rec := call(t, e, s.ListTagsHandler, http.MethodGet, "/tags", "", "")
+		assert.Equal(t, []database.TagCount{
+			{Tag: "go", Count: 2},
+			{Tag: "concurrency", Count: 1},
+			{Tag: "golang", Count: 1},
+		}, counts(rec))
+	})
+
+	t.Run("Lists the posts with a tag", func(t *testing.T) {
+		rec := call(t, e, s.TagPostsHandler, http.MethodGet, "/tags/GO/posts?sort=title&order=asc", "", "", "tag", "GO")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var page database.BlogPage
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+		require.Len(t, page.Items, 2)
+		assert.Equal(t, "Generics", page.Items[0].Title)
+		assert.Equal(t, "Goroutines", page.Items[1].Title)
+	})
+
+	t.Run("Suggests tags to editors", func(t *testing.T) {
+		rec := call(t, e, s.AutocompleteTagsHandler, http.MethodGet, "/tags/autocomplete?prefix=Go", "", auth.RoleEditor)
+		assert.Equal(t, []database.TagCount{
+			{Tag: "go", Count: 3},
+			{Tag: "golang", Count: 1},
+			{Tag: "gophers", Count: 1},
+		}, counts(rec), "drafts included")
+
+		rec = call(t, e, s.AutocompleteTagsHandler, http.MethodGet, "/tags/autocomplete?prefix=go&limit=1", "", auth.RoleEditor)
+		assert.Len(t, counts(rec), 1)
+
+		rec = call(t, e, s.AutocompleteTagsHandler, http.MethodGet, "/tags/autocomplete", "", auth.RoleEditor)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = call(t, e, s.AutocompleteTagsHandler, http.MethodGet, "/tags/autocomplete?prefix=go", "", auth.RoleReader)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Admins rename tags", func(t *testing.T) {
+		rec := call(t, e, s.RenameTagHandler, http.MethodPut, "/tags/gophers", `{"name": "Gopher"}`, auth.RoleEditor, "tag", "gophers")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = call(t, e, s.RenameTagHandler, http.MethodPut, "/tags/gophers", `{"name": "Gopher"}`, auth.RoleAdmin, "tag", "gophers")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var change server.TagChange
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&change))
+		assert.Equal(t, server.TagChange{Tag: "gopher", Posts: 1}, change)
+
+		rec = call(t, e, s.RenameTagHandler, http.MethodPut, "/tags/missing", `{"name": "found"}`, auth.RoleAdmin, "tag", "missing")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		rec = call(t, e, s.RenameTagHandler, http.MethodPut, "/tags/gopher", `{"name": "   "}`, auth.RoleAdmin, "tag", "gopher")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Admins merge tags", func(t *testing.T) {
+		rec := call(t, e, s.MergeTagsHandler, http.MethodPost, "/tags/merge", `{"tags": ["golang", "gopher"], "into": "go"}`, auth.RoleAdmin)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var change server.TagChange
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&change))
+		assert.Equal(t, server.TagChange{Tag: "go", Posts: 2}, change)
+
+		rec = call(t, e, s.ListTagsHandler, http.MethodGet, "/tags", "", "")
+		assert.Equal(t, []database.TagCount{{Tag: "go", Count: 2}, {Tag: "concurrency", Count: 1}}, counts(rec))
+
+		rec = call(t, e, s.MergeTagsHandler, http.MethodPost, "/tags/merge", `{"tags": [], "into": "go"}`, auth.RoleAdmin)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Posts are tagged in normal form", func(t *testing.T) {
+		rec := call(t, e, s.CreateBlogHandler, http.MethodPost, "/posts", `{"title": "Tagged", "content": "Content", "category": "Tech", "tags": [" Web ", "WEB", "APIs"]}`, auth.RoleAuthor)
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var res map[string]string
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+
+		blog, err := repository.GetBlog(ctx, res["data"])
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web", "apis"}, blog.Tags)
+
+		rec = call(t, e, s.UpdateBlogHandler, http.MethodPut, "/posts/"+res["data"], `{"tags": ["HTTP", "http "]}`, auth.RoleEditor, "id", res["data"])
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"tags":["http"]`)
+	})
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultTokenTTL is how long login tokens last unless TokenTTL says
+// otherwise.
+const defaultTokenTTL = 24 * time.Hour
+
+// Login is the response to a successful login.
+type Login struct {
+	Token     string         `json:"token"`
+	TokenType string         `json:"tokenType"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+	User      *database.User `json:"user"`
+}
+
+// RegisterHandler creates an account. New accounts can read; an admin hands
+// out any other role.
+func (s *Server) RegisterHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var register dto.RegisterDTO
+	if err := c.Bind(&register); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(register); err != nil {
+		return err
+	}
+
+	hash, err := auth.HashPassword(register.Password)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.Users.CreateUser(ctx, dto.UserCreateDTO{
+		Email:        register.Email,
+		Name:         register.Name,
+		PasswordHash: hash,
+		Roles:        []auth.Role{auth.RoleReader},
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, user)
+}
+
+// LoginHandler issues a token for an email and password. Unknown emails and
+// wrong passwords fail alike, so logins do not reveal who has an account.
+func (s *Server) LoginHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	var login dto.LoginDTO
+	if err := c.Bind(&login); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(login); err != nil {
+		return err
+	}
+
+	user, err := s.Users.GetUserByEmail(ctx, login.Email)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+
+	var hash string
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if !auth.CheckPassword(hash, login.Password) {
+		return NewProblem(http.StatusUnauthorized, "invalid email or password")
+	}
+
+	ttl := cmp.Or(s.TokenTTL, defaultTokenTTL)
+	token, err := s.Auth.Sign(user.Principal(), ttl)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, Login{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresAt: time.Now().Add(ttl).UTC().Truncate(time.Second),
+		User:      user,
+	})
+}
+
+// LogoutHandler signs out the token the request was made with.
+func (s *Server) LogoutHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	p, err := requireUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Users.RevokeToken(ctx, p.TokenID, p.ExpiresAt); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) GetProfileHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	p, err := requireUser(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.Users.GetUser(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) UpdateProfileHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	p, err := requireUser(c)
+	if err != nil {
+		return err
+	}
+
+	var update dto.UserUpdateDTO
+	if err := c.Bind(&update); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+	update.Id = p.ID
+
+	if err := validate.Struct(update); err != nil {
+		return err
+	}
+
+	user, err := s.Users.UpdateUser(ctx, update)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// ChangePasswordHandler replaces the caller's password. The current password
+// has to be given too, so a stolen token alone cannot lock the owner out.
+// Every token issued before stops working, the caller's own included.
+func (s *Server) ChangePasswordHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 5*time.Second)
+	defer cancel()
+
+	p, err := requireUser(c)
+	if err != nil {
+		return err
+	}
+
+	var change dto.PasswordChangeDTO
+	if err := c.Bind(&change); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+
+	if err := validate.Struct(change); err != nil {
+		return err
+	}
+
+	user, err := s.Users.GetUser(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	if !auth.CheckPassword(user.PasswordHash, change.CurrentPassword) {
+		return NewProblem(http.StatusForbidden, "current password is incorrect")
+	}
+
+	hash, err := auth.HashPassword(change.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.Users.SetPassword(ctx, p.ID, hash); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetRolesHandler replaces the roles of a user. Tokens carry the roles they
+// were issued with, so the user's tokens stop working and the new roles
+// apply from their next login.
+func (s *Server) SetRolesHandler(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 1*time.Second)
+	defer cancel()
+
+	if _, err := requireAdmin(c); err != nil {
+		return err
+	}
+
+	var change dto.UserRolesDTO
+	if err := c.Bind(&change); err != nil {
+		return NewProblem(http.StatusBadRequest, "invalid request body")
+	}
+	change.Id = c.Param("id")
+
+	if err := validate.Struct(change); err != nil {
+		return err
+	}
+
+	user, err := s.Users.SetRoles(ctx, change)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// BootstrapAdmin creates the admin account admin describes, so a new
+// installation has someone to hand out roles. Nothing is done without an
+// email. An account that already has the email is never promoted, since
+// whoever registered it need not own the address; unless it is an admin
+// already, that is an error.
+func BootstrapAdmin(ctx context.Context, users database.UserRepository, admin dto.RegisterDTO) error {
+	if admin.Email == "" {
+		return nil
+	}
+
+	user, err := users.GetUserByEmail(ctx, admin.Email)
+	if err == nil {
+		if slices.Contains(user.Roles, auth.RoleAdmin) {
+			return nil
+		}
+		return fmt.Errorf("cannot make %v an admin: the account exists already - %w", admin.Email, database.ErrConflict)
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+
+	if err := validate.Struct(admin); err != nil {
+		return fmt.Errorf("invalid admin account - %w", err)
+	}
+	hash, err := auth.HashPassword(admin.Password)
+	if err != nil {
+		return err
+	}
+	_, err = users.CreateUser(ctx, dto.UserCreateDTO{
+		Email:        admin.Email,
+		Name:         admin.Name,
+		PasswordHash: hash,
+		Roles:        []auth.Role{auth.RoleAdmin},
+	})
+	return err
+}
@@ -0,0 +1,231 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blog-platform/internal/auth"
+	"blog-platform/internal/database"
+	"blog-platform/internal/dto"
+	"blog-platform/internal/server"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserHandlers(t *testing.T) {
+	e := echo.New()
+	users := database.NewMemoryUsers()
+	s := &server.Server{
+		Users:    users,
+		Auth:     keys,
+		TokenTTL: time.Hour,
+	}
+
+	request := func(method string, handler echo.HandlerFunc, payload, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", strings.NewReader(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if err := server.Authenticate(keys, users)(handler)(c); err != nil {
+			server.HTTPErrorHandler(err, c)
+		}
+		return rec
+	}
+	login := func(email, password string) string {
+		rec := request(http.MethodPost, s.LoginHandler, `{"email": "`+email+`", "password": "`+password+`"}`, "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var res server.Login
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		assert.Equal(t, "Bearer", res.TokenType)
+		return res.Token
+	}
+
+	t.Run("Registers readers", func(t *testing.T) {
+		rec := request(http.MethodPost, s.RegisterHandler, `{"email": "Ada@Example.com", "name": "Ada", "password": "analytical"}`, "")
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "analytical")
+		assert.NotContains(t, rec.Body.String(), "password")
+
+		var user database.User
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.Equal(t, "ada@example.com", user.Email)
+		assert.Equal(t, []auth.Role{auth.RoleReader}, user.Roles)
+
+		rec = request(http.MethodPost, s.RegisterHandler, `{"email": "ada@example.com", "name": "Ada", "password": "analytical"}`, "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	})
+
+	t.Run("Bootstraps the configured admin", func(t *testing.T) {
+		ctx := context.Background()
+		admin := dto.RegisterDTO{Email: "root@example.com", Name: "Root", Password: "superuser"}
+		require.NoError(t, server.BootstrapAdmin(ctx, users, admin))
+		require.NoError(t, server.BootstrapAdmin(ctx, users, admin), "an existing admin is left alone")
+		require.NoError(t, server.BootstrapAdmin(ctx, users, dto.RegisterDTO{}), "no email, no admin")
+
+		p, err := keys.Verify(login("root@example.com", "superuser"))
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleAdmin}, p.Roles)
+
+		rec := request(http.MethodPost, s.RegisterHandler, `{"email": "root@example.com", "name": "Root", "password": "superuser"}`, "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		err = server.BootstrapAdmin(ctx, users, dto.RegisterDTO{Email: "ada@example.com", Name: "Ada", Password: "analytical"})
+		assert.ErrorIs(t, err, database.ErrConflict, "registering first does not make anyone an admin")
+		ada, err := users.GetUserByEmail(ctx, "ada@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleReader}, ada.Roles)
+
+		err = server.BootstrapAdmin(ctx, users, dto.RegisterDTO{Email: "new@example.com", Name: "New", Password: "short"})
+		assert.Error(t, err)
+	})
+
+	t.Run("Validates registrations", func(t *testing.T) {
+		for _, payload := range []string{
+			`{"email": "not-an-email", "name": "Ada", "password": "analytical"}`,
+			`{"email": "grace@example.com", "name": "Grace", "password": "short"}`,
+			`{"email": "grace@example.com", "password": "analytical"}`,
+		} {
+			rec := request(http.MethodPost, s.RegisterHandler, payload, "")
+			assert.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		}
+	})
+
+	t.Run("Limits passwords in bytes", func(t *testing.T) {
+		// 40 characters, but 80 bytes: more than bcrypt can hash.
+		long := strings.Repeat("é", 40)
+		rec := request(http.MethodPost, s.RegisterHandler, `{"email": "grace@example.com", "name": "Grace", "password": "`+long+`"}`, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "must be at most 72 bytes long")
+
+		rec = request(http.MethodPost, s.RegisterHandler, `{"email": "grace@example.com", "name": "Grace", "password": "`+strings.Repeat("é", 36)+`"}`, "")
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		token := login("grace@example.com", strings.Repeat("é", 36))
+		rec = request(http.MethodPut, s.ChangePasswordHandler, `{"currentPassword": "`+strings.Repeat("é", 36)+`", "newPassword": "`+long+`"}`, token)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Logs in with the right password only", func(t *testing.T) {
+		token := login("ADA@example.com", "analytical")
+
+		p, err := keys.Verify(token)
+		require.NoError(t, err)
+		assert.Equal(t, "Ada", p.Name)
+		assert.Equal(t, []auth.Role{auth.RoleReader}, p.Roles)
+
+		for _, payload := range []string{
+			`{"email": "ada@example.com", "password": "wrong password"}`,
+			`{"email": "nobody@example.com", "password": "analytical"}`,
+		} {
+			rec := request(http.MethodPost, s.LoginHandler, payload, "")
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, payload)
+			assert.Contains(t, rec.Body.String(), "invalid email or password")
+		}
+	})
+
+	t.Run("Shows and updates the profile", func(t *testing.T) {
+		token := login("ada@example.com", "analytical")
+
+		rec := request(http.MethodGet, s.GetProfileHandler, "", token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"email":"ada@example.com"`)
+
+		rec = request(http.MethodPut, s.UpdateProfileHandler, `{"name": "Ada Lovelace"}`, token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"Ada Lovelace"`)
+
+		rec = request(http.MethodPut, s.UpdateProfileHandler, `{"bio": "Wrote the first program.", "avatarUrl": "https://example.com/ada.png"}`, token)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"avatarUrl":"https://example.com/ada.png"`)
+
+		rec = request(http.MethodPut, s.UpdateProfileHandler, `{"avatarUrl": "javascript:alert(1)"}`, token)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = request(http.MethodPut, s.UpdateProfileHandler, `{"email": "root@example.com"}`, token)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = request(http.MethodGet, s.GetProfileHandler, "", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Changes the password", func(t *testing.T) {
+		token := login("ada@example.com", "analytical")
+
+		rec := request(http.MethodPut, s.ChangePasswordHandler, `{"currentPassword": "wrong password", "newPassword": "difference engine"}`, token)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = request(http.MethodPut, s.ChangePasswordHandler, `{"currentPassword": "analytical", "newPassword": "difference engine"}`, token)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = request(http.MethodPost, s.LoginHandler, `{"email": "ada@example.com", "password": "analytical"}`, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = request(http.MethodGet, s.GetProfileHandler, "", token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "tokens from before the change stop working")
+
+		rec = request(http.MethodGet, s.GetProfileHandler, "", login("ada@example.com", "difference engine"))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Logout signs the token out", func(t *testing.T) {
+		token := login("ada@example.com", "difference engine")
+		other := login("ada@example.com", "difference engine")
+
+		rec := request(http.MethodPost, s.LogoutHandler, "", token)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = request(http.MethodGet, s.GetProfileHandler, "", token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "signed out")
+
+		rec = request(http.MethodGet, s.GetProfileHandler, "", other)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Admins set roles", func(t *testing.T) {
+		ada, err := users.GetUserByEmail(context.Background(), "ada@example.com")
+		require.NoError(t, err)
+		setRoles := func(payload, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPut, "/users/"+ada.ID.Hex()+"/roles", strings.NewReader(payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues(ada.ID.Hex())
+
+			serve(s.SetRolesHandler, c)
+			return rec
+		}
+
+		before := login("ada@example.com", "difference engine")
+		rec := setRoles(`{"roles": ["author"]}`, before)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		admin := login("root@example.com", "superuser")
+		rec = setRoles(`{"roles": ["owner"]}`, admin)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = setRoles(`{"roles": ["author", "editor"]}`, admin)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = request(http.MethodGet, s.GetProfileHandler, "", before)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "tokens carrying the old roles stop working")
+
+		p, err := keys.Verify(login("ada@example.com", "difference engine"))
+		require.NoError(t, err)
+		assert.Equal(t, []auth.Role{auth.RoleAuthor, auth.RoleEditor}, p.Roles)
+	})
+}
@@ -0,0 +1,141 @@
+// Package sitemap writes the files search engines crawl a site by: the XML
+// sitemap of its pages, split behind a sitemap index when there are too many
+// for one file, and the robots.txt that points them to it.
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"blog-platform/internal/cache"
+)
+
+// MaxURLs is the most URLs the sitemap protocol allows in one file.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a page for search engines to crawl and when it last changed.
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// Files are the crawl files of a site. Sitemap is served at /sitemap.xml:
+// the URLs themselves, or an index of Parts when there are more than MaxURLs.
+// Part n, counting from 1, is served at /sitemaps/n.xml.
+type Files struct {
+	Sitemap []byte
+	Parts   [][]byte
+	Robots  []byte
+}
+
+type urlset struct {
+	XMLName xml.Name `xml:"urlset"`
+	NS      string   `xml:"xmlns,attr"`
+	URLs    []entry  `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	NS       string   `xml:"xmlns,attr"`
+	Sitemaps []entry  `xml:"sitemap"`
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Build writes the crawl files for the site at base, which has no trailing
+// slash. Robots are the rules robots.txt starts with; a line pointing to the
+// sitemap is added after them.
+func Build(base string, urls []URL, robots string) (*Files, error) {
+	files := &Files{Robots: robotsTxt(robots, base+"/sitemap.xml")}
+
+	if len(urls) <= MaxURLs {
+		sitemap, err := write(urlset{NS: namespace, URLs: entries(urls)})
+		if err != nil {
+			return nil, err
+		}
+		files.Sitemap = sitemap
+		return files, nil
+	}
+
+	idx := index{NS: namespace}
+	for start := 0; start < len(urls); start += MaxURLs {
+		part := urls[start:min(start+MaxURLs, len(urls))]
+		body, err := write(urlset{NS: namespace, URLs: entries(part)})
+		if err != nil {
+			return nil, err
+		}
+		files.Parts = append(files.Parts, body)
+		idx.Sitemaps = append(idx.Sitemaps, entry{
+			Loc:     fmt.Sprintf("%s/sitemaps/%d.xml", base, len(files.Parts)),
+			LastMod: lastMod(newest(part)),
+		})
+	}
+
+	sitemap, err := write(idx)
+	if err != nil {
+		return nil, err
+	}
+	files.Sitemap = sitemap
+	return files, nil
+}
+
+func entries(urls []URL) []entry {
+	list := make([]entry, 0, len(urls))
+	for _, u := range urls {
+		list = append(list, entry{Loc: u.Loc, LastMod: lastMod(u.LastMod)})
+	}
+	return list
+}
+
+// lastMod formats t as a W3C datetime, or returns nothing for the zero time.
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func newest(urls []URL) time.Time {
+	var t time.Time
+	for _, u := range urls {
+		if u.LastMod.After(t) {
+			t = u.LastMod
+		}
+	}
+	return t
+}
+
+func write(doc any) ([]byte, error) {
+	body, err := xml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write sitemap - %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// robotsTxt is rules followed by a Sitemap line for sitemap.
+func robotsTxt(rules, sitemap string) []byte {
+	var b strings.Builder
+	if rules = strings.TrimSpace(rules); rules != "" {
+		b.WriteString(rules)
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(&b, "Sitemap: %s\n", sitemap)
+	return []byte(b.String())
+}
+
+// Cache keeps the Files of a site, built for its base URL, until its pages
+// change.
+type Cache = cache.Rebuilding[*Files]
+
+// NewCache returns a Cache that rebuilds files at least every maxAge.
+func NewCache(maxAge time.Duration) *Cache {
+	return cache.New[*Files](maxAge)
+}
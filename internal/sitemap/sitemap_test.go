@@ -0,0 +1,70 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type document struct {
+	XMLName  xml.Name
+	URLs     []entry `xml:"url"`
+	Sitemaps []entry `xml:"sitemap"`
+}
+
+func parse(t *testing.T, body []byte) document {
+	t.Helper()
+	var doc document
+	require.NoError(t, xml.Unmarshal(body, &doc), string(body))
+	return doc
+}
+
+func TestBuild(t *testing.T) {
+	day := time.Date(2025, time.April, 15, 10, 0, 0, 0, time.UTC)
+
+	t.Run("Lists the URLs", func(t *testing.T) {
+		files, err := Build("https://blog.example", []URL{
+			{Loc: "https://blog.example/posts/by-slug/a?x=1&y=2", LastMod: day},
+			{Loc: "https://blog.example/posts"},
+		}, "User-agent: *\nDisallow: /trash\n")
+		require.NoError(t, err)
+
+		doc := parse(t, files.Sitemap)
+		assert.Equal(t, xml.Name{Space: namespace, Local: "urlset"}, doc.XMLName)
+		assert.Equal(t, []entry{
+			{Loc: "https://blog.example/posts/by-slug/a?x=1&y=2", LastMod: "2025-04-15T10:00:00Z"},
+			{Loc: "https://blog.example/posts"},
+		}, doc.URLs)
+		assert.Contains(t, string(files.Sitemap), "a?x=1&amp;y=2", "locations are escaped")
+		assert.Empty(t, files.Parts)
+
+		assert.Equal(t, "User-agent: *\nDisallow: /trash\n\nSitemap: https://blog.example/sitemap.xml\n", string(files.Robots))
+	})
+
+	t.Run("Splits behind an index", func(t *testing.T) {
+		urls := make([]URL, MaxURLs+2)
+		for i := range urls {
+			urls[i] = URL{Loc: fmt.Sprintf("https://blog.example/%d", i), LastMod: day.Add(-time.Duration(i) * time.Minute)}
+		}
+		urls[MaxURLs+1].LastMod = day.Add(time.Hour)
+
+		files, err := Build("https://blog.example", urls, "")
+		require.NoError(t, err)
+
+		doc := parse(t, files.Sitemap)
+		assert.Equal(t, "sitemapindex", doc.XMLName.Local)
+		assert.Equal(t, []entry{
+			{Loc: "https://blog.example/sitemaps/1.xml", LastMod: "2025-04-15T10:00:00Z"},
+			{Loc: "https://blog.example/sitemaps/2.xml", LastMod: "2025-04-15T11:00:00Z"},
+		}, doc.Sitemaps)
+
+		require.Len(t, files.Parts, 2)
+		assert.Len(t, parse(t, files.Parts[0]).URLs, MaxURLs)
+		assert.Equal(t, "https://blog.example/50001", parse(t, files.Parts[1]).URLs[1].Loc)
+		assert.Equal(t, "Sitemap: https://blog.example/sitemap.xml\n", string(files.Robots))
+	})
+}
@@ -0,0 +1,113 @@
+// Package slug turns titles into the readable, URL-safe names posts are
+// served under.
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength caps the length in bytes of a generated slug, before any suffix
+// added to make it unique.
+const MaxLength = 80
+
+// fallback is the slug of a title with nothing that can be transliterated.
+const fallback = "post"
+
+// transliterations spells out letters that do not decompose into an ASCII
+// letter plus accents.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th",
+	'ł': "l", 'ı': "i", 'ħ': "h", 'ŋ': "ng", 'ĸ': "k", 'ſ': "s",
+
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+}
+
+// Make builds a slug from title: lowercase words joined by hyphens. Accents
+// are dropped and Cyrillic and Greek are transliterated; letters of other
+// scripts are kept as they are, since browsers display them in URLs.
+func Make(title string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	write := func(s string) {
+		if pendingHyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		pendingHyphen = false
+		b.WriteString(s)
+	}
+
+	// Transliterate before decomposing, so letters such as й that decompose
+	// into another letter plus a mark keep their own spelling.
+	for _, composed := range norm.NFC.String(title) {
+		composed = unicode.ToLower(composed)
+		if t, ok := transliterations[composed]; ok {
+			write(t)
+			continue
+		}
+		for _, r := range norm.NFKD.String(string(composed)) {
+			r = unicode.ToLower(r)
+			switch t, ok := transliterations[r]; {
+			case unicode.Is(unicode.Mn, r):
+			case ok:
+				write(t)
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				write(string(r))
+			default:
+				pendingHyphen = true
+			}
+		}
+	}
+
+	s := truncate(b.String())
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// truncate cuts s to MaxLength, at a hyphen when there is one to cut at.
+func truncate(s string) string {
+	if len(s) <= MaxLength {
+		return s
+	}
+	cut := MaxLength
+	for cut > 0 && !isBoundary(s, cut) {
+		cut--
+	}
+	if i := strings.LastIndexByte(s[:cut], '-'); i > 0 {
+		cut = i
+	}
+	return strings.TrimSuffix(s[:cut], "-")
+}
+
+func isBoundary(s string, i int) bool {
+	return i == len(s) || s[i] < 0x80 || s[i] >= 0xC0
+}
+
+// Valid reports whether s is already a slug, as a custom slug has to be.
+func Valid(s string) bool {
+	return s != "" && len(s) <= MaxLength && Make(s) == s
+}
+
+// WithSuffix returns the nth candidate for a slug based on base: base itself,
+// then base-2, base-3 and so on.
+func WithSuffix(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+	return base + "-" + strconv.Itoa(n)
+}
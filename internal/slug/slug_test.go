@@ -0,0 +1,65 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":                "hello-world",
+		"  Go 1.22 -- what's new?  ":   "go-1-22-what-s-new",
+		"Crème Brûlée à la Française":  "creme-brulee-a-la-francaise",
+		"Straße und Ærø":               "strasse-und-aero",
+		"Łódź":                         "lodz",
+		"Привет, мир":                  "privet-mir",
+		"Йога и щи":                    "yoga-i-shchi",
+		"Ελληνικά":                     "ellinika",
+		"ﬁnal ①":                       "final-1",
+		"東京 travel":                    "東京-travel",
+		"!!!":                          "post",
+		"":                             "post",
+		"already-a-slug":               "already-a-slug",
+		"UPPER_snake_Case":             "upper-snake-case",
+		"emoji 🚀 launch":               "emoji-launch",
+		"tabs\tand\nnewlines":          "tabs-and-newlines",
+		"trailing punctuation...":      "trailing-punctuation",
+		"numbers 2024 and 10x growth":  "numbers-2024-and-10x-growth",
+		"mixed Café-au-lait & croûton": "mixed-cafe-au-lait-crouton",
+	}
+
+	for title, want := range tests {
+		assert.Equal(t, want, Make(title), title)
+	}
+}
+
+func TestMakeTruncatesAtAWordBoundary(t *testing.T) {
+	title := strings.Repeat("word ", 30)
+	s := Make(title)
+	assert.LessOrEqual(t, len(s), MaxLength)
+	assert.True(t, strings.HasSuffix(s, "-word"))
+
+	s = Make(strings.Repeat("東", 40))
+	assert.LessOrEqual(t, len(s), MaxLength)
+	assert.True(t, Valid(s))
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("hello-world"))
+	assert.True(t, Valid("go-2"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid("Hello-World"))
+	assert.False(t, Valid("hello--world"))
+	assert.False(t, Valid("-hello"))
+	assert.False(t, Valid("hello world"))
+	assert.False(t, Valid("post/../admin"))
+	assert.False(t, Valid(strings.Repeat("a", MaxLength+1)))
+}
+
+func TestWithSuffix(t *testing.T) {
+	assert.Equal(t, "hello", WithSuffix("hello", 1))
+	assert.Equal(t, "hello-2", WithSuffix("hello", 2))
+	assert.Equal(t, "hello-10", WithSuffix("hello", 10))
+}
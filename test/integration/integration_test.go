@@ -1,6 +1,7 @@
 package integration_test
 
 import (
+	"blog-platform/internal/auth"
 	"blog-platform/internal/database"
 	"blog-platform/internal/server"
 	"blog-platform/test/helpers"
@@ -8,10 +9,12 @@ import (
 	"log"
 
 	"context"
+	"crypto/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/labstack/echo/v4"
@@ -49,19 +52,27 @@ func TestSuite(t *testing.T) {
 }
 
 func (suite *IntegrationTestSuite) TestSuite() {
+	secret := make([]byte, auth.MinSecretLength)
+	_, err := rand.Read(secret)
+	suite.Require().NoError(err)
+	keys, err := auth.NewHMACKeys(secret, "")
+	suite.Require().NoError(err)
+	token, err := keys.Sign(auth.Principal{ID: "integration", Roles: []auth.Role{auth.RoleEditor}}, time.Hour)
+	suite.Require().NoError(err)
 
 	createBlog := func(payload string) (*httptest.ResponseRecorder, error) {
 		e := echo.New()
 
 		req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(payload))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 
 		s := &server.Server{
 			DB: suite.repository,
 		}
-		err := s.CreateBlogHandler(c)
+		err := server.Authenticate(keys, nil)(s.CreateBlogHandler)(c)
 		if err != nil {
 			return nil, err
 		}
@@ -72,6 +83,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 		e := echo.New()
 
 		req := httptest.NewRequest(http.MethodDelete, "/posts/:id", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames("id")
@@ -80,7 +92,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 		s := &server.Server{
 			DB: suite.repository,
 		}
-		err := s.DeleteBlogHandler(c)
+		err := server.Authenticate(keys, nil)(s.DeleteBlogHandler)(c)
 		if err != nil {
 			return nil, err
 		}
@@ -92,6 +104,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 
 		req := httptest.NewRequest(http.MethodPut, "/posts/:id", strings.NewReader(payload))
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
 		rec := httptest.NewRecorder()
 		c := e.NewContext(req, rec)
 		c.SetParamNames("id")
@@ -100,7 +113,7 @@ func (suite *IntegrationTestSuite) TestSuite() {
 		s := &server.Server{
 			DB: suite.repository,
 		}
-		err := s.UpdateBlogHandler(c)
+		err := server.Authenticate(keys, nil)(s.UpdateBlogHandler)(c)
 		if err != nil {
 			return nil, err
 		}
@@ -160,14 +173,15 @@ func (suite *IntegrationTestSuite) TestSuite() {
 			"tags": ["go", "two"]
 		}`)
 
-		blogs, err := suite.repository.GetBlogs(context.Background())
+		page, err := suite.repository.ListBlogs(context.Background(), database.ListOptions{})
 		suite.NoError(err)
-		suite.Equal(2, len(blogs))
+		suite.Equal(2, len(page.Items))
 
-		blogs, err = suite.repository.GetBlogsByTerm(context.Background(), "foo")
-		suite.Equal("My Test Blog 2", (*blogs[0]).Title)
-		suite.Equal("Example", (*blogs[0]).Category)
-		suite.Equal("foo bar baz", (*blogs[0]).Content)
+		result, err := suite.repository.SearchBlogs(context.Background(), database.SearchOptions{Query: "foo"})
+		suite.NoError(err)
+		suite.Equal("My Test Blog 2", result.Items[0].Blog.Title)
+		suite.Equal("Example", result.Items[0].Blog.Category)
+		suite.Equal("foo bar baz", result.Items[0].Blog.Content)
 	})
 	//
 	suite.Run("Creates a blog and is able to update it", func() {